@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"common/logging"
+)
+
+func signedRequest(tokens *Tokens, method, target, requestID string) *http.Request {
+	r := httptest.NewRequest(method, target, nil)
+	r.Header.Set(logging.RequestIDHeader, requestID)
+	tokens.SignIdentity(r, Principal{UserID: 7, Scopes: []string{ScopeAdmin}})
+	return r
+}
+
+func TestVerifyIdentity(t *testing.T) {
+	tokens := NewTokens("test key", 0)
+
+	r := signedRequest(tokens, http.MethodPost, "/posts/1/like?x=1", "req-1")
+	p, signed, err := tokens.VerifyIdentity(r)
+	if err != nil || !signed {
+		t.Fatalf("got signed %v err %v, want a valid identity", signed, err)
+	}
+	if p.UserID != 7 || !p.HasScope(ScopeAdmin) {
+		t.Errorf("got %+v, want user 7 with admin", p)
+	}
+
+	anonymous := httptest.NewRequest(http.MethodGet, "/posts", nil)
+	tokens.SignIdentity(anonymous, Anonymous)
+	if _, signed, err := tokens.VerifyIdentity(anonymous); signed || err != nil {
+		t.Errorf("anonymous request: got signed %v err %v, want no identity", signed, err)
+	}
+}
+
+// headers captured from one request are replayed on another
+func TestVerifyIdentityRejectsReplay(t *testing.T) {
+	tokens := NewTokens("test key", 0)
+	captured := signedRequest(tokens, http.MethodGet, "/posts/1?format=html", "req-1")
+
+	tests := []struct {
+		name      string
+		method    string
+		target    string
+		requestID string
+	}{
+		{"other method", http.MethodDelete, "/posts/1?format=html", "req-1"},
+		{"other path", http.MethodGet, "/posts/2?format=html", "req-1"},
+		{"other query", http.MethodGet, "/posts/1?format=markdown", "req-1"},
+		{"other request id", http.MethodGet, "/posts/1?format=html", "req-2"},
+		{"no request id", http.MethodGet, "/posts/1?format=html", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, nil)
+			for _, name := range identityHeaders {
+				r.Header.Set(name, captured.Header.Get(name))
+			}
+			r.Header.Set(logging.RequestIDHeader, tt.requestID)
+
+			if _, _, err := tokens.VerifyIdentity(r); err != ErrTokenInvalid {
+				t.Errorf("got %v, want ErrTokenInvalid", err)
+			}
+		})
+	}
+}
+
+func TestVerifyIdentityRejectsForgery(t *testing.T) {
+	tokens := NewTokens("test key", 0)
+
+	tests := []struct {
+		name   string
+		modify func(r *http.Request)
+	}{
+		{"other user", func(r *http.Request) { r.Header.Set(HeaderUserID, "8") }},
+		{"added scope", func(r *http.Request) { r.Header.Set(HeaderUserScopes, ScopeAdmin+",other") }},
+		{"no signature", func(r *http.Request) { r.Header.Del(HeaderIdentitySignature) }},
+		{"other key", func(r *http.Request) {
+			NewTokens("other key", 0).SignIdentity(r, Principal{UserID: 7, Scopes: []string{ScopeAdmin}})
+		}},
+		//a signature with the token key itself, the identity key is derived from it
+		{"token key", func(r *http.Request) {
+			mac := hmac.New(sha256.New, []byte("test key"))
+			mac.Write([]byte("identity\n" + r.Header.Get(HeaderUserID) + "\n" + r.Header.Get(HeaderUserScopes) + "\n" + r.Header.Get(HeaderIdentityTimestamp)))
+			r.Header.Set(HeaderIdentitySignature, hex.EncodeToString(mac.Sum(nil)))
+		}},
+		{"stale", func(r *http.Request) {
+			timestamp := strconv.FormatInt(time.Now().Add(-2*identityMaxAge).Unix(), 10)
+			r.Header.Set(HeaderIdentityTimestamp, timestamp)
+			r.Header.Set(HeaderIdentitySignature, tokens.identitySignature(r, "7", ScopeAdmin, timestamp))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := signedRequest(tokens, http.MethodGet, "/posts/1", "req-1")
+			tt.modify(r)
+			if _, _, err := tokens.VerifyIdentity(r); err != ErrTokenInvalid {
+				t.Errorf("got %v, want ErrTokenInvalid", err)
+			}
+		})
+	}
+}
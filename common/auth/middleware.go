@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"common/logging"
+)
+
+type contextKey string
+
+var principalContextKey = contextKey("principal")
+
+func ContextSetPrincipal(r *http.Request, p Principal) *http.Request {
+	ctx := context.WithValue(r.Context(), principalContextKey, p)
+	return r.WithContext(ctx)
+}
+
+// panics when Authenticate didnt run for the request
+func ContextGetPrincipal(r *http.Request) Principal {
+	p, ok := r.Context().Value(principalContextKey).(Principal)
+	if !ok {
+		panic("cannot typecast to auth.Principal probably unknown value")
+	}
+	return p
+}
+
+// anonymous for routes that dont run Authenticate
+func ContextLookupPrincipal(r *http.Request) Principal {
+	p, ok := r.Context().Value(principalContextKey).(Principal)
+	if !ok {
+		return Anonymous
+	}
+	return p
+}
+
+type Middleware struct {
+	Tokens *Tokens
+
+	// runs after the signature and expiry were checked, for what only the
+	// service knows like revoked sessions. it may change the principal and
+	// return the request with more context values. ErrTokenInvalid answers
+	// 401, other errors go to OnError.
+	// token and claims are empty for requests with an identity signed by the
+	// gateway, which did the full check already
+	Check func(r *http.Request, token string, claims *Claims, p *Principal) (*http.Request, error)
+
+	// answers errors of Check, 500 when nil
+	OnError func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// adds the principal of the gateway identity or the bearer token to the
+// request, anonymous without either
+func (m *Middleware) Authenticate(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, signed, err := m.Tokens.VerifyIdentity(r)
+		if err != nil {
+			invalidToken(w)
+			return
+		}
+		if signed {
+			m.authenticated(w, r, next, "", nil, p)
+			return
+		}
+
+		authorizationHeader := r.Header.Get("Authorization")
+
+		if len(authorizationHeader) == 0 {
+			next.ServeHTTP(w, ContextSetPrincipal(r, Anonymous))
+			return
+		}
+
+		headerParts := strings.Split(authorizationHeader, " ")
+		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
+			sendError(w, http.StatusExpectationFailed, "invalid authentication headers.")
+			return
+		}
+		token := headerParts[1]
+
+		claims, err := m.Tokens.Verify(token)
+		if err != nil {
+			invalidToken(w)
+			return
+		}
+
+		p = Principal{UserID: claims.UserID, Scopes: claims.Scopes}
+		m.authenticated(w, r, next, token, claims, p)
+	}
+}
+
+func (m *Middleware) authenticated(w http.ResponseWriter, r *http.Request, next http.HandlerFunc, token string, claims *Claims, p Principal) {
+	if m.Check != nil {
+		var err error
+		r, err = m.Check(r, token, claims, &p)
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrTokenInvalid):
+				invalidToken(w)
+			case m.OnError != nil:
+				m.OnError(w, r, err)
+			default:
+				sendError(w, http.StatusInternalServerError, "server error")
+			}
+			return
+		}
+	}
+
+	r = ContextSetPrincipal(r, p)
+	logging.With(r.Context(), "user_id", p.UserID)
+
+	next.ServeHTTP(w, r)
+}
+
+// has to run after Authenticate
+func (m *Middleware) RequireAuthentication(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ContextGetPrincipal(r).IsAnonymous() {
+			sendError(w, http.StatusUnauthorized, "authentication required.")
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// has to run after RequireAuthentication
+func (m *Middleware) RequireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !ContextGetPrincipal(r).HasScope(scope) {
+			sendError(w, http.StatusForbidden, "not permitted.")
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+func invalidToken(w http.ResponseWriter) {
+	sendError(w, http.StatusUnauthorized, "token invalid")
+}
+
+// same body as the error responses of the services
+func sendError(w http.ResponseWriter, statusCode int, message string) {
+	js, err := json.Marshal(map[string]string{"error": message})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	js = append(js, '\n')
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(js)
+}
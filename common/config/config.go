@@ -0,0 +1,388 @@
+// Package config loads service configuration into a tagged struct.
+//
+// Sources are merged in increasing precedence:
+//
+//	`default` tags < yaml/toml file < environment < command line flags
+//
+// Every field is named by its `config` tag. The same name is used as the file
+// key and the flag (underscores become dashes). The environment variable is
+// the name in upper case behind Options.EnvPrefix, or the one in an `env` tag,
+// so a field named host is never read from the shell's HOST. Without a prefix
+// only fields with an `env` tag are read from the environment. Setting
+// NAME_FILE in the environment reads the value from that file instead, for
+// secrets mounted by docker or kubernetes.
+//
+//	type configuration struct {
+//		DBDriver   string `config:"db_driver" default:"postgres" validate:"oneof=postgres mysql sqlite"`
+//		DBPassword string `config:"dbpassword" secret:"true"`
+//		JWTKey     string `config:"jwt_key" env:"JWT_KEY" secret:"true"`
+//	}
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// name of the flag pointing at the config file, the environment variable is
+// EnvPrefix+CONFIG
+const fileKey = "config"
+
+type Options struct {
+	// yaml or toml file, overridden by -config or the EnvPrefix+CONFIG
+	// environment variable
+	File string
+	// put in front of the upper cased names to get the environment
+	// variables, e.g. USER_SERVICE_ turns dbname into USER_SERVICE_DBNAME
+	EnvPrefix string
+	// command line arguments without the program name, usually os.Args[1:]
+	Args []string
+}
+
+// field level problems found while loading, all of them are reported at once
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "invalid configuration:\n  " + strings.Join(msgs, "\n  ")
+}
+
+// implemented by configuration structs that need checks across fields
+type Validator interface {
+	Validate() error
+}
+
+type field struct {
+	key    string
+	env    string
+	secret bool
+	tags   reflect.StructTag
+	value  reflect.Value
+}
+
+// fills dst, a pointer to a struct, from every source and validates the result
+func Load(dst interface{}, opts Options) error {
+	fields, err := fieldsOf(dst)
+	if err != nil {
+		return err
+	}
+	for i := range fields {
+		if fields[i].env == "" && opts.EnvPrefix != "" {
+			fields[i].env = opts.EnvPrefix + strings.ToUpper(fields[i].key)
+		}
+	}
+
+	var errs Errors
+
+	for _, f := range fields {
+		if def, ok := f.tags.Lookup("default"); ok {
+			if err := set(f.value, def); err != nil {
+				errs = append(errs, fmt.Errorf("%s: bad default %q: %w", f.key, def, err))
+			}
+		}
+	}
+
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	fileFlag := fs.String(fileKey, "", "yaml or toml configuration file")
+	flagValues := map[string]*string{}
+	for _, f := range fields {
+		flagValues[f.key] = fs.String(strings.ReplaceAll(f.key, "_", "-"), "", "overrides "+f.key)
+	}
+	if err := fs.Parse(opts.Args); err != nil {
+		return err
+	}
+
+	file := opts.File
+	if opts.EnvPrefix != "" {
+		if v, ok := os.LookupEnv(opts.EnvPrefix + strings.ToUpper(fileKey)); ok {
+			file = v
+		}
+	}
+	if *fileFlag != "" {
+		file = *fileFlag
+	}
+	if file != "" {
+		values, err := readFile(file)
+		if err != nil {
+			return err
+		}
+		for _, f := range fields {
+			if raw, ok := values[f.key]; ok {
+				if err := set(f.value, fmt.Sprint(raw)); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %s: %w", file, f.key, err))
+				}
+			}
+		}
+	}
+
+	for _, f := range fields {
+		if f.env == "" {
+			continue
+		}
+		v, ok, err := envValue(f.env)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.key, err))
+			continue
+		}
+		if ok {
+			if err := set(f.value, v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: environment value %q: %w", f.key, redact(f, v), err))
+			}
+		}
+	}
+
+	fs.Visit(func(fl *flag.Flag) {
+		key := strings.ReplaceAll(fl.Name, "-", "_")
+		for _, f := range fields {
+			if f.key == key {
+				if err := set(f.value, *flagValues[key]); err != nil {
+					errs = append(errs, fmt.Errorf("%s: flag value %q: %w", f.key, redact(f, *flagValues[key]), err))
+				}
+			}
+		}
+	})
+
+	for _, f := range fields {
+		if err := validate(f); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.key, err))
+		}
+	}
+
+	if v, ok := dst.(Validator); ok && len(errs) == 0 {
+		if err := v.Validate(); err != nil {
+			var more Errors
+			if errors.As(err, &more) {
+				errs = append(errs, more...)
+			} else {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// effective configuration as "key = value" lines with secrets masked
+func Describe(src interface{}) []string {
+	fields, err := fieldsOf(src)
+	if err != nil {
+		return nil
+	}
+
+	lines := make([]string, 0, len(fields))
+	for _, f := range fields {
+		lines = append(lines, fmt.Sprintf("%s = %s", f.key, redact(f, fmt.Sprint(f.value.Interface()))))
+	}
+	return lines
+}
+
+func redact(f field, v string) string {
+	if f.secret && v != "" {
+		return "******"
+	}
+	return v
+}
+
+func fieldsOf(dst interface{}) ([]field, error) {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return nil, errors.New("config: destination must be a pointer to a struct")
+	}
+	rv = rv.Elem()
+
+	var fields []field
+	for i := 0; i < rv.NumField(); i++ {
+		sf := rv.Type().Field(i)
+		key, ok := sf.Tag.Lookup("config")
+		if !ok || key == "-" {
+			continue
+		}
+		fields = append(fields, field{
+			key:    key,
+			env:    sf.Tag.Get("env"),
+			secret: sf.Tag.Get("secret") == "true",
+			tags:   sf.Tag,
+			value:  rv.Field(i),
+		})
+	}
+	return fields, nil
+}
+
+// value of the variable, or the contents of the file named by name_FILE
+func envValue(name string) (string, bool, error) {
+	if path, ok := os.LookupEnv(name + "_FILE"); ok {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return "", false, fmt.Errorf("reading secret file: %w", err)
+		}
+		return strings.TrimRight(string(contents), "\r\n"), true, nil
+	}
+	v, ok := os.LookupEnv(name)
+	return v, ok, nil
+}
+
+func readFile(path string) (map[string]interface{}, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+
+	values := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(contents, &values)
+	case ".toml":
+		err = toml.Unmarshal(contents, &values)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported extension, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	// lists come back as []interface{}, flatten them to the comma form set understands
+	for k, v := range values {
+		if list, ok := v.([]interface{}); ok {
+			items := make([]string, len(list))
+			for i, item := range list {
+				items[i] = fmt.Sprint(item)
+			}
+			values[k] = strings.Join(items, ",")
+		}
+	}
+	return values, nil
+}
+
+func set(v reflect.Value, raw string) error {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// rules in the validate tag, comma separated: required, oneof=a b c, min=N, max=N
+func validate(f field) error {
+	rules, ok := f.tags.Lookup("validate")
+	if !ok {
+		return nil
+	}
+
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+
+		switch name {
+		case "required":
+			if f.value.IsZero() {
+				return errors.New("is required")
+			}
+
+		case "oneof":
+			options := strings.Fields(arg)
+			current := redact(f, fmt.Sprint(f.value.Interface()))
+			found := false
+			for _, o := range options {
+				if o == current {
+					found = true
+				}
+			}
+			if !found {
+				return fmt.Errorf("must be one of %s, got %q", strings.Join(options, ", "), current)
+			}
+
+		case "min", "max":
+			limit, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return fmt.Errorf("bad %s rule %q", name, arg)
+			}
+			n, ok := number(f.value)
+			if !ok {
+				n = float64(f.value.Len())
+			}
+			if name == "min" && n < limit {
+				return fmt.Errorf("must be at least %s, got %v", arg, n)
+			}
+			if name == "max" && n > limit {
+				return fmt.Errorf("must be at most %s, got %v", arg, n)
+			}
+
+		default:
+			return fmt.Errorf("unknown validation rule %q", name)
+		}
+	}
+	return nil
+}
+
+func number(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
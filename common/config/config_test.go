@@ -0,0 +1,258 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testConfig struct {
+	Host     string        `config:"host" default:"default-host"`
+	Port     int           `config:"port" default:"80" validate:"min=1,max=65535"`
+	Mode     string        `config:"mode" default:"fast" validate:"oneof=fast safe"`
+	Timeout  time.Duration `config:"timeout" default:"1s"`
+	Tags     []string      `config:"tags"`
+	Password string        `config:"password" secret:"true"`
+	Shared   string        `config:"shared" env:"TEST_SHARED_KEY"`
+	Ignored  string
+}
+
+// port and password together are checked across fields
+type crossConfig struct {
+	Port     int    `config:"port" default:"80"`
+	Password string `config:"password" secret:"true"`
+	PIN      int    `config:"pin" secret:"true"`
+}
+
+func (c *crossConfig) Validate() error {
+	if c.Port == 443 && c.Password == "" {
+		return Errors{errors.New("password: is required on port 443")}
+	}
+	return nil
+}
+
+const prefix = "TEST_"
+
+func writeFile(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPrecedence(t *testing.T) {
+	file := writeFile(t, "config.yaml", "host: file-host\nport: 81\ntags: [a, b]\n")
+
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{"default", "", nil, nil, "default-host"},
+		{"file over default", file, nil, nil, "file-host"},
+		{"env over file", file, map[string]string{"TEST_HOST": "env-host"}, nil, "env-host"},
+		{"flag over env", file, map[string]string{"TEST_HOST": "env-host"}, []string{"-host", "flag-host"}, "flag-host"},
+		{"file from env", "", map[string]string{"TEST_CONFIG": file}, nil, "file-host"},
+		{"file from flag", "", nil, []string{"-config", file}, "file-host"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			var c testConfig
+			if err := Load(&c, Options{File: tt.file, Args: tt.args, EnvPrefix: prefix}); err != nil {
+				t.Fatal(err)
+			}
+			if c.Host != tt.want {
+				t.Errorf("host: got %q, want %q", c.Host, tt.want)
+			}
+		})
+	}
+}
+
+func TestTypes(t *testing.T) {
+	file := writeFile(t, "config.toml", "port = 81\ntags = [\"a\", \"b\"]\n")
+	t.Setenv("TEST_TIMEOUT", "2m")
+
+	var c testConfig
+	if err := Load(&c, Options{File: file, Args: []string{"-mode", "safe"}, EnvPrefix: prefix}); err != nil {
+		t.Fatal(err)
+	}
+	want := testConfig{
+		Host:    "default-host",
+		Port:    81,
+		Mode:    "safe",
+		Timeout: 2 * time.Minute,
+		Tags:    []string{"a", "b"},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("got %+v, want %+v", c, want)
+	}
+}
+
+// only prefixed or explicitly named variables are read
+func TestEnvNames(t *testing.T) {
+	tests := []struct {
+		name      string
+		prefix    string
+		env       map[string]string
+		wantHost  string
+		wantShare string
+	}{
+		{"shell variable", prefix, map[string]string{"HOST": "shell-host"}, "default-host", ""},
+		{"lower case", prefix, map[string]string{"host": "shell-host"}, "default-host", ""},
+		{"prefixed", prefix, map[string]string{"TEST_HOST": "env-host"}, "env-host", ""},
+		{"env tag", prefix, map[string]string{"TEST_SHARED_KEY": "shared", "TEST_SHARED": "prefixed"}, "default-host", "shared"},
+		{"no prefix", "", map[string]string{"HOST": "shell-host", "TEST_SHARED_KEY": "shared"}, "default-host", "shared"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			var c testConfig
+			if err := Load(&c, Options{EnvPrefix: tt.prefix}); err != nil {
+				t.Fatal(err)
+			}
+			if c.Host != tt.wantHost || c.Shared != tt.wantShare {
+				t.Errorf("got host %q shared %q, want %q %q", c.Host, c.Shared, tt.wantHost, tt.wantShare)
+			}
+		})
+	}
+}
+
+func TestSecretFile(t *testing.T) {
+	t.Run("read", func(t *testing.T) {
+		t.Setenv("TEST_PASSWORD", "from env")
+		t.Setenv("TEST_PASSWORD_FILE", writeFile(t, "password", "from file\n"))
+		t.Setenv("TEST_SHARED_KEY_FILE", writeFile(t, "shared", "shared\r\n"))
+
+		var c testConfig
+		if err := Load(&c, Options{EnvPrefix: prefix}); err != nil {
+			t.Fatal(err)
+		}
+		if c.Password != "from file" || c.Shared != "shared" {
+			t.Errorf("got password %q shared %q, want them from the files without the line break", c.Password, c.Shared)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		t.Setenv("TEST_PASSWORD_FILE", filepath.Join(t.TempDir(), "missing"))
+
+		var c testConfig
+		err := Load(&c, Options{EnvPrefix: prefix})
+		if err == nil || !strings.Contains(err.Error(), "password: reading secret file") {
+			t.Errorf("got %v, want a secret file error", err)
+		}
+	})
+}
+
+func TestValidationErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		dst  interface{}
+		file string
+		env  map[string]string
+		args []string
+		want []string
+	}{
+		{
+			name: "all field errors at once",
+			dst:  &testConfig{},
+			args: []string{"-port", "0", "-mode", "slow"},
+			want: []string{"port: must be at least 1, got 0", `mode: must be one of fast, safe, got "slow"`},
+		},
+		{
+			name: "bad values",
+			dst:  &testConfig{},
+			env:  map[string]string{"TEST_PORT": "eighty", "TEST_TIMEOUT": "soon"},
+			want: []string{`port: environment value "eighty"`, `timeout: environment value "soon"`},
+		},
+		{
+			name: "bad file value",
+			dst:  &testConfig{},
+			file: writeFile(t, "config.yaml", "port: 70000\n"),
+			want: []string{"port: must be at most 65535, got 70000"},
+		},
+		{
+			name: "secret value",
+			dst:  &crossConfig{},
+			env:  map[string]string{"TEST_PIN": "12x4"},
+			want: []string{`pin: environment value "******"`},
+		},
+		{
+			name: "cross field",
+			dst:  &crossConfig{},
+			args: []string{"-port", "443"},
+			want: []string{"password: is required on port 443"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			err := Load(tt.dst, Options{File: tt.file, Args: tt.args, EnvPrefix: prefix})
+			var errs Errors
+			if !errors.As(err, &errs) {
+				t.Fatalf("got %v, want Errors", err)
+			}
+			if len(errs) != len(tt.want) {
+				t.Errorf("got %d errors, want %d: %v", len(errs), len(tt.want), err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("got %q, want it to contain %q", err.Error(), want)
+				}
+			}
+		})
+	}
+
+	t.Run("unsupported file", func(t *testing.T) {
+		var c testConfig
+		err := Load(&c, Options{File: writeFile(t, "config.json", "{}")})
+		if err == nil || !strings.Contains(err.Error(), "unsupported extension") {
+			t.Errorf("got %v, want an unsupported extension error", err)
+		}
+	})
+
+	t.Run("not a struct pointer", func(t *testing.T) {
+		if err := Load(testConfig{}, Options{}); err == nil {
+			t.Error("got nil, want an error")
+		}
+	})
+}
+
+func TestDescribe(t *testing.T) {
+	c := testConfig{Host: "h", Port: 1, Mode: "fast", Tags: []string{"a", "b"}, Password: "hunter2"}
+	want := []string{
+		"host = h",
+		"port = 1",
+		"mode = fast",
+		"timeout = 0s",
+		"tags = [a b]",
+		"password = ******",
+		"shared = ",
+	}
+	if got := Describe(&c); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	//an unset secret shows it is missing
+	c.Password = ""
+	if got := Describe(&c); got[5] != "password = " {
+		t.Errorf("got %q, want an empty password", got[5])
+	}
+}
@@ -0,0 +1,192 @@
+// Package health serves the liveness and readiness endpoints of the services.
+//
+// /healthz only reports that the process is up and serving, it never looks at
+// dependencies so an outage of the database doesnt get every instance
+// restarted. /readyz runs every registered check concurrently, each with its
+// own timeout, and answers 503 when one of them fails or the server is
+// draining for shutdown.
+package health
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusOK          = "ok"
+	StatusError       = "error"
+	StatusUnavailable = "unavailable"
+	StatusDraining    = "draining"
+)
+
+// reports whether a dependency is usable, ctx carries the per check timeout
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+type Checker struct {
+	timeout  time.Duration
+	checks   []namedCheck
+	draining atomic.Bool
+}
+
+type CheckResult struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// timeout applies to every check separately
+func New(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// registers a readiness check, not safe to call once the handlers are serving
+func (c *Checker) Add(name string, check Check) {
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// marks the instance not ready so load balancers stop sending new requests
+// while the server finishes the ones in flight
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// runs every check concurrently and collects the results
+func (c *Checker) Run(ctx context.Context) Report {
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(c.checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, nc := range c.checks {
+		wg.Add(1)
+		go func(nc namedCheck) {
+			defer wg.Done()
+			result := c.run(ctx, nc.check)
+
+			mu.Lock()
+			report.Checks[nc.name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusUnavailable
+			}
+			mu.Unlock()
+		}(nc)
+	}
+	wg.Wait()
+
+	if c.draining.Load() {
+		report.Status = StatusDraining
+	}
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	// a check ignoring ctx still reports the timeout
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+
+	result := CheckResult{Status: StatusOK, DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = StatusError
+		result.Error = err.Error()
+		if errors.Is(err, context.DeadlineExceeded) {
+			result.Error = fmt.Sprintf("timed out after %s", c.timeout)
+		}
+	}
+	return result
+}
+
+// /healthz, 200 as long as the process can answer
+func (c *Checker) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, Report{Status: StatusOK}, http.StatusOK)
+	})
+}
+
+// /readyz, 200 when every check passed, 503 otherwise
+func (c *Checker) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := c.Run(r.Context())
+
+		status := http.StatusOK
+		if report.Status != StatusOK {
+			status = http.StatusServiceUnavailable
+		}
+		writeReport(w, report, status)
+	})
+}
+
+func writeReport(w http.ResponseWriter, report Report, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+// pings the sql database
+func SQLCheck(db *sql.DB) Check {
+	return func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	}
+}
+
+// creates and removes a file in dir
+func DirWritable(dir string) Check {
+	return func(ctx context.Context) error {
+		f, err := os.CreateTemp(dir, ".healthcheck-*")
+		if err != nil {
+			return err
+		}
+		name := f.Name()
+		_, err = f.Write([]byte("ok"))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if rerr := os.Remove(name); err == nil {
+			err = rerr
+		}
+		return err
+	}
+}
+
+// GET url must answer with a 2xx status
+func HTTPCheck(client *http.Client, url string) Check {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("%s answered %s", url, resp.Status)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,252 @@
+// Package idempotency makes retried POST and PATCH requests safe.
+//
+// A client sends the same Idempotency-Key header with every attempt of one
+// request. The first attempt runs the handler and its response is stored for
+// the user and key, later attempts get the stored response back with
+// Idempotent-Replayed: true instead of running the handler again. An attempt
+// while the first is still running is answered with 409, reusing a key for a
+// different method, path or body with 422.
+//
+// Responses with a 5xx status are not stored, the key is released so the
+// client can retry. Only the headers in storedHeaders are kept with a
+// response, credentials like a new session token are not stored and not
+// replayed. Storage is left to the services, see Store.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"common/logging"
+)
+
+const (
+	Header         = "Idempotency-Key"
+	ReplayedHeader = "Idempotent-Replayed"
+)
+
+// longest key accepted, enough for a uuid or a hash with a prefix
+const maxKeyLength = 255
+
+// anonymous requests all share user 0, their keys have to be long enough
+// that two clients dont pick the same one, a uuid will do
+const minAnonymousKeyLength = 32
+
+// bodies are read whole to fingerprint them, same limit as the json handlers
+const maxBodyBytes = 1_048_576
+
+var (
+	ErrInFlight = errors.New("idempotency: key is in use by a running request")
+	ErrMismatch = errors.New("idempotency: key was used for a different request")
+)
+
+// keys are per user, anonymous requests share user 0, see minAnonymousKeyLength
+type Key struct {
+	UserID uint64
+	Value  string
+}
+
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Store keeps a record per Key, either in flight or completed with a response.
+// Records expire at the time passed to Begin or Complete and are treated as
+// absent afterwards, an in flight record expiring means its request died
+// without releasing it.
+type Store interface {
+	// claims key and returns nil, nil. when a live record exists it returns
+	// ErrMismatch if fingerprint differs, ErrInFlight if it isnt completed and
+	// the stored response otherwise
+	Begin(key Key, fingerprint string, lockedUntil time.Time) (*Response, error)
+	Complete(key Key, response *Response, expiresAt time.Time) error
+	Release(key Key) error
+}
+
+type Middleware struct {
+	// store for the request, so queries run under its context
+	Store func(r *http.Request) Store
+	// owner of the key, usually the authenticated user
+	UserID func(r *http.Request) uint64
+
+	// how long responses are replayed
+	TTL time.Duration
+	// how long a request may hold its key, longer than any request can run
+	LockTimeout time.Duration
+
+	// answers store failures, 500 when nil
+	OnError func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// requests without the header or with another method go straight to next
+func (m *Middleware) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		value := r.Header.Get(Header)
+		if value == "" || (r.Method != http.MethodPost && r.Method != http.MethodPatch) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !validKey(value) {
+			sendError(w, http.StatusBadRequest, "invalid Idempotency-Key header.")
+			return
+		}
+		key := Key{UserID: m.UserID(r), Value: value}
+		if key.UserID == 0 && len(value) < minAnonymousKeyLength {
+			sendError(w, http.StatusBadRequest, "Idempotency-Key of a request without a token must be at least 32 characters.")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				sendError(w, http.StatusRequestEntityTooLarge, "body must not be larger than 1048576 bytes")
+				return
+			}
+			sendError(w, http.StatusBadRequest, "couldnt read the request body.")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		fingerprint := fingerprint(r, body)
+
+		stored, err := m.Store(r).Begin(key, fingerprint, time.Now().Add(m.LockTimeout))
+		switch {
+		case errors.Is(err, ErrInFlight):
+			sendError(w, http.StatusConflict, "a request with this Idempotency-Key is still in progress.")
+			return
+		case errors.Is(err, ErrMismatch):
+			sendError(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request.")
+			return
+		case err != nil:
+			m.fail(w, r, err)
+			return
+		case stored != nil:
+			replay(w, stored)
+			return
+		}
+
+		//the key has to be completed or released even when the client is gone
+		//or the handler panics, or retries get 409 until the lock times out
+		store := m.Store(r.WithContext(context.WithoutCancel(r.Context())))
+		rec := &recorder{ResponseWriter: w}
+		done := false
+		defer func() {
+			if done {
+				return
+			}
+			if err := store.Release(key); err != nil {
+				logging.FromContext(r.Context()).Error("couldnt release idempotency key", "err", err)
+			}
+		}()
+
+		next.ServeHTTP(rec, r)
+
+		if rec.status >= http.StatusInternalServerError {
+			return
+		}
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		response := &Response{Status: rec.status, Header: rec.header, Body: rec.body.Bytes()}
+		if err := store.Complete(key, response, time.Now().Add(m.TTL)); err != nil {
+			logging.FromContext(r.Context()).Error("couldnt store idempotent response", "err", err)
+			return
+		}
+		done = true
+	}
+}
+
+func (m *Middleware) fail(w http.ResponseWriter, r *http.Request, err error) {
+	if m.OnError != nil {
+		m.OnError(w, r, err)
+		return
+	}
+	sendError(w, http.StatusInternalServerError, "server error")
+}
+
+// printable ascii without spaces
+func validKey(value string) bool {
+	if len(value) > maxKeyLength {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		if value[i] <= ' ' || value[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func replay(w http.ResponseWriter, stored *Response) {
+	for k, v := range stored.Header {
+		w.Header()[k] = v
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(stored.Status)
+	w.Write(stored.Body)
+}
+
+// response headers kept for replays, whatever else a handler sets like the
+// Authentication-Token of a registration or the request id is left out
+var storedHeaders = []string{"Content-Type", "Content-Language", "Cache-Control", "Location", "ETag", "Last-Modified"}
+
+// passes the response through and keeps a copy
+type recorder struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+		r.header = http.Header{}
+		for _, name := range storedHeaders {
+			if v := r.ResponseWriter.Header().Values(name); len(v) != 0 {
+				r.header[name] = append([]string{}, v...)
+			}
+		}
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// same body as the error responses of the services
+func sendError(w http.ResponseWriter, statusCode int, message string) {
+	js, err := json.Marshal(map[string]string{"error": message})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	js = append(js, '\n')
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(js)
+}
@@ -0,0 +1,345 @@
+package idempotency
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type record struct {
+	fingerprint string
+	response    *Response
+	expires     time.Time
+}
+
+// Store kept in a map, the way the services implement it with a table
+type memStore struct {
+	mu      sync.Mutex
+	records map[Key]*record
+}
+
+func newMemStore() *memStore {
+	return &memStore{records: map[Key]*record{}}
+}
+
+func (s *memStore) Begin(key Key, fingerprint string, lockedUntil time.Time) (*Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rec, ok := s.records[key]; ok && time.Now().Before(rec.expires) {
+		switch {
+		case rec.fingerprint != fingerprint:
+			return nil, ErrMismatch
+		case rec.response == nil:
+			return nil, ErrInFlight
+		}
+		return rec.response, nil
+	}
+	s.records[key] = &record{fingerprint: fingerprint, expires: lockedUntil}
+	return nil, nil
+}
+
+func (s *memStore) Complete(key Key, response *Response, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[key].response = response
+	s.records[key].expires = expiresAt
+	return nil
+}
+
+func (s *memStore) Release(key Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
+
+func (s *memStore) has(key Key) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.records[key]
+	return ok
+}
+
+// the user is taken from X-User so tests can act as different users
+func newMiddleware(store *memStore) *Middleware {
+	return &Middleware{
+		Store: func(r *http.Request) Store { return store },
+		UserID: func(r *http.Request) uint64 {
+			id, _ := strconv.ParseUint(r.Header.Get("X-User"), 10, 64)
+			return id
+		},
+		TTL:         time.Hour,
+		LockTimeout: time.Minute,
+	}
+}
+
+// made by user 1, set X-User to 0 for an anonymous request
+func newRequest(method, path, key, body string) *http.Request {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("X-User", "1")
+	if key != "" {
+		r.Header.Set(Header, key)
+	}
+	return r
+}
+
+func serve(h http.HandlerFunc, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h(w, r)
+	return w
+}
+
+func TestReplay(t *testing.T) {
+	calls := 0
+	h := newMiddleware(newMemStore()).Wrap(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Location", "/posts/"+strconv.Itoa(calls))
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":` + strconv.Itoa(calls) + `}`))
+	})
+
+	first := serve(h, newRequest(http.MethodPost, "/posts", "key-1", `{"title":"a"}`))
+	second := serve(h, newRequest(http.MethodPost, "/posts", "key-1", `{"title":"a"}`))
+
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", calls)
+	}
+	if first.Header().Get(ReplayedHeader) != "" {
+		t.Error("first response is marked as replayed")
+	}
+	if second.Header().Get(ReplayedHeader) != "true" {
+		t.Error("second response is not marked as replayed")
+	}
+	if second.Code != first.Code || second.Body.String() != first.Body.String() {
+		t.Errorf("replay is %d %q, want %d %q", second.Code, second.Body, first.Code, first.Body)
+	}
+	if got := second.Header().Get("Location"); got != "/posts/1" {
+		t.Errorf("replayed Location is %q, want /posts/1", got)
+	}
+
+	//keys belong to a user
+	other := newRequest(http.MethodPost, "/posts", "key-1", `{"title":"a"}`)
+	other.Header.Set("X-User", "2")
+	if w := serve(h, other); w.Header().Get(ReplayedHeader) != "" || calls != 2 {
+		t.Errorf("the same key of another user was replayed")
+	}
+}
+
+func TestPassThrough(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		key      string
+		wantCode int
+		wantRuns int
+	}{
+		{"no key", http.MethodPost, "", http.StatusOK, 2},
+		{"GET", http.MethodGet, "key-1", http.StatusOK, 2},
+		{"DELETE", http.MethodDelete, "key-1", http.StatusOK, 2},
+		{"PATCH", http.MethodPatch, "key-1", http.StatusOK, 1},
+		{"key with a space", http.MethodPost, "key 1", http.StatusBadRequest, 0},
+		{"key too long", http.MethodPost, strings.Repeat("k", maxKeyLength+1), http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runs := 0
+			h := newMiddleware(newMemStore()).Wrap(func(w http.ResponseWriter, r *http.Request) {
+				runs++
+			})
+
+			for i := 0; i < 2; i++ {
+				w := serve(h, newRequest(tt.method, "/posts/1", tt.key, ""))
+				if w.Code != tt.wantCode {
+					t.Fatalf("got status %d, want %d", w.Code, tt.wantCode)
+				}
+			}
+			if runs != tt.wantRuns {
+				t.Errorf("handler ran %d times, want %d", runs, tt.wantRuns)
+			}
+		})
+	}
+}
+
+func TestInFlight(t *testing.T) {
+	started, finish := make(chan struct{}), make(chan struct{})
+	h := newMiddleware(newMemStore()).Wrap(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-finish
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- serve(h, newRequest(http.MethodPost, "/posts", "key-1", "{}"))
+	}()
+	<-started
+
+	if w := serve(h, newRequest(http.MethodPost, "/posts", "key-1", "{}")); w.Code != http.StatusConflict {
+		t.Errorf("retry while the first runs got %d, want 409", w.Code)
+	}
+
+	close(finish)
+	if w := <-done; w.Code != http.StatusCreated {
+		t.Fatalf("first request got %d, want 201", w.Code)
+	}
+	if w := serve(h, newRequest(http.MethodPost, "/posts", "key-1", "{}")); w.Code != http.StatusCreated || w.Header().Get(ReplayedHeader) != "true" {
+		t.Errorf("retry after the first finished got %d, want a replayed 201", w.Code)
+	}
+}
+
+func TestMismatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{"other body", http.MethodPost, "/posts", `{"title":"b"}`},
+		{"other path", http.MethodPost, "/posts/1/comments", `{"title":"a"}`},
+		{"other query", http.MethodPost, "/posts?draft=true", `{"title":"a"}`},
+		{"other method", http.MethodPatch, "/posts", `{"title":"a"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runs := 0
+			h := newMiddleware(newMemStore()).Wrap(func(w http.ResponseWriter, r *http.Request) {
+				runs++
+			})
+
+			serve(h, newRequest(http.MethodPost, "/posts", "key-1", `{"title":"a"}`))
+			w := serve(h, newRequest(tt.method, tt.path, "key-1", tt.body))
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Errorf("got status %d, want 422", w.Code)
+			}
+			if runs != 1 {
+				t.Errorf("handler ran %d times, want 1", runs)
+			}
+		})
+	}
+}
+
+func TestReleaseOnServerError(t *testing.T) {
+	store := newMemStore()
+	key := Key{UserID: 1, Value: "key-1"}
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		stored  bool
+	}{
+		{"500", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}, false},
+		{"503", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}, false},
+		{"panic", func(w http.ResponseWriter, r *http.Request) {
+			panic("handler failed")
+		}, false},
+		{"4xx is kept", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store.Release(key)
+			h := newMiddleware(store).Wrap(tt.handler)
+
+			func() {
+				defer func() { recover() }()
+				serve(h, newRequest(http.MethodPost, "/posts", key.Value, "{}"))
+			}()
+
+			if store.has(key) != tt.stored {
+				t.Errorf("key stored is %v, want %v", store.has(key), tt.stored)
+			}
+		})
+	}
+
+	//released keys can be retried
+	store.Release(key)
+	runs := 0
+	h := newMiddleware(store).Wrap(func(w http.ResponseWriter, r *http.Request) {
+		runs++
+		if runs == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+	first := serve(h, newRequest(http.MethodPost, "/posts", key.Value, "{}"))
+	second := serve(h, newRequest(http.MethodPost, "/posts", key.Value, "{}"))
+	if first.Code != http.StatusInternalServerError || second.Code != http.StatusOK || runs != 2 {
+		t.Errorf("got %d then %d after %d runs, want 500 then 200 after 2", first.Code, second.Code, runs)
+	}
+	if second.Header().Get(ReplayedHeader) != "" {
+		t.Error("retry after a 5xx was replayed")
+	}
+}
+
+func TestStoredHeaders(t *testing.T) {
+	h := newMiddleware(newMemStore()).Wrap(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/users/1")
+		w.Header().Set("Authentication-Token", "secret token")
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	first := serve(h, newRequest(http.MethodPost, "/users/register", "key-1", "{}"))
+	if first.Header().Get("Authentication-Token") != "secret token" {
+		t.Fatal("the first response lost its token")
+	}
+
+	second := serve(h, newRequest(http.MethodPost, "/users/register", "key-1", "{}"))
+	if second.Header().Get(ReplayedHeader) != "true" {
+		t.Fatal("second response is not a replay")
+	}
+	for _, name := range []string{"Authentication-Token", "Set-Cookie"} {
+		if got := second.Header().Get(name); got != "" {
+			t.Errorf("replay carries %s %q", name, got)
+		}
+	}
+	if second.Header().Get("Content-Type") != "application/json" || second.Header().Get("Location") != "/users/1" {
+		t.Errorf("replay lost its content headers: %v", second.Header())
+	}
+}
+
+func TestAnonymousKeys(t *testing.T) {
+	long := strings.Repeat("k", minAnonymousKeyLength)
+
+	tests := []struct {
+		name     string
+		user     string
+		key      string
+		wantCode int
+	}{
+		{"short key of a user", "1", "key-1", http.StatusOK},
+		{"short anonymous key", "0", "key-1", http.StatusBadRequest},
+		{"anonymous key one too short", "0", long[1:], http.StatusBadRequest},
+		{"long anonymous key", "0", long, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runs := 0
+			h := newMiddleware(newMemStore()).Wrap(func(w http.ResponseWriter, r *http.Request) {
+				runs++
+			})
+
+			r := newRequest(http.MethodPost, "/users/register", tt.key, "{}")
+			r.Header.Set("X-User", tt.user)
+			if w := serve(h, r); w.Code != tt.wantCode {
+				t.Errorf("got status %d, want %d", w.Code, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusOK && runs != 0 {
+				t.Error("handler ran for a rejected key")
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+// Package logging builds the JSON loggers used by the services and carries a
+// request scoped logger (request id, route, user id) through the context.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+const redacted = "[REDACTED]"
+
+// attribute keys containing any of these are never written out
+var sensitiveKeys = []string{"password", "token", "secret", "authorization", "cookie", "jwt"}
+
+func New(w io.Writer, level *slog.LevelVar) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redact,
+	}))
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return slog.String(a.Key, redacted)
+		}
+	}
+	return a
+}
+
+type contextKey string
+
+var scopeContextKey = contextKey("logger")
+
+// logger shared by everything handling one request, fields added by inner
+// middleware (user id) also show up in the access log written by Middleware
+type scope struct {
+	mu     sync.Mutex
+	logger *slog.Logger
+}
+
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, scopeContextKey, &scope{logger: logger})
+}
+
+// request logger stored in ctx, slog.Default() outside of a request
+func FromContext(ctx context.Context) *slog.Logger {
+	s, ok := ctx.Value(scopeContextKey).(*scope)
+	if !ok {
+		return slog.Default()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logger
+}
+
+// adds fields to every later log line of the request
+func With(ctx context.Context, args ...any) {
+	s, ok := ctx.Value(scopeContextKey).(*scope)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	s.logger = s.logger.With(args...)
+	s.mu.Unlock()
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.size += n
+	return n, err
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// stores a request logger in the context and writes one access log line per
+// request, route returns the matched route template so raw ids stay out of logs
+func Middleware(base *slog.Logger, route func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			requestID := r.Header.Get(RequestIDHeader)
+			if requestID == "" {
+				requestID = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, requestID)
+
+			logger := base.With(
+				"request_id", requestID,
+				"method", r.Method,
+				"route", route(r),
+			)
+			ctx := NewContext(r.Context(), logger)
+
+			rec := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r.WithContext(ctx))
+
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+
+			level := slog.LevelInfo
+			if rec.status >= 500 {
+				level = slog.LevelError
+			}
+			FromContext(ctx).Log(ctx, level, "request completed",
+				"status", rec.status,
+				"bytes", rec.size,
+				"duration_ms", time.Since(start).Milliseconds(),
+			)
+		})
+	}
+}
+
+// GET returns the current level, PUT {"level":"debug"} changes it without a restart
+func LevelHandler(level *slog.LevelVar) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var body struct {
+				Level string `json:"level"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, "body must be {\"level\": \"debug|info|warn|error\"}", http.StatusBadRequest)
+				return
+			}
+			if err := level.UnmarshalText([]byte(body.Level)); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			slog.Info("log level changed", "level", level.Level().String())
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"level": level.Level().String()})
+	})
+}
@@ -0,0 +1,110 @@
+// Package metrics exposes Prometheus metrics for the services: HTTP request
+// counts and latencies labelled by route template, Go runtime and process
+// stats, and the /metrics handler. Services register their own collectors on
+// Registry.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// registry served by Handler, starts out with the go runtime and process collectors
+var Registry = prometheus.NewRegistry()
+
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests handled, by service, method, route template and status code.",
+	}, []string{"service", "method", "route", "status"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time spent handling HTTP requests, by service, method, route template and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"service", "method", "route", "status"})
+
+	requestsInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "HTTP requests currently being handled.",
+	}, []string{"service"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		requestsTotal,
+		requestDuration,
+		requestsInFlight,
+	)
+}
+
+// serves everything in Registry in the prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// connection pool stats of db (open, in use, idle, waits), labelled with db_name
+func RegisterDBStats(db *sql.DB, name string) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// counts and times every request, route returns the matched route template so
+// raw ids in paths dont create a new series per request
+func Middleware(service string, route func(r *http.Request) string) func(http.Handler) http.Handler {
+	inFlight := requestsInFlight.WithLabelValues(service)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			inFlight.Inc()
+			defer inFlight.Dec()
+
+			rec := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+
+			labels := prometheus.Labels{
+				"service": service,
+				"method":  r.Method,
+				"route":   route(r),
+				"status":  strconv.Itoa(rec.status),
+			}
+			requestsTotal.With(labels).Inc()
+			requestDuration.With(labels).Observe(time.Since(start).Seconds())
+		})
+	}
+}
@@ -0,0 +1,59 @@
+// Package mongometrics exports MongoDB connection pool stats through the
+// driver's pool monitor.
+package mongometrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"go.mongodb.org/mongo-driver/event"
+
+	"common/metrics"
+)
+
+var (
+	connectionsOpen = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mongodb_pool_connections_open",
+		Help: "Connections currently open to the server.",
+	}, []string{"address"})
+
+	connectionsInUse = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mongodb_pool_connections_in_use",
+		Help: "Connections currently checked out of the pool.",
+	}, []string{"address"})
+
+	checkoutFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mongodb_pool_checkout_failures_total",
+		Help: "Checkouts that failed, by reason (timeout, connectionError, poolClosed).",
+	}, []string{"address", "reason"})
+
+	poolMaxSize = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "mongodb_pool_max_size",
+		Help: "Configured maximum pool size per server.",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(connectionsOpen, connectionsInUse, checkoutFailures, poolMaxSize)
+}
+
+// monitor for options.Client().SetPoolMonitor, maxPoolSize should be the value
+// passed to SetMaxPoolSize so saturation can be read off the in use gauge
+func PoolMonitor(maxPoolSize uint64) *event.PoolMonitor {
+	poolMaxSize.Set(float64(maxPoolSize))
+
+	return &event.PoolMonitor{
+		Event: func(evt *event.PoolEvent) {
+			switch evt.Type {
+			case event.ConnectionCreated:
+				connectionsOpen.WithLabelValues(evt.Address).Inc()
+			case event.ConnectionClosed:
+				connectionsOpen.WithLabelValues(evt.Address).Dec()
+			case event.GetSucceeded:
+				connectionsInUse.WithLabelValues(evt.Address).Inc()
+			case event.ConnectionReturned:
+				connectionsInUse.WithLabelValues(evt.Address).Dec()
+			case event.GetFailed:
+				checkoutFailures.WithLabelValues(evt.Address, evt.Reason).Inc()
+			}
+		},
+	}
+}
@@ -0,0 +1,223 @@
+// Package openapi serves the OpenAPI 3 document of a service and checks
+// requests against it.
+//
+// Requests to operations in the document are validated before they reach the
+// handler, a request that doesnt match is answered with 400 and an error
+// message naming the parameter or body field. Routes the document doesnt
+// describe, like /metrics, pass through unchecked. Responses can be validated
+// too, meant for tests and staging: a response that doesnt match the document
+// is logged and replaced by a 500 so the mismatch cant go unnoticed.
+package openapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+
+	"common/logging"
+)
+
+func init() {
+	//messages name the failing field, the schema itself is in /openapi.json
+	openapi3.SchemaErrorDetailsDisabled = true
+}
+
+type Spec struct {
+	doc    *openapi3.T
+	router routers.Router
+	json   []byte
+}
+
+// parses and validates a yaml or json document
+func Load(data []byte) (*Spec, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(data)
+	if err != nil {
+		return nil, fmt.Errorf("openapi: %w", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("openapi: invalid document: %w", err)
+	}
+
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("openapi: %w", err)
+	}
+
+	js, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("openapi: %w", err)
+	}
+
+	return &Spec{doc: doc, router: router, json: js}, nil
+}
+
+// serves the document as json, for /openapi.json
+func (s *Spec) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(s.json)
+	})
+}
+
+type Options struct {
+	// also check responses, for tests and staging only since every response
+	// is buffered
+	ValidateResponses bool
+}
+
+func (s *Spec) Middleware(opts Options) func(http.Handler) http.Handler {
+	//authentication is left to the services, the document only describes it
+	filterOpts := &openapi3filter.Options{
+		AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route, pathParams, err := s.router.FindRoute(r)
+			if err != nil {
+				//not described, routing answers 404 and 405 itself
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			defaultToJSON(r, route)
+
+			input := &openapi3filter.RequestValidationInput{
+				Request:    r,
+				PathParams: pathParams,
+				Route:      route,
+				Options:    filterOpts,
+			}
+			if err := openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+				sendError(w, http.StatusBadRequest, describe(err))
+				return
+			}
+
+			if !opts.ValidateResponses {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rec := &recorder{header: http.Header{}}
+			next.ServeHTTP(rec, r)
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+
+			err = openapi3filter.ValidateResponse(r.Context(), &openapi3filter.ResponseValidationInput{
+				RequestValidationInput: input,
+				Status:                 rec.status,
+				Header:                 rec.header,
+				Body:                   io.NopCloser(bytes.NewReader(rec.body.Bytes())),
+				Options:                filterOpts,
+			})
+			if err != nil {
+				logging.FromContext(r.Context()).Error("response does not match the api specification",
+					"status", rec.status, "err", err)
+				sendError(w, http.StatusInternalServerError, "response does not match the api specification.")
+				return
+			}
+
+			for k, v := range rec.header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(rec.status)
+			w.Write(rec.body.Bytes())
+		})
+	}
+}
+
+// the services read every body that isnt a file upload as json, whatever the
+// client declared, so a body of another type is checked as json too. curl -d
+// sends application/x-www-form-urlencoded for example
+func defaultToJSON(r *http.Request, route *routers.Route) {
+	body := route.Operation.RequestBody
+	if body == nil || body.Value == nil {
+		return
+	}
+	content := body.Value.Content
+	if content.Get(r.Header.Get("Content-Type")) == nil && content.Get("application/json") != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+}
+
+// short message for a failed request validation, names the parameter or the
+// body field instead of dumping the schema
+func describe(err error) string {
+	var reqErr *openapi3filter.RequestError
+	if !errors.As(err, &reqErr) {
+		var secErr *openapi3filter.SecurityRequirementsError
+		if errors.As(err, &secErr) {
+			return "authentication required."
+		}
+		return err.Error()
+	}
+
+	reason := reqErr.Reason
+	var schemaErr *openapi3.SchemaError
+	if errors.As(reqErr.Err, &schemaErr) {
+		reason = schemaErr.Reason
+		if field := strings.Join(schemaErr.JSONPointer(), "."); field != "" {
+			reason = fmt.Sprintf("field %q: %s", field, reason)
+		}
+	} else if reason == "" && reqErr.Err != nil {
+		reason = reqErr.Err.Error()
+	}
+
+	switch {
+	case reqErr.Parameter != nil:
+		return fmt.Sprintf("invalid %s parameter %q: %s", reqErr.Parameter.In, reqErr.Parameter.Name, reason)
+	case reqErr.RequestBody != nil:
+		return "invalid request body: " + reason
+	}
+	return reason
+}
+
+// holds the response until it is validated
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.body.Write(b)
+}
+
+// same body as the error responses of the services
+func sendError(w http.ResponseWriter, statusCode int, message string) {
+	js, err := json.Marshal(map[string]string{"error": message})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	js = append(js, '\n')
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(js)
+}
@@ -0,0 +1,123 @@
+package openapi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const document = `
+openapi: 3.0.3
+info:
+  title: test
+  version: "1"
+paths:
+  /items:
+    post:
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              type: object
+              additionalProperties: false
+              properties:
+                name:
+                  type: string
+                  maxLength: 8
+      responses:
+        "200":
+          description: the item
+          headers:
+            Location:
+              schema:
+                type: string
+          content:
+            application/json:
+              schema:
+                type: object
+                required: [name]
+                properties:
+                  name:
+                    type: string
+`
+
+func load(t *testing.T) *Spec {
+	t.Helper()
+	spec, err := Load([]byte(document))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+func TestLoadRejectsInvalidDocument(t *testing.T) {
+	if _, err := Load([]byte("openapi: 3.0.3\npaths: {}\n")); err == nil {
+		t.Error("got nil, want an error for a document without info")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	spec := load(t)
+	//echoes the body it got, so the test sees it is still readable
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/items/1")
+		w.Write(body)
+	})
+
+	tests := []struct {
+		name         string
+		opts         Options
+		contentType  string
+		body         string
+		wantStatus   int
+		wantBody     string
+		wantLocation string
+	}{
+		{"valid", Options{}, "application/json", `{"name":"a"}`, http.StatusOK, `{"name":"a"}`, "/items/1"},
+		{"form body read as json", Options{}, "application/x-www-form-urlencoded", `{"name":"a"}`, http.StatusOK, `{"name":"a"}`, "/items/1"},
+		{"invalid", Options{}, "application/json", `{"name":"too long a name"}`, http.StatusBadRequest,
+			`{"error":"invalid request body: field \"name\": maximum string length is 8"}`, ""},
+		{"valid response", Options{ValidateResponses: true}, "application/json", `{"name":"a"}`, http.StatusOK, `{"name":"a"}`, "/items/1"},
+		//a name is optional in the request but not in the response
+		{"invalid response", Options{ValidateResponses: true}, "application/json", `{}`, http.StatusInternalServerError,
+			`{"error":"response does not match the api specification."}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			spec.Middleware(tt.opts)(echo).ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := strings.TrimSpace(w.Body.String()); got != tt.wantBody {
+				t.Errorf("got body %s, want %s", got, tt.wantBody)
+			}
+			if got := w.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("got Location %q, want %q", got, tt.wantLocation)
+			}
+		})
+	}
+}
+
+func TestMiddlewarePassesUndescribedRoutes(t *testing.T) {
+	spec := load(t)
+	handler := spec.Middleware(Options{ValidateResponses: true})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("metrics"))
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK || w.Body.String() != "metrics" {
+		t.Errorf("got %d %q, want the handler's response", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,105 @@
+// Package server runs the HTTP servers of the services with timeouts,
+// optional TLS and a graceful shutdown on SIGINT/SIGTERM.
+//
+// On a signal the server first reports itself as draining (OnDrain, used to
+// fail /readyz), waits DrainDelay so load balancers notice, then stops
+// accepting connections and waits up to ShutdownTimeout for requests in
+// flight to finish.
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+type Options struct {
+	Addr string
+
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+
+	// time between failing readiness and closing the listener
+	DrainDelay time.Duration
+	// upper bound for requests in flight to finish after the listener is closed
+	ShutdownTimeout time.Duration
+
+	// serve https when both are set
+	TLSCertFile string
+	TLSKeyFile  string
+
+	Logger *slog.Logger
+	// called once when a shutdown signal arrives
+	OnDrain func()
+}
+
+// serves handler until a shutdown signal arrives or the listener fails, nil
+// means the server was shut down cleanly
+func ListenAndServe(handler http.Handler, opts Options) error {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	srv := &http.Server{
+		Addr:         opts.Addr,
+		Handler:      handler,
+		ReadTimeout:  opts.ReadTimeout,
+		WriteTimeout: opts.WriteTimeout,
+		IdleTimeout:  opts.IdleTimeout,
+		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		tls := opts.TLSCertFile != "" && opts.TLSKeyFile != ""
+		logger.Info("server listening", "addr", opts.Addr, "tls", tls)
+
+		var err error
+		if tls {
+			err = srv.ListenAndServeTLS(opts.TLSCertFile, opts.TLSKeyFile)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		serveErr <- err
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+	//a second signal kills the process the usual way
+	stop()
+
+	logger.Info("shutdown signal received, draining", "drain_delay", opts.DrainDelay.String())
+	if opts.OnDrain != nil {
+		opts.OnDrain()
+	}
+	time.Sleep(opts.DrainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), opts.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error("requests still in flight at shutdown timeout", "err", err)
+		srv.Close()
+		return err
+	}
+
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	logger.Info("server stopped")
+	return nil
+}
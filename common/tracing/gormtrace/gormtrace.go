@@ -0,0 +1,85 @@
+// Package gormtrace is a gorm plugin creating a span for every statement.
+// Spans are children of the context passed to gorm with WithContext.
+package gormtrace
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const spanKey = "gormtrace:span"
+
+var tracer = otel.Tracer("common/tracing/gormtrace")
+
+type Plugin struct{}
+
+func (Plugin) Name() string {
+	return "gormtrace"
+}
+
+func (p Plugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+
+	hooks := []struct {
+		operation string
+		before    func(string, func(*gorm.DB)) error
+		after     func(string, func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register},
+		{"query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+		{"update", cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register},
+		{"delete", cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register},
+		{"row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+		{"raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register},
+	}
+
+	for _, h := range hooks {
+		if err := h.before("gormtrace:before_"+h.operation, before(db.Dialector.Name(), h.operation)); err != nil {
+			return err
+		}
+		if err := h.after("gormtrace:after_"+h.operation, after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func before(system string, operation string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		ctx, span := tracer.Start(tx.Statement.Context, "gorm."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("db.system", system),
+				attribute.String("db.operation", operation),
+				attribute.String("db.sql.table", tx.Statement.Table),
+			),
+		)
+		tx.Statement.Context = ctx
+		tx.InstanceSet(spanKey, span)
+	}
+}
+
+func after(tx *gorm.DB) {
+	v, ok := tx.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span, ok := v.(trace.Span)
+	if !ok {
+		return
+	}
+	defer span.End()
+
+	// statement text only, values stay out of the trace
+	span.SetAttributes(
+		attribute.String("db.statement", tx.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", tx.Statement.RowsAffected),
+	)
+	if tx.Error != nil && tx.Error != gorm.ErrRecordNotFound {
+		span.RecordError(tx.Error)
+		span.SetStatus(codes.Error, tx.Error.Error())
+	}
+}
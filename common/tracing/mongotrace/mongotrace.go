@@ -0,0 +1,64 @@
+// Package mongotrace creates a span for every MongoDB command through the
+// driver's command monitor. Spans are children of the context passed to the
+// collection method.
+package mongotrace
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("common/tracing/mongotrace")
+
+type monitor struct {
+	// in flight spans keyed by driver request id
+	spans sync.Map
+}
+
+func Monitor() *event.CommandMonitor {
+	m := &monitor{}
+	return &event.CommandMonitor{
+		Started:   m.started,
+		Succeeded: m.succeeded,
+		Failed:    m.failed,
+	}
+}
+
+func (m *monitor) started(ctx context.Context, evt *event.CommandStartedEvent) {
+	collection := ""
+	if v, err := evt.Command.LookupErr(evt.CommandName); err == nil {
+		collection, _ = v.StringValueOK()
+	}
+
+	_, span := tracer.Start(ctx, "mongodb."+evt.CommandName,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "mongodb"),
+			attribute.String("db.name", evt.DatabaseName),
+			attribute.String("db.operation", evt.CommandName),
+			attribute.String("db.mongodb.collection", collection),
+			attribute.String("server.address", evt.ConnectionID),
+		),
+	)
+	m.spans.Store(evt.RequestID, span)
+}
+
+func (m *monitor) succeeded(ctx context.Context, evt *event.CommandSucceededEvent) {
+	if v, ok := m.spans.LoadAndDelete(evt.RequestID); ok {
+		v.(trace.Span).End()
+	}
+}
+
+func (m *monitor) failed(ctx context.Context, evt *event.CommandFailedEvent) {
+	if v, ok := m.spans.LoadAndDelete(evt.RequestID); ok {
+		span := v.(trace.Span)
+		span.SetStatus(codes.Error, evt.Failure)
+		span.End()
+	}
+}
@@ -0,0 +1,98 @@
+// Package tracing sets up OpenTelemetry for the services: the global tracer
+// provider and propagator, HTTP server middleware and an instrumented HTTP
+// client for calls between services.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+type Options struct {
+	ServiceName string
+	// none, stdout or otlp
+	Exporter string
+	// host:port of the otlp/http collector, e.g. localhost:4318
+	Endpoint string
+	Insecure bool
+	// fraction of new traces that are sampled, 0..1
+	SampleRatio float64
+}
+
+// installs the global tracer provider, the returned func flushes and stops it
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	// propagation is set up even without an exporter so incoming trace ids are forwarded
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+
+	switch opts.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterOTLP:
+		clientOpts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(opts.Endpoint)}
+		if opts.Insecure {
+			clientOpts = append(clientOpts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, clientOpts...)
+	default:
+		return nil, fmt.Errorf("tracing: unknown exporter %q", opts.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(opts.ServiceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// server span per request named after the route template, use it after routing
+// so route can see the matched route
+func Middleware(service string, route func(r *http.Request) string) func(http.Handler) http.Handler {
+	return otelhttp.NewMiddleware(service,
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + route(r)
+		}),
+	)
+}
+
+// client for calls to other services, injects the trace context into the request
+func HTTPClient() *http.Client {
+	return &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)}
+}
@@ -0,0 +1,5 @@
+// Package userpb holds the client and server code generated from user.proto
+// for the internal user_service API.
+package userpb
+
+//go:generate protoc -I . --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative user.proto
@@ -0,0 +1,231 @@
+// Package users is the client for the internal user_service gRPC API used by
+// the other services. Every call gets a deadline, user profiles are cached for
+// a short time since usernames never change and bios rarely do.
+package users
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"common/userpb"
+)
+
+// BatchGetUsers limit of user_service
+const maxBatch = 500
+
+var (
+	ErrNotFound        = errors.New("user not found")
+	ErrUnauthenticated = errors.New("token invalid")
+)
+
+type Options struct {
+	// host:port of the user_service grpc server
+	Addr string
+	// deadline of every call
+	Timeout time.Duration
+	// how long a profile is served from the cache, 0 disables caching
+	CacheTTL time.Duration
+	// cached profiles at most
+	CacheSize int
+}
+
+type Client struct {
+	conn    *grpc.ClientConn
+	rpc     userpb.UserServiceClient
+	health  healthpb.HealthClient
+	timeout time.Duration
+	cache   *cache
+}
+
+// the connection is made lazily, an unreachable user_service fails the calls, not New
+func New(opts Options) (*Client, error) {
+	conn, err := grpc.NewClient(opts.Addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		conn:    conn,
+		rpc:     userpb.NewUserServiceClient(conn),
+		health:  healthpb.NewHealthClient(conn),
+		timeout: opts.Timeout,
+		cache:   newCache(opts.CacheTTL, opts.CacheSize),
+	}, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func translate(err error) error {
+	switch status.Code(err) {
+	case codes.NotFound:
+		return ErrNotFound
+	case codes.Unauthenticated:
+		return ErrUnauthenticated
+	}
+	return err
+}
+
+func (c *Client) GetUser(ctx context.Context, userid uint64) (*userpb.User, error) {
+	if user, ok := c.cache.get(userid); ok {
+		return user, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	user, err := c.rpc.GetUser(ctx, &userpb.GetUserRequest{Id: userid})
+	if err != nil {
+		return nil, translate(err)
+	}
+
+	c.cache.set(user)
+	return user, nil
+}
+
+// users keyed by id, ids of missing users are left out
+func (c *Client) GetUsers(ctx context.Context, userids []uint64) (map[uint64]*userpb.User, error) {
+	users := make(map[uint64]*userpb.User, len(userids))
+
+	var missing []uint64
+	seen := make(map[uint64]bool, len(userids))
+	for _, id := range userids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		if user, ok := c.cache.get(id); ok {
+			users[id] = user
+		} else {
+			missing = append(missing, id)
+		}
+	}
+
+	for len(missing) > 0 {
+		batch := missing
+		if len(batch) > maxBatch {
+			batch = batch[:maxBatch]
+		}
+		missing = missing[len(batch):]
+
+		ctx, cancel := context.WithTimeout(ctx, c.timeout)
+		resp, err := c.rpc.BatchGetUsers(ctx, &userpb.BatchGetUsersRequest{Ids: batch})
+		cancel()
+		if err != nil {
+			return nil, translate(err)
+		}
+
+		for _, user := range resp.GetUsers() {
+			c.cache.set(user)
+			users[user.GetId()] = user
+		}
+	}
+
+	return users, nil
+}
+
+// checked on every call, revoked sessions must stop working right away
+func (c *Client) VerifyToken(ctx context.Context, token string) (*userpb.VerifyTokenResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	resp, err := c.rpc.VerifyToken(ctx, &userpb.VerifyTokenRequest{Token: token})
+	if err != nil {
+		return nil, translate(err)
+	}
+	return resp, nil
+}
+
+// ids of the users userid follows
+func (c *Client) GetFollowing(ctx context.Context, userid uint64) ([]uint64, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	resp, err := c.rpc.GetFollowing(ctx, &userpb.GetFollowingRequest{UserId: userid})
+	if err != nil {
+		return nil, translate(err)
+	}
+	return resp.GetUserIds(), nil
+}
+
+// grpc health check of the user_service api, for readiness
+func (c *Client) Check(ctx context.Context) error {
+	resp, err := c.health.Check(ctx, &healthpb.HealthCheckRequest{
+		Service: userpb.UserService_ServiceDesc.ServiceName,
+	})
+	if err != nil {
+		return err
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return errors.New("user_service is " + resp.GetStatus().String())
+	}
+	return nil
+}
+
+type cacheEntry struct {
+	user    *userpb.User
+	expires time.Time
+}
+
+type cache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	size    int
+	entries map[uint64]cacheEntry
+}
+
+func newCache(ttl time.Duration, size int) *cache {
+	return &cache{ttl: ttl, size: size, entries: map[uint64]cacheEntry{}}
+}
+
+func (c *cache) get(userid uint64) (*userpb.User, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[userid]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.user, true
+}
+
+func (c *cache) set(user *userpb.User) {
+	if c.ttl <= 0 || c.size <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if len(c.entries) >= c.size {
+		for id, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, id)
+			}
+		}
+	}
+	//still full of live entries, drop an arbitrary one
+	for id := range c.entries {
+		if len(c.entries) < c.size {
+			break
+		}
+		delete(c.entries, id)
+	}
+
+	c.entries[user.GetId()] = cacheEntry{user: user, expires: now.Add(c.ttl)}
+}
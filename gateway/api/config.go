@@ -0,0 +1,102 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"common/config"
+)
+
+// Application Configuraton
+type configuration struct {
+	// path prefix=backend url, a request goes to the route with the longest
+	// matching prefix, e.g. /users=http://user_service:8080
+	Routes []string `config:"routes" default:"/users=http://localhost:8080,/posts=http://localhost:8081" validate:"required"`
+	// time a backend gets to start answering
+	UpstreamTimeout time.Duration `config:"upstream_timeout" default:"30s"`
+
+	// shared by every service, so JWT_KEY has no prefix
+	JWTkey string `config:"jwt_key" env:"JWT_KEY" secret:"true" validate:"required"`
+
+	// internal grpc api of user_service, every token is checked there once
+	UserServiceAddr    string        `config:"user_service_addr" default:"localhost:9090" validate:"required"`
+	UserServiceTimeout time.Duration `config:"user_service_timeout" default:"2s"`
+
+	// requests per second and burst per client address, 0 disables limiting
+	RateLimit      float64 `config:"rate_limit" default:"20" validate:"min=0"`
+	RateLimitBurst int     `config:"rate_limit_burst" default:"40" validate:"min=1"`
+
+	// origins browsers may call the api from, * allows any
+	CORSAllowedOrigins []string      `config:"cors_allowed_origins"`
+	CORSAllowedMethods []string      `config:"cors_allowed_methods" default:"GET,POST,PUT,DELETE"`
+	CORSAllowedHeaders []string      `config:"cors_allowed_headers" default:"Authorization,Content-Type,Idempotency-Key,X-Request-ID"`
+	CORSExposedHeaders []string      `config:"cors_exposed_headers" default:"Authentication-Token,Idempotent-Replayed,X-Request-ID"`
+	CORSMaxAge         time.Duration `config:"cors_max_age" default:"10m"`
+
+	// address the http server listens on
+	HTTPAddr        string        `config:"http_addr" default:":8000" validate:"required"`
+	ReadTimeout     time.Duration `config:"read_timeout" default:"10s"`
+	WriteTimeout    time.Duration `config:"write_timeout" default:"60s"`
+	IdleTimeout     time.Duration `config:"idle_timeout" default:"2m"`
+	DrainDelay      time.Duration `config:"drain_delay" default:"5s"`
+	ShutdownTimeout time.Duration `config:"shutdown_timeout" default:"20s"`
+	// https is served when both are set
+	TLSCertFile string `config:"tls_cert_file"`
+	TLSKeyFile  string `config:"tls_key_file"`
+
+	LogLevel string `config:"log_level" default:"info" validate:"oneof=debug info warn error"`
+
+	// per check limit for /readyz
+	HealthCheckTimeout time.Duration `config:"health_check_timeout" default:"2s"`
+
+	// none, stdout or otlp
+	TracingExporter  string  `config:"tracing_exporter" default:"none" validate:"oneof=none stdout otlp"`
+	OTLPEndpoint     string  `config:"otlp_endpoint" default:"localhost:4318"`
+	OTLPInsecure     bool    `config:"otlp_insecure" default:"true"`
+	TraceSampleRatio float64 `config:"trace_sample_ratio" default:"1" validate:"min=0,max=1"`
+}
+
+var Config = configuration{}
+
+func (c *configuration) Validate() error {
+	var errs config.Errors
+
+	if _, err := parseRoutes(c.Routes); err != nil {
+		errs = append(errs, fmt.Errorf("routes: %w", err))
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		errs = append(errs, errors.New("tls_cert_file, tls_key_file: set both or neither"))
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// merges defaults, the config file, .env/environment and flags into Config
+func LoadConfig(args []string) error {
+	//.env is optional, the environment may already be set
+	err := godotenv.Load(".env")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Error("couldnt load .env", "err", err)
+		return err
+	}
+
+	if err := config.Load(&Config, config.Options{Args: args, EnvPrefix: "GATEWAY_"}); err != nil {
+		log.Error("invalid configuration", "err", err)
+		return err
+	}
+
+	if err := logLevel.UnmarshalText([]byte(Config.LogLevel)); err != nil {
+		return err
+	}
+
+	log.Info("effective configuration", "config", config.Describe(&Config))
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package api
+
+import "net/http"
+
+func (app *application) sendErrorResponse(w http.ResponseWriter, statusCode int, message interface{}) {
+	env := envelope{"error": message}
+
+	err := app.writeJSON(w, env, statusCode)
+	if err != nil {
+		//err while sending
+		log.Error("error while sending error response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (app *application) routeNotFound(w http.ResponseWriter, r *http.Request) {
+	message := "route not available."
+	app.sendErrorResponse(w, http.StatusNotFound, message)
+}
+
+func (app *application) userServiceUnavailable(w http.ResponseWriter, r *http.Request) {
+	message := "user service unavailable, try again later."
+	app.sendErrorResponse(w, http.StatusServiceUnavailable, message)
+}
+
+func (app *application) upstreamUnavailable(w http.ResponseWriter, r *http.Request) {
+	message := "service unavailable, try again later."
+	app.sendErrorResponse(w, http.StatusBadGateway, message)
+}
+
+func (app *application) upstreamTimeout(w http.ResponseWriter, r *http.Request) {
+	message := "service took too long to respond."
+	app.sendErrorResponse(w, http.StatusGatewayTimeout, message)
+}
+
+func (app *application) rateLimitExceeded(w http.ResponseWriter, r *http.Request) {
+	message := "rate limit exceeded, slow down."
+	w.Header().Set("Retry-After", "1")
+	app.sendErrorResponse(w, http.StatusTooManyRequests, message)
+}
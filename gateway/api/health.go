@@ -0,0 +1,20 @@
+package api
+
+import (
+	"common/health"
+	"common/tracing"
+)
+
+// readiness checks for user_service, used for every token, and each backend's /readyz
+func (app *application) newHealthChecker() *health.Checker {
+	checker := health.New(Config.HealthCheckTimeout)
+
+	checker.Add("user_service", app.users.Check)
+
+	httpClient := tracing.HTTPClient()
+	for _, rt := range app.backends {
+		checker.Add("backend:"+rt.name(), health.HTTPCheck(httpClient, rt.backend.JoinPath("readyz").String()))
+	}
+
+	return checker
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"common/logging"
+)
+
+var log *slog.Logger
+
+// changed at runtime through Config.LogLevel and the log level endpoint
+var logLevel = new(slog.LevelVar)
+
+func initLogger(w io.Writer) {
+	log = logging.New(w, logLevel)
+	slog.SetDefault(log)
+}
+
+func GetLogger() *slog.Logger {
+	if log != nil {
+		return log
+	} else {
+		initLogger(os.Stdout)
+		return log
+	}
+}
+
+// cli tools keep stdout for their own output
+func UseStderrLogger() {
+	initLogger(os.Stderr)
+}
+
+// logger carrying the request id, route and user id of r
+func requestLogger(r *http.Request) *slog.Logger {
+	return logging.FromContext(r.Context())
+}
@@ -0,0 +1,15 @@
+package api
+
+import (
+	"common/auth"
+	"common/health"
+	"common/users"
+)
+
+type application struct {
+	backends []*route
+	health   *health.Checker
+	users    *users.Client
+	auth     *auth.Middleware
+	limiter  *rateLimiter
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"common/metrics"
+)
+
+// request counts and latencies by route prefix and status
+func (app *application) instrumentRequests(next http.Handler) http.Handler {
+	return metrics.Middleware(serviceName, routeLabel)(next)
+}
+
+// unmatched paths are reported under one label so scans dont grow the series count
+func routeLabel(r *http.Request) string {
+	if mux.CurrentRoute(r) == nil {
+		return "unmatched"
+	}
+	return routeTemplate(r)
+}
+
+// serves /metrics
+func MetricsHandler() http.Handler {
+	return metrics.Handler()
+}
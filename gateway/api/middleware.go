@@ -0,0 +1,111 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"common/auth"
+	"common/logging"
+	"common/users"
+)
+
+// writes the access log and gives handlers a logger with request id and route
+func (app *application) logRequests(next http.Handler) http.Handler {
+	return logging.Middleware(log, routeTemplate)(next)
+}
+
+// route prefix like /posts/, keeps raw ids out of logs and metrics
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			return tmpl
+		}
+	}
+	return r.URL.Path
+}
+
+// tokens are checked here once, the backends get a signed identity instead
+func (app *application) newAuth() *auth.Middleware {
+	return &auth.Middleware{
+		Tokens:  auth.NewTokens(Config.JWTkey, 0),
+		Check:   app.checkSession,
+		OnError: app.authenticationError,
+	}
+}
+
+func (app *application) checkSession(r *http.Request, token string, claims *auth.Claims, p *auth.Principal) (*http.Request, error) {
+	resp, err := app.users.VerifyToken(r.Context(), token)
+	if err != nil {
+		if errors.Is(err, users.ErrUnauthenticated) {
+			return r, auth.ErrTokenInvalid
+		}
+		return r, err
+	}
+
+	p.Scopes = nil
+	if resp.GetIsAdmin() {
+		p.Scopes = []string{auth.ScopeAdmin}
+	}
+	return r, nil
+}
+
+func (app *application) authenticationError(w http.ResponseWriter, r *http.Request, err error) {
+	requestLogger(r).Error("error while verifying token with user_service", "err", err)
+	app.userServiceUnavailable(w, r)
+}
+
+// clients cannot claim an identity, only the gateway signs one
+func (app *application) stripIdentity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth.StripIdentity(r.Header)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// answers preflight requests and adds the cors headers for allowed origins,
+// requests from other origins are still served, browsers just hide the response
+func (app *application) cors(next http.Handler) http.Handler {
+	allowAny := false
+	allowed := map[string]bool{}
+	for _, origin := range Config.CORSAllowedOrigins {
+		if origin == "*" {
+			allowAny = true
+		}
+		allowed[origin] = true
+	}
+	methods := strings.Join(Config.CORSAllowedMethods, ", ")
+	headers := strings.Join(Config.CORSAllowedHeaders, ", ")
+	exposed := strings.Join(Config.CORSExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(Config.CORSMaxAge.Seconds()))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+
+		origin := r.Header.Get("Origin")
+		if origin == "" || !(allowAny || allowed[origin]) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if exposed != "" {
+			w.Header().Set("Access-Control-Expose-Headers", exposed)
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			w.Header().Set("Access-Control-Allow-Methods", methods)
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+			w.Header().Set("Access-Control-Max-Age", maxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+
+	"common/auth"
+	"common/logging"
+)
+
+// one entry of Config.Routes
+type route struct {
+	prefix  string
+	backend *url.URL
+	proxy   *httputil.ReverseProxy
+}
+
+// name of the backend in health reports, /users -> users
+func (rt *route) name() string {
+	return strings.ReplaceAll(strings.Trim(rt.prefix, "/"), "/", "_")
+}
+
+// parses prefix=url pairs, longest prefix first so /posts/admin wins over /posts
+func parseRoutes(raw []string) ([]*route, error) {
+	var routes []*route
+	seen := map[string]bool{}
+
+	for _, entry := range raw {
+		prefix, target, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q: expected prefix=url", entry)
+		}
+		prefix = "/" + strings.Trim(strings.TrimSpace(prefix), "/")
+		if prefix == "/" {
+			return nil, fmt.Errorf("%q: prefix cannot be /", entry)
+		}
+		if seen[prefix] {
+			return nil, fmt.Errorf("%q: prefix %s used twice", entry, prefix)
+		}
+		seen[prefix] = true
+
+		backend, err := url.Parse(strings.TrimSpace(target))
+		if err != nil || backend.Host == "" || (backend.Scheme != "http" && backend.Scheme != "https") {
+			return nil, fmt.Errorf("%q: backend must be an http or https url", entry)
+		}
+
+		routes = append(routes, &route{prefix: prefix, backend: backend})
+	}
+
+	sort.SliceStable(routes, func(i, j int) bool {
+		return len(routes[i].prefix) > len(routes[j].prefix)
+	})
+	return routes, nil
+}
+
+func (app *application) newProxies() {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = Config.UpstreamTimeout
+	traced := otelhttp.NewTransport(transport)
+
+	for _, rt := range app.backends {
+		backend := rt.backend
+		rt.proxy = &httputil.ReverseProxy{
+			Rewrite: func(pr *httputil.ProxyRequest) {
+				pr.SetURL(backend)
+				pr.SetXForwarded()
+				//last, the signature covers the path as the backend gets it
+				app.auth.Tokens.SignIdentity(pr.Out, auth.ContextGetPrincipal(pr.In))
+			},
+			Transport:    traced,
+			ErrorHandler: app.proxyError,
+		}
+	}
+}
+
+// forwards the request with the identity of the authenticated user, signed in
+// the rewrite of the proxy. the path is passed on unchanged so the backends
+// keep their /users and /posts routes
+func (app *application) forward(rt *route) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//one id for the whole chain of log lines, the identity is signed for it
+		r.Header.Set(logging.RequestIDHeader, w.Header().Get(logging.RequestIDHeader))
+
+		rt.proxy.ServeHTTP(w, r)
+	}
+}
+
+func (app *application) proxyError(w http.ResponseWriter, r *http.Request, err error) {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		//client went away, nobody is reading the response
+		requestLogger(r).Debug("client canceled request", "err", err)
+		w.WriteHeader(499)
+	case errors.As(err, &netErr) && netErr.Timeout():
+		requestLogger(r).Error("backend timed out", "err", err)
+		app.upstreamTimeout(w, r)
+	default:
+		requestLogger(r).Error("error while proxying request", "err", err)
+		app.upstreamUnavailable(w, r)
+	}
+}
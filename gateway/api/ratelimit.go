@@ -0,0 +1,91 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// limiters of clients not seen for this long are dropped
+const limiterIdleTimeout = 3 * time.Minute
+
+// token bucket per client address
+type rateLimiter struct {
+	mu       sync.Mutex
+	limit    rate.Limit
+	burst    int
+	limiters map[string]*clientLimiter
+}
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newRateLimiter(perSecond float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		limit:    rate.Limit(perSecond),
+		burst:    burst,
+		limiters: map[string]*clientLimiter{},
+	}
+}
+
+func (l *rateLimiter) allow(client string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c, ok := l.limiters[client]
+	if !ok {
+		c = &clientLimiter{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.limiters[client] = c
+	}
+	c.lastSeen = time.Now()
+	return c.limiter.Allow()
+}
+
+// drops idle clients until done is closed
+func (l *rateLimiter) cleanup(done <-chan struct{}) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			l.mu.Lock()
+			for client, c := range l.limiters {
+				if time.Since(c.lastSeen) > limiterIdleTimeout {
+					delete(l.limiters, client)
+				}
+			}
+			l.mu.Unlock()
+		}
+	}
+}
+
+// the gateway is the edge, so the peer address is the client
+func clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// runs before authentication so floods of bad tokens dont reach user_service
+func (app *application) limitRate(next http.Handler) http.Handler {
+	if app.limiter == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.limiter.allow(clientAddr(r)) {
+			app.rateLimitExceeded(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
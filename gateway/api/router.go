@@ -0,0 +1,35 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"common/auth"
+	"common/logging"
+)
+
+func (app *application) routes() http.Handler {
+	router := mux.NewRouter()
+
+	//middleware added with Use only runs for matched routes, where the route prefix is known
+	router.Use(app.traceRequests, app.logRequests, app.instrumentRequests)
+
+	//unmatched requests still get an access log line and are counted
+	router.NotFoundHandler = app.logRequests(app.instrumentRequests(http.HandlerFunc(app.routeNotFound)))
+
+	router.Handle("/healthz", app.health.LivenessHandler()).Methods(http.MethodGet)
+	router.Handle("/readyz", app.health.ReadinessHandler()).Methods(http.MethodGet)
+	router.Handle("/metrics", MetricsHandler()).Methods(http.MethodGet)
+	router.HandleFunc("/debug/log-level", app.auth.Authenticate(app.auth.RequireAuthentication(
+		app.auth.RequireScope(auth.ScopeAdmin, logging.LevelHandler(logLevel).ServeHTTP)))).Methods(http.MethodGet, http.MethodPut)
+
+	//backends are sorted by prefix length, mux tries routes in the order they are added
+	for _, rt := range app.backends {
+		handler := app.limitRate(app.auth.Authenticate(app.forward(rt)))
+		router.Handle(rt.prefix, handler)
+		router.PathPrefix(rt.prefix + "/").Handler(handler)
+	}
+
+	return app.cors(app.stripIdentity(router))
+}
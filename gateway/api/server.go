@@ -0,0 +1,71 @@
+package api
+
+import (
+	"context"
+
+	"common/server"
+	"common/users"
+)
+
+// loads the configuration and proxies to the backends until SIGINT/SIGTERM,
+// args are the command line flags without the program name
+func Run(args []string) error {
+	GetLogger()
+
+	if err := LoadConfig(args); err != nil {
+		return err
+	}
+
+	shutdownTracing, err := SetupTracing(context.Background())
+	if err != nil {
+		log.Error("error while setting up tracing", "err", err)
+		return err
+	}
+	defer shutdownTracing(context.Background())
+
+	app := &application{}
+
+	//already validated with the rest of the configuration
+	app.backends, _ = parseRoutes(Config.Routes)
+	app.newProxies()
+	for _, rt := range app.backends {
+		log.Info("routing", "prefix", rt.prefix, "backend", rt.backend.String())
+	}
+
+	//tokens are verified on every request and never cached
+	app.users, err = users.New(users.Options{
+		Addr:    Config.UserServiceAddr,
+		Timeout: Config.UserServiceTimeout,
+	})
+	if err != nil {
+		return err
+	}
+	defer app.users.Close()
+
+	app.auth = app.newAuth()
+	app.health = app.newHealthChecker()
+
+	if Config.RateLimit > 0 {
+		app.limiter = newRateLimiter(Config.RateLimit, Config.RateLimitBurst)
+		done := make(chan struct{})
+		defer close(done)
+		go app.limiter.cleanup(done)
+	}
+
+	err = server.ListenAndServe(app.routes(), server.Options{
+		Addr:            Config.HTTPAddr,
+		ReadTimeout:     Config.ReadTimeout,
+		WriteTimeout:    Config.WriteTimeout,
+		IdleTimeout:     Config.IdleTimeout,
+		DrainDelay:      Config.DrainDelay,
+		ShutdownTimeout: Config.ShutdownTimeout,
+		TLSCertFile:     Config.TLSCertFile,
+		TLSKeyFile:      Config.TLSKeyFile,
+		Logger:          log,
+		OnDrain:         app.health.Drain,
+	})
+	if err != nil {
+		log.Error("server stopped with error", "err", err)
+	}
+	return err
+}
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"common/tracing"
+)
+
+const serviceName = "gateway"
+
+// installs the tracer provider from Config, call the returned func on shutdown
+func SetupTracing(ctx context.Context) (func(context.Context) error, error) {
+	return tracing.Setup(ctx, tracing.Options{
+		ServiceName: serviceName,
+		Exporter:    Config.TracingExporter,
+		Endpoint:    Config.OTLPEndpoint,
+		Insecure:    Config.OTLPInsecure,
+		SampleRatio: Config.TraceSampleRatio,
+	})
+}
+
+// server span for every request, named after the route prefix
+func (app *application) traceRequests(next http.Handler) http.Handler {
+	return tracing.Middleware(serviceName, routeTemplate)(next)
+}
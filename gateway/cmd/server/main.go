@@ -0,0 +1,18 @@
+// server runs the api gateway in front of user_service and post_service.
+//
+//	server [-config file] [-http-addr :8000] [-routes /users=http://...,/posts=http://...]
+//
+// every configuration option can also be set in the environment, see api/config.go
+package main
+
+import (
+	"os"
+
+	"gateway/api"
+)
+
+func main() {
+	if err := api.Run(os.Args[1:]); err != nil {
+		os.Exit(1)
+	}
+}
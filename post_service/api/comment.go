@@ -0,0 +1,273 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"post_service/internal/data"
+)
+
+// top level comments shown with a post, the rest are read through the link
+// to the next page
+const postCommentsLimit = 5
+
+func commentsListing(postid primitive.ObjectID, parent string, order string) string {
+	return "comments:" + postid.Hex() + ":" + parent + ":" + order
+}
+
+// the first page of the top level comments of the post, newest first, and the
+// link to the page after it in GetComments, nil if there is none
+func (app *application) firstComments(r *http.Request, post *data.Post) ([]data.Comment, interface{}, error) {
+	page := data.Page{Limit: postCommentsLimit}
+	comments, more, err := app.modelsFor(r).Posts.GetComments(post.ID, nil, data.CommentsNewest, page)
+	if err != nil || !more {
+		return comments, nil, err
+	}
+
+	cursor, err := encodeCursor(commentsListing(post.ID, "", data.CommentsNewest), comments[len(comments)-1].Cursor(false))
+	if err != nil {
+		return nil, nil, err
+	}
+	next := url.URL{Path: "/posts/" + post.ID.Hex() + "/comments", RawQuery: url.Values{"cursor": {cursor}}.Encode()}
+	return comments, next.RequestURI(), nil
+}
+
+// one level of the comments of a post, ?parent= for the replies to a comment
+// and ?sort=newest or top
+func (app *application) GetComments(w http.ResponseWriter, r *http.Request) {
+	post, ok := app.getVisiblePost(w, r)
+	if !ok {
+		return
+	}
+
+	order := r.URL.Query().Get("sort")
+	switch order {
+	case "":
+		order = data.CommentsNewest
+	case data.CommentsNewest, data.CommentsTop:
+	default:
+		app.sendErrorResponse(w, http.StatusBadRequest, "sort must be newest or top.")
+		return
+	}
+
+	var parent *primitive.ObjectID
+	if s := r.URL.Query().Get("parent"); s != "" {
+		parentid, err := primitive.ObjectIDFromHex(s)
+		if err != nil {
+			app.sendErrorResponse(w, http.StatusBadRequest, "invalid parent comment id.")
+			return
+		}
+		parent = &parentid
+	}
+
+	listing := commentsListing(post.ID, r.URL.Query().Get("parent"), order)
+	page, err := app.readPage(r, listing)
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	comments, more, err := app.modelsFor(r).Posts.GetComments(post.ID, parent, order, page)
+	if err != nil {
+		app.internalServerError(w, r)
+		return
+	}
+
+	var first, last data.Cursor
+	if len(comments) != 0 {
+		first, last = comments[0].Cursor(true), comments[len(comments)-1].Cursor(false)
+	}
+	next, prev, err := pageLinks(r, listing, page, len(comments), first, last, more)
+	if err != nil {
+		app.internalServerError(w, r)
+		return
+	}
+
+	commentids := make([]primitive.ObjectID, len(comments))
+	for i := range comments {
+		commentids[i] = comments[i].ID
+	}
+	mine, err := app.myReactions(r, commentids)
+	if err != nil {
+		app.internalServerError(w, r)
+		return
+	}
+
+	env := envelope{"comments": comments, "next": next, "prev": prev}
+	if mine != nil {
+		env["my_reactions"] = mine
+	}
+	app.writeJSON(w, env, http.StatusOK)
+}
+
+// a top level comment, or a reply with parent_id
+func (app *application) CreateComment(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Content  string              `json:"content"`
+		ParentID *primitive.ObjectID `json:"parent_id"`
+	}
+
+	err := app.readJSON(r, w, &input)
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	post, ok := app.getVisiblePost(w, r)
+	if !ok {
+		return
+	}
+
+	models := app.modelsFor(r)
+
+	comment := &data.Comment{
+		PostID:   post.ID,
+		ParentID: input.ParentID,
+		AuthorID: app.contextGetUserID(r),
+		Content:  input.Content,
+	}
+	if input.ParentID != nil {
+		parent, err := models.Posts.GetComment(post.ID, *input.ParentID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrNotFound):
+				app.commentNotFound(w, r)
+			default:
+				app.internalServerError(w, r)
+			}
+			return
+		}
+		if parent.Deleted {
+			app.sendErrorResponse(w, http.StatusBadRequest, "deleted comments cannot be replied to.")
+			return
+		}
+		comment.Depth = parent.Depth + 1
+	}
+	if err := comment.Validate(); err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	authorName, ok := app.authorName(w, r)
+	if !ok {
+		return
+	}
+	comment.AuthorName = authorName
+
+	if err := models.Posts.AddComment(comment); err != nil {
+		switch {
+		//deleted since it was read
+		case errors.Is(err, data.ErrNotFound):
+			app.postNotFound(w, r)
+		default:
+			requestLogger(r).Error("error while adding comment", "err", err)
+			app.internalServerError(w, r)
+		}
+		return
+	}
+
+	app.writeJSON(w, envelope{"comment": comment}, http.StatusCreated)
+}
+
+func (app *application) UpdateComment(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Content string `json:"content"`
+	}
+
+	err := app.readJSON(r, w, &input)
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	_, comment, ok := app.getComment(w, r)
+	if !ok {
+		return
+	}
+	if comment.Deleted {
+		app.commentNotFound(w, r)
+		return
+	}
+	if comment.AuthorID != app.contextGetUserID(r) {
+		app.notCommentAuthor(w, r)
+		return
+	}
+
+	comment.Content = input.Content
+	if err := comment.Validate(); err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = app.modelsFor(r).Posts.UpdateComment(&comment)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNotFound):
+			app.commentNotFound(w, r)
+		default:
+			app.internalServerError(w, r)
+		}
+		return
+	}
+
+	app.writeJSON(w, envelope{"comment": comment}, http.StatusOK)
+}
+
+// by the author of the comment or of the post, the comment stays as a
+// placeholder for its replies
+func (app *application) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	post, comment, ok := app.getComment(w, r)
+	if !ok {
+		return
+	}
+
+	userid := app.contextGetUserID(r)
+	if comment.AuthorID != userid && post.AuthorID != userid {
+		app.notCommentAuthor(w, r)
+		return
+	}
+
+	err := app.modelsFor(r).Posts.DeleteComment(&comment)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNotFound):
+			app.commentNotFound(w, r)
+		default:
+			app.internalServerError(w, r)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// the comment in the path and its post, writes the error response when the
+// post cant be seen or there is no such comment
+func (app *application) getComment(w http.ResponseWriter, r *http.Request) (data.Post, data.Comment, bool) {
+	post, ok := app.getVisiblePost(w, r)
+	if !ok {
+		return post, data.Comment{}, false
+	}
+
+	commentid, err := primitive.ObjectIDFromHex(mux.Vars(r)["commentid"])
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, "invalid comment id.")
+		return post, data.Comment{}, false
+	}
+
+	comment, err := app.modelsFor(r).Posts.GetComment(post.ID, commentid)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNotFound):
+			app.commentNotFound(w, r)
+		default:
+			app.internalServerError(w, r)
+		}
+		return post, comment, false
+	}
+	return post, comment, true
+}
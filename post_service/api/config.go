@@ -0,0 +1,141 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"common/config"
+)
+
+// Application Configuraton
+type configuration struct {
+	Host string `config:"host" default:"localhost"`
+
+	// a replica set, a single node one will do, reactions and comments are
+	// written together with their counts in transactions
+	MongoURI         string `config:"mongo_uri" default:"mongodb://localhost:27017/?replicaSet=rs0" secret:"true" validate:"required"`
+	MongoDatabase    string `config:"mongo_database" default:"blog" validate:"required"`
+	MongoMaxPoolSize uint64 `config:"mongo_max_pool_size" default:"5" validate:"min=1"`
+	MongoMinPoolSize uint64 `config:"mongo_min_pool_size" default:"1"`
+
+	// shared by every service, so JWT_KEY has no prefix
+	JWTkey string `config:"jwt_key" env:"JWT_KEY" secret:"true" validate:"required"`
+
+	// internal grpc api of user_service
+	UserServiceAddr    string        `config:"user_service_addr" default:"localhost:9090" validate:"required"`
+	UserServiceTimeout time.Duration `config:"user_service_timeout" default:"2s"`
+	UserCacheTTL       time.Duration `config:"user_cache_ttl" default:"1m"`
+	UserCacheSize      int           `config:"user_cache_size" default:"10000" validate:"min=0"`
+
+	// address the http server listens on
+	HTTPAddr        string        `config:"http_addr" default:":8081" validate:"required"`
+	ReadTimeout     time.Duration `config:"read_timeout" default:"10s"`
+	WriteTimeout    time.Duration `config:"write_timeout" default:"30s"`
+	IdleTimeout     time.Duration `config:"idle_timeout" default:"2m"`
+	DrainDelay      time.Duration `config:"drain_delay" default:"5s"`
+	ShutdownTimeout time.Duration `config:"shutdown_timeout" default:"20s"`
+	// https is served when both are set
+	TLSCertFile string `config:"tls_cert_file"`
+	TLSKeyFile  string `config:"tls_key_file"`
+
+	LogLevel string `config:"log_level" default:"info" validate:"oneof=debug info warn error"`
+
+	// check responses against openapi.yaml too, for tests and staging
+	OpenAPIValidateResponses bool `config:"openapi_validate_responses" default:"false"`
+
+	// how long responses to requests with an Idempotency-Key are replayed
+	IdempotencyTTL time.Duration `config:"idempotency_ttl" default:"24h"`
+	// a key whose request never finished is free again after this
+	IdempotencyLockTimeout time.Duration `config:"idempotency_lock_timeout" default:"1m"`
+
+	// how often posts scheduled for publishing are checked
+	PublishInterval time.Duration `config:"publish_interval" default:"30s"`
+
+	// how often the like counts are recomputed from the reactions, 0 turns
+	// it off
+	ReconcileInterval time.Duration `config:"reconcile_interval" default:"1h"`
+	// emojis posts and comments can be reacted with, they are used as field
+	// names in mongo
+	Reactions []string `config:"reactions" default:"👍,❤️,😂,🎉,😮" validate:"min=1,max=20"`
+
+	// per check limit for /readyz
+	HealthCheckTimeout time.Duration `config:"health_check_timeout" default:"2s"`
+	// health urls of services this one depends on, e.g. user_service /readyz
+	DownstreamURLs []string `config:"downstream_urls"`
+
+	// none, stdout or otlp
+	TracingExporter  string  `config:"tracing_exporter" default:"none" validate:"oneof=none stdout otlp"`
+	OTLPEndpoint     string  `config:"otlp_endpoint" default:"localhost:4318"`
+	OTLPInsecure     bool    `config:"otlp_insecure" default:"true"`
+	TraceSampleRatio float64 `config:"trace_sample_ratio" default:"1" validate:"min=0,max=1"`
+}
+
+var Config = configuration{}
+
+func (c *configuration) Validate() error {
+	var errs config.Errors
+
+	if c.MongoMinPoolSize > c.MongoMaxPoolSize {
+		errs = append(errs, errors.New("mongo_min_pool_size: cannot be larger than mongo_max_pool_size"))
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		errs = append(errs, errors.New("tls_cert_file, tls_key_file: set both or neither"))
+	}
+	if c.IdempotencyTTL <= 0 {
+		errs = append(errs, errors.New("idempotency_ttl: must be positive"))
+	}
+	if c.IdempotencyLockTimeout <= 0 {
+		errs = append(errs, errors.New("idempotency_lock_timeout: must be positive"))
+	}
+	if c.PublishInterval <= 0 {
+		errs = append(errs, errors.New("publish_interval: must be positive"))
+	}
+	if c.ReconcileInterval < 0 {
+		errs = append(errs, errors.New("reconcile_interval: cannot be negative"))
+	}
+	seen := map[string]bool{}
+	for _, reaction := range c.Reactions {
+		switch {
+		case reaction == "" || len(reaction) > 32:
+			errs = append(errs, fmt.Errorf("reactions: %q must be 1 to 32 bytes", reaction))
+		case strings.Contains(reaction, ".") || strings.HasPrefix(reaction, "$"):
+			errs = append(errs, fmt.Errorf("reactions: %q cannot contain . or start with $", reaction))
+		case seen[reaction]:
+			errs = append(errs, fmt.Errorf("reactions: %q is listed twice", reaction))
+		}
+		seen[reaction] = true
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// merges defaults, the config file, .env/environment and flags into Config
+func LoadConfig(args []string) error {
+	//.env is optional, the environment may already be set
+	err := godotenv.Load(".env")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Error("couldnt load .env", "err", err)
+		return err
+	}
+
+	if err := config.Load(&Config, config.Options{Args: args, EnvPrefix: "POST_SERVICE_"}); err != nil {
+		log.Error("invalid configuration", "err", err)
+		return err
+	}
+
+	if err := logLevel.UnmarshalText([]byte(Config.LogLevel)); err != nil {
+		return err
+	}
+
+	log.Info("effective configuration", "config", config.Describe(&Config))
+
+	return nil
+}
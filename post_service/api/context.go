@@ -1 +1,12 @@
 package api
+
+import (
+	"net/http"
+
+	"common/auth"
+)
+
+// users are kept in user_service, requests only carry the principal from the token
+func (app *application) contextGetUserID(r *http.Request) uint64 {
+	return auth.ContextGetPrincipal(r).UserID
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"post_service/internal/data"
+)
+
+// listings a cursor can belong to, a cursor of one is rejected by the others
+const (
+	listingPosts     = "posts"
+	listingByAuthor  = "by-author"
+	listingFollowing = "following"
+)
+
+var errInvalidCursor = errors.New("invalid cursor.")
+
+type cursorPayload struct {
+	Listing string `json:"s"`
+	data.Cursor
+}
+
+// base64 json and its signature, opaque to clients. signed with the token
+// key so a cursor can only come from a link this service handed out
+func encodeCursor(listing string, c data.Cursor) (string, error) {
+	js, err := json.Marshal(cursorPayload{Listing: listing, Cursor: c})
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(js)
+	return payload + "." + cursorSignature(payload), nil
+}
+
+func decodeCursor(listing string, s string) (*data.Cursor, error) {
+	payload, signature, ok := strings.Cut(s, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(cursorSignature(payload))) {
+		return nil, errInvalidCursor
+	}
+
+	js, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var c cursorPayload
+	if err := json.Unmarshal(js, &c); err != nil || c.Listing != listing {
+		return nil, errInvalidCursor
+	}
+	return &c.Cursor, nil
+}
+
+func cursorSignature(payload string) string {
+	mac := hmac.New(sha256.New, []byte(Config.JWTkey))
+	mac.Write([]byte("cursor\n" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+const maxPageSize = 100
+
+// limit and cursor of a listing request, pages are walked through the next
+// and prev links instead of an offset
+func (app *application) readPage(r *http.Request, listing string) (data.Page, error) {
+	if r.URL.Query().Has("offset") {
+		return data.Page{}, errors.New("offset is not supported, follow the next and prev links.")
+	}
+
+	limit, err := app.readIntQuery(r, "limit", 10)
+	if err != nil {
+		return data.Page{}, err
+	}
+	if limit == 0 || limit > maxPageSize {
+		return data.Page{}, fmt.Errorf("limit must be between 1 and %d.", maxPageSize)
+	}
+
+	page := data.Page{Limit: limit}
+	if s := r.URL.Query().Get("cursor"); s != "" {
+		page.Cursor, err = decodeCursor(listing, s)
+		if err != nil {
+			return data.Page{}, err
+		}
+	}
+	return page, nil
+}
+
+// posts with links to the pages before and after them
+func (app *application) writePage(w http.ResponseWriter, r *http.Request, listing string, page data.Page, posts []data.Post, more bool) {
+	var first, last data.Cursor
+	if len(posts) != 0 {
+		first, last = posts[0].Cursor(true), posts[len(posts)-1].Cursor(false)
+	}
+
+	next, prev, err := pageLinks(r, listing, page, len(posts), first, last, more)
+	if err != nil {
+		app.internalServerError(w, r)
+		return
+	}
+
+	app.writeJSON(w, envelope{"posts": posts, "next": next, "prev": prev}, http.StatusOK)
+}
+
+// links to the pages around one of n items from first to last, nil at either
+// end of the listing. more is whether the listing continues in the direction
+// the page was read
+func pageLinks(r *http.Request, listing string, page data.Page, n int, first, last data.Cursor, more bool) (interface{}, interface{}, error) {
+	if n == 0 {
+		return nil, nil, nil
+	}
+
+	hasNext, hasPrev := more, page.Cursor != nil
+	if page.Backward() {
+		hasNext, hasPrev = true, more
+	}
+
+	var next, prev interface{}
+	var err error
+	if hasNext {
+		next, err = pageLink(r, listing, last)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if hasPrev {
+		prev, err = pageLink(r, listing, first)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return next, prev, nil
+}
+
+// the request url with another cursor, other parameters like limit stay
+func pageLink(r *http.Request, listing string, c data.Cursor) (string, error) {
+	cursor, err := encodeCursor(listing, c)
+	if err != nil {
+		return "", err
+	}
+
+	u := *r.URL
+	q := u.Query()
+	q.Set("cursor", cursor)
+	u.RawQuery = q.Encode()
+	return u.RequestURI(), nil
+}
@@ -0,0 +1,235 @@
+package api
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"post_service/internal/data"
+)
+
+// cursors are signed with the token key
+func setJWTKey(t *testing.T, key string) {
+	t.Helper()
+	old := Config.JWTkey
+	Config.JWTkey = key
+	t.Cleanup(func() { Config.JWTkey = old })
+}
+
+func testCursor(before bool) data.Cursor {
+	return data.Cursor{
+		Likes:     42,
+		CreatedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		ID:        primitive.NewObjectID(),
+		Before:    before,
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	setJWTKey(t, "test key")
+
+	for _, before := range []bool{false, true} {
+		want := testCursor(before)
+		s, err := encodeCursor(listingPosts, want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.ContainsAny(s, "+/= ") {
+			t.Errorf("cursor %q is not url safe", s)
+		}
+
+		got, err := decodeCursor(listingPosts, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Likes != want.Likes || !got.CreatedAt.Equal(want.CreatedAt) || got.ID != want.ID || got.Before != want.Before {
+			t.Errorf("decoded %+v, want %+v", *got, want)
+		}
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	setJWTKey(t, "test key")
+
+	valid, err := encodeCursor(listingPosts, testCursor(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, signature, _ := strings.Cut(valid, ".")
+
+	other, err := encodeCursor(listingPosts, data.Cursor{Likes: 1000, ID: primitive.NewObjectID()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPayload, _, _ := strings.Cut(other, ".")
+
+	tampered := []byte(signature)
+	if tampered[0] == 'A' {
+		tampered[0] = 'B'
+	} else {
+		tampered[0] = 'A'
+	}
+
+	//signatures are over whatever payload, so garbage can be signed too
+	notBase64 := "!!!"
+	notJSON := "bm90IGpzb24"
+
+	tests := []struct {
+		name    string
+		listing string
+		cursor  string
+	}{
+		{"other listing", listingFollowing, valid},
+		{"tampered signature", listingPosts, payload + "." + string(tampered)},
+		{"signature of another payload", listingPosts, otherPayload + "." + signature},
+		{"no signature", listingPosts, payload},
+		{"empty signature", listingPosts, payload + "."},
+		{"empty", listingPosts, ""},
+		{"payload not base64", listingPosts, notBase64 + "." + cursorSignature(notBase64)},
+		{"payload not json", listingPosts, notJSON + "." + cursorSignature(notJSON)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeCursor(tt.listing, tt.cursor); err != errInvalidCursor {
+				t.Errorf("got %v, want errInvalidCursor", err)
+			}
+		})
+	}
+
+	t.Run("other key", func(t *testing.T) {
+		setJWTKey(t, "rotated key")
+		if _, err := decodeCursor(listingPosts, valid); err != errInvalidCursor {
+			t.Errorf("got %v, want errInvalidCursor", err)
+		}
+	})
+}
+
+func TestReadPage(t *testing.T) {
+	setJWTKey(t, "test key")
+	app := &application{}
+
+	forward, err := encodeCursor(listingPosts, testCursor(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	backward, err := encodeCursor(listingPosts, testCursor(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	byAuthor, err := encodeCursor(listingByAuthor, testCursor(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		query      url.Values
+		wantErr    bool
+		wantLimit  int64
+		wantCursor bool
+		backward   bool
+	}{
+		{"first page", url.Values{}, false, 10, false, false},
+		{"limit", url.Values{"limit": {"25"}}, false, 25, false, false},
+		{"largest limit", url.Values{"limit": {"100"}}, false, 100, false, false},
+		{"zero limit", url.Values{"limit": {"0"}}, true, 0, false, false},
+		{"limit too large", url.Values{"limit": {"101"}}, true, 0, false, false},
+		{"negative limit", url.Values{"limit": {"-1"}}, true, 0, false, false},
+		{"offset", url.Values{"offset": {"20"}}, true, 0, false, false},
+		{"empty offset", url.Values{"offset": {""}}, true, 0, false, false},
+		{"forward cursor", url.Values{"cursor": {forward}}, false, 10, true, false},
+		{"backward cursor", url.Values{"cursor": {backward}, "limit": {"5"}}, false, 5, true, true},
+		{"cursor of another listing", url.Values{"cursor": {byAuthor}}, true, 0, false, false},
+		{"tampered cursor", url.Values{"cursor": {forward + "x"}}, true, 0, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/posts?"+tt.query.Encode(), nil)
+			page, err := app.readPage(r, listingPosts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if page.Limit != tt.wantLimit {
+				t.Errorf("limit %d, want %d", page.Limit, tt.wantLimit)
+			}
+			if (page.Cursor != nil) != tt.wantCursor {
+				t.Errorf("cursor %v, want one %v", page.Cursor, tt.wantCursor)
+			}
+			if page.Backward() != tt.backward {
+				t.Errorf("backward %v, want %v", page.Backward(), tt.backward)
+			}
+		})
+	}
+}
+
+func TestPageLinks(t *testing.T) {
+	setJWTKey(t, "test key")
+
+	first, last := testCursor(true), testCursor(false)
+	forward, backward := testCursor(false), testCursor(true)
+
+	tests := []struct {
+		name     string
+		page     data.Page
+		n        int
+		more     bool
+		wantNext bool
+		wantPrev bool
+	}{
+		{"empty", data.Page{Limit: 10}, 0, false, false, false},
+		{"only page", data.Page{Limit: 10}, 3, false, false, false},
+		{"first of several", data.Page{Limit: 10}, 10, true, true, false},
+		{"middle going forward", data.Page{Limit: 10, Cursor: &forward}, 10, true, true, true},
+		{"last going forward", data.Page{Limit: 10, Cursor: &forward}, 4, false, false, true},
+		{"middle going backward", data.Page{Limit: 10, Cursor: &backward}, 10, true, true, true},
+		{"first going backward", data.Page{Limit: 10, Cursor: &backward}, 10, false, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/posts?limit=10&tag=go", nil)
+			next, prev, err := pageLinks(r, listingPosts, tt.page, tt.n, first, last, tt.more)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (next != nil) != tt.wantNext || (prev != nil) != tt.wantPrev {
+				t.Fatalf("next %v prev %v, want next %v prev %v", next, prev, tt.wantNext, tt.wantPrev)
+			}
+
+			if next != nil {
+				checkPageLink(t, next.(string), last)
+			}
+			if prev != nil {
+				checkPageLink(t, prev.(string), first)
+			}
+		})
+	}
+}
+
+// the link keeps the other parameters and its cursor decodes to want
+func checkPageLink(t *testing.T, link string, want data.Cursor) {
+	t.Helper()
+
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if u.Path != "/posts" || q.Get("limit") != "10" || q.Get("tag") != "go" {
+		t.Errorf("link %q lost the request parameters", link)
+	}
+
+	got, err := decodeCursor(listingPosts, q.Get("cursor"))
+	if err != nil {
+		t.Fatalf("link %q: %v", link, err)
+	}
+	if got.ID != want.ID || got.Before != want.Before {
+		t.Errorf("link %q points at %v before %v, want %v before %v", link, got.ID, got.Before, want.ID, want.Before)
+	}
+}
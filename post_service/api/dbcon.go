@@ -6,41 +6,56 @@ import (
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+
+	"common/metrics/mongometrics"
+	"common/tracing/mongotrace"
 )
 
 var client *mongo.Client
 
 const connection_timeout = 10 * time.Second
 
-func (app *application) ConnectMongoDB() *mongo.Client {
+// connects and pings the server so an unreachable mongodb fails startup
+// instead of the first request
+func (app *application) ConnectMongoDB() (*mongo.Client, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), connection_timeout)
 	defer cancel()
 
 	client_options := options.Client()
 
 	client_options.
-		ApplyURI("mongodb://localhost:27017").
-		SetMaxPoolSize(5).
-		SetMinPoolSize(1)
-
-	client, err := mongo.Connect(ctx, client_options)
+		ApplyURI(Config.MongoURI).
+		SetMaxPoolSize(Config.MongoMaxPoolSize).
+		SetMinPoolSize(Config.MongoMinPoolSize).
+		SetMonitor(mongotrace.Monitor()).
+		SetPoolMonitor(mongometrics.PoolMonitor(Config.MongoMaxPoolSize))
 
+	c, err := mongo.Connect(ctx, client_options)
 	if err != nil {
-		log.Error("error while connecting to mongodb: ", err)
-		return nil
+		log.Error("error while connecting to mongodb", "err", err)
+		return nil, err
+	}
+
+	if err := c.Ping(ctx, readpref.Primary()); err != nil {
+		log.Error("mongodb is not reachable", "err", err)
+		c.Disconnect(context.Background())
+		return nil, err
 	}
 
-	return client
+	client = c
+	return client, nil
 }
 
-func (app *application) DisconnectMongoDB() {
+func (app *application) DisconnectMongoDB() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	if err := client.Disconnect(ctx); err != nil {
-		log.Error("error occured while disconnecting from db: ", err)
-		panic(err)
+		log.Error("error occured while disconnecting from db", "err", err)
+		return err
 	}
 
 	log.Info("mongodb disconnected successfully")
+	return nil
 }
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"post_service/internal/data"
+)
+
+// the emojis posts and comments can be reacted with
+func (app *application) GetReactionSet(w http.ResponseWriter, r *http.Request) {
+	app.writeJSON(w, envelope{"reactions": Config.Reactions}, http.StatusOK)
+}
+
+// the reaction in the path, it has to be one of Config.Reactions
+func (app *application) readReaction(r *http.Request) (string, error) {
+	reaction := mux.Vars(r)["reaction"]
+	for _, allowed := range Config.Reactions {
+		if reaction == allowed {
+			return reaction, nil
+		}
+	}
+	return "", errors.New("unknown reaction, see /posts/reactions.")
+}
+
+func (app *application) AddPostReaction(w http.ResponseWriter, r *http.Request) {
+	post, ok := app.getVisiblePost(w, r)
+	if !ok {
+		return
+	}
+	app.addReaction(w, r, data.TargetPost, post.ID, post.ID)
+}
+
+func (app *application) RemovePostReaction(w http.ResponseWriter, r *http.Request) {
+	post, ok := app.getVisiblePost(w, r)
+	if !ok {
+		return
+	}
+	app.removeReaction(w, r, data.TargetPost, post.ID)
+}
+
+// deleted comments cant be reacted to
+func (app *application) AddCommentReaction(w http.ResponseWriter, r *http.Request) {
+	post, comment, ok := app.getComment(w, r)
+	if !ok {
+		return
+	}
+	if comment.Deleted {
+		app.commentNotFound(w, r)
+		return
+	}
+	app.addReaction(w, r, data.TargetComment, comment.ID, post.ID)
+}
+
+func (app *application) RemoveCommentReaction(w http.ResponseWriter, r *http.Request) {
+	_, comment, ok := app.getComment(w, r)
+	if !ok {
+		return
+	}
+	app.removeReaction(w, r, data.TargetComment, comment.ID)
+}
+
+func (app *application) addReaction(w http.ResponseWriter, r *http.Request, targetType string, targetid primitive.ObjectID, postid primitive.ObjectID) {
+	emoji, err := app.readReaction(r)
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userName, ok := app.authorName(w, r)
+	if !ok {
+		return
+	}
+
+	reaction := &data.EmojiReaction{
+		TargetType: targetType,
+		TargetID:   targetid,
+		PostID:     postid,
+		UserID:     app.contextGetUserID(r),
+		UserName:   userName,
+		Emoji:      emoji,
+	}
+	err = app.modelsFor(r).Posts.AddEmojiReaction(reaction)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrAlreadyReacted):
+			app.alreadyReactedWith(w, r)
+		//deleted since it was read
+		case errors.Is(err, data.ErrNotFound) && targetType == data.TargetComment:
+			app.commentNotFound(w, r)
+		case errors.Is(err, data.ErrNotFound):
+			app.postNotFound(w, r)
+		default:
+			requestLogger(r).Error("error while adding reaction", "err", err)
+			app.internalServerError(w, r)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (app *application) removeReaction(w http.ResponseWriter, r *http.Request, targetType string, targetid primitive.ObjectID) {
+	emoji, err := app.readReaction(r)
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = app.modelsFor(r).Posts.RemoveEmojiReaction(targetType, targetid, app.contextGetUserID(r), emoji)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNotFound):
+			app.reactionNotFound(w, r)
+		default:
+			requestLogger(r).Error("error while removing reaction", "err", err)
+			app.internalServerError(w, r)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (app *application) GetPostReactionUsers(w http.ResponseWriter, r *http.Request) {
+	post, ok := app.getVisiblePost(w, r)
+	if !ok {
+		return
+	}
+	app.reactionUsers(w, r, post.ID)
+}
+
+func (app *application) GetCommentReactionUsers(w http.ResponseWriter, r *http.Request) {
+	_, comment, ok := app.getComment(w, r)
+	if !ok {
+		return
+	}
+	app.reactionUsers(w, r, comment.ID)
+}
+
+// who reacted to the target with the emoji in the path, most recent first
+func (app *application) reactionUsers(w http.ResponseWriter, r *http.Request, targetid primitive.ObjectID) {
+	emoji, err := app.readReaction(r)
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	listing := "reactions:" + targetid.Hex() + ":" + emoji
+	page, err := app.readPage(r, listing)
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	reactions, more, err := app.modelsFor(r).Posts.GetEmojiReactions(targetid, emoji, page)
+	if err != nil {
+		app.internalServerError(w, r)
+		return
+	}
+
+	var first, last data.Cursor
+	if len(reactions) != 0 {
+		first, last = reactions[0].Cursor(true), reactions[len(reactions)-1].Cursor(false)
+	}
+	next, prev, err := pageLinks(r, listing, page, len(reactions), first, last, more)
+	if err != nil {
+		app.internalServerError(w, r)
+		return
+	}
+
+	app.writeJSON(w, envelope{"users": reactions, "next": next, "prev": prev}, http.StatusOK)
+}
+
+// likes, dislikes and emojis of the requesting user on each of the targets,
+// keyed by id. nil when nobody is logged in
+func (app *application) myReactions(r *http.Request, targetids []primitive.ObjectID) (map[string][]string, error) {
+	userid := app.contextGetUserID(r)
+	if userid == 0 {
+		return nil, nil
+	}
+
+	mine, err := app.modelsFor(r).Posts.GetUserReactions(userid, targetids)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string][]string, len(targetids))
+	for _, targetid := range targetids {
+		byID[targetid.Hex()] = append([]string{}, mine[targetid]...)
+	}
+	return byID, nil
+}
@@ -0,0 +1,99 @@
+package api
+
+import "net/http"
+
+func (app *application) sendErrorResponse(w http.ResponseWriter, statusCode int, message interface{}) {
+	env := envelope{"error": message}
+
+	err := app.writeJSON(w, env, statusCode)
+	if err != nil {
+		//err while sending
+		log.Error("error while sending error response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (app *application) internalServerError(w http.ResponseWriter, r *http.Request) {
+	message := "server error"
+	app.sendErrorResponse(w, http.StatusInternalServerError, message)
+}
+
+func (app *application) authenticationRequired(w http.ResponseWriter, r *http.Request) {
+	message := "authentication required."
+	app.sendErrorResponse(w, http.StatusUnauthorized, message)
+}
+
+func (app *application) routeNotFound(w http.ResponseWriter, r *http.Request) {
+	message := "route not available."
+	app.sendErrorResponse(w, http.StatusNotFound, message)
+}
+
+func (app *application) methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	message := "method not allowed on this route."
+	app.sendErrorResponse(w, http.StatusMethodNotAllowed, message)
+}
+
+func (app *application) postNotFound(w http.ResponseWriter, r *http.Request) {
+	message := "post not found."
+	app.sendErrorResponse(w, http.StatusNotFound, message)
+}
+
+func (app *application) revisionNotFound(w http.ResponseWriter, r *http.Request) {
+	message := "revision not found."
+	app.sendErrorResponse(w, http.StatusNotFound, message)
+}
+
+func (app *application) commentNotFound(w http.ResponseWriter, r *http.Request) {
+	message := "comment not found."
+	app.sendErrorResponse(w, http.StatusNotFound, message)
+}
+
+func (app *application) notCommentAuthor(w http.ResponseWriter, r *http.Request) {
+	message := "only the author can change this comment."
+	app.sendErrorResponse(w, http.StatusForbidden, message)
+}
+
+func (app *application) notPostAuthor(w http.ResponseWriter, r *http.Request) {
+	message := "only the author can change this post."
+	app.sendErrorResponse(w, http.StatusForbidden, message)
+}
+
+func (app *application) postTitleExists(w http.ResponseWriter, r *http.Request) {
+	message := "a post with this title exists."
+	app.sendErrorResponse(w, http.StatusConflict, message)
+}
+
+func (app *application) postChanged(w http.ResponseWriter, r *http.Request) {
+	message := "the post changed meanwhile, reload it and try again."
+	app.sendErrorResponse(w, http.StatusConflict, message)
+}
+
+func (app *application) alreadyReacted(w http.ResponseWriter, r *http.Request) {
+	message := "you already reacted to this post this way."
+	app.sendErrorResponse(w, http.StatusConflict, message)
+}
+
+func (app *application) readingListNotFound(w http.ResponseWriter, r *http.Request) {
+	message := "reading list not found."
+	app.sendErrorResponse(w, http.StatusNotFound, message)
+}
+
+func (app *application) notListOwner(w http.ResponseWriter, r *http.Request) {
+	message := "only the owner can change this reading list."
+	app.sendErrorResponse(w, http.StatusForbidden, message)
+}
+
+func (app *application) alreadyReactedWith(w http.ResponseWriter, r *http.Request) {
+	message := "you already reacted with this emoji."
+	app.sendErrorResponse(w, http.StatusConflict, message)
+}
+
+func (app *application) reactionNotFound(w http.ResponseWriter, r *http.Request) {
+	message := "you have not reacted with this emoji."
+	app.sendErrorResponse(w, http.StatusNotFound, message)
+}
+
+func (app *application) userServiceUnavailable(w http.ResponseWriter, r *http.Request) {
+	message := "user service unavailable, try again later."
+	app.sendErrorResponse(w, http.StatusServiceUnavailable, message)
+}
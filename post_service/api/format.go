@@ -0,0 +1,46 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"post_service/internal/data"
+)
+
+// how the content of a single post is returned, ?format=
+const (
+	formatMarkdown = "markdown"
+	formatHTML     = "html"
+	formatBoth     = "both"
+)
+
+// markdown when not given, what clients got before there was html
+func readContentFormat(r *http.Request) (string, error) {
+	switch format := r.URL.Query().Get("format"); format {
+	case "":
+		return formatMarkdown, nil
+	case formatMarkdown, formatHTML, formatBoth:
+		return format, nil
+	default:
+		return "", errors.New("format must be markdown, html or both.")
+	}
+}
+
+// leaves only the content in the requested format on the post. posts saved
+// before there was html are rendered here
+func formatContent(post *data.Post, format string) error {
+	if format == formatMarkdown {
+		post.ContentHTML = ""
+		return nil
+	}
+
+	if post.ContentHTML == "" && post.Content != "" {
+		if err := post.RenderContent(); err != nil {
+			return err
+		}
+	}
+	if format == formatHTML {
+		post.Content = ""
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"context"
+	"net/url"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+
+	"common/health"
+	"common/tracing"
+	"common/users"
+)
+
+// readiness checks for mongodb, user_service and the services listed in downstream_urls
+func NewHealthChecker(client *mongo.Client, userClient *users.Client) (*health.Checker, error) {
+	checker := health.New(Config.HealthCheckTimeout)
+
+	checker.Add("mongodb", func(ctx context.Context) error {
+		return client.Ping(ctx, readpref.Primary())
+	})
+	checker.Add("user_service", userClient.Check)
+
+	httpClient := tracing.HTTPClient()
+	for _, raw := range Config.DownstreamURLs {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		checker.Add("downstream:"+u.Host, health.HTTPCheck(httpClient, raw))
+	}
+
+	return checker, nil
+}
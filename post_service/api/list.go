@@ -0,0 +1,332 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"post_service/internal/data"
+)
+
+// named reading lists of ?user_id=, the requesting user by default. private
+// lists are only listed for their owner
+func (app *application) GetReadingLists(w http.ResponseWriter, r *http.Request) {
+	caller := app.contextGetUserID(r)
+
+	userid := caller
+	if s := r.URL.Query().Get("user_id"); s != "" {
+		var err error
+		userid, err = strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			app.sendErrorResponse(w, http.StatusBadRequest, "invalid user_id.")
+			return
+		}
+	}
+	if userid == 0 {
+		app.authenticationRequired(w, r)
+		return
+	}
+
+	lists, err := app.modelsFor(r).Posts.GetReadingLists(userid, userid == caller)
+	if err != nil {
+		app.internalServerError(w, r)
+		return
+	}
+
+	app.writeJSON(w, envelope{"lists": lists}, http.StatusOK)
+}
+
+func (app *application) CreateReadingList(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name   string `json:"name"`
+		Public bool   `json:"public"`
+	}
+
+	err := app.readJSON(r, w, &input)
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	list := &data.ReadingList{
+		UserID: app.contextGetUserID(r),
+		Name:   input.Name,
+		Public: input.Public,
+	}
+	if err := list.Validate(); err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = app.modelsFor(r).Posts.AddReadingList(list)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrListNameExists), errors.Is(err, data.ErrTooManyLists):
+			app.sendErrorResponse(w, http.StatusConflict, err.Error())
+		default:
+			requestLogger(r).Error("error while adding reading list", "err", err)
+			app.internalServerError(w, r)
+		}
+		return
+	}
+
+	app.writeJSON(w, envelope{"list": list}, http.StatusCreated)
+}
+
+// the list with the metadata of its posts in order
+func (app *application) GetReadingList(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.getReadingList(w, r, false)
+	if !ok {
+		return
+	}
+	app.writeReadingList(w, r, list)
+}
+
+// name and public, both are replaced
+func (app *application) UpdateReadingList(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name   string `json:"name"`
+		Public bool   `json:"public"`
+	}
+
+	err := app.readJSON(r, w, &input)
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	list, ok := app.getReadingList(w, r, true)
+	if !ok {
+		return
+	}
+
+	list.Name, list.Public = input.Name, input.Public
+	if err := list.Validate(); err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = app.modelsFor(r).Posts.UpdateReadingList(&list)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNotFound):
+			app.readingListNotFound(w, r)
+		case errors.Is(err, data.ErrListNameExists):
+			app.sendErrorResponse(w, http.StatusConflict, err.Error())
+		default:
+			app.internalServerError(w, r)
+		}
+		return
+	}
+
+	app.writeJSON(w, envelope{"list": list}, http.StatusOK)
+}
+
+func (app *application) DeleteReadingList(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.getReadingList(w, r, true)
+	if !ok {
+		return
+	}
+
+	err := app.modelsFor(r).Posts.DeleteReadingList(list.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNotFound):
+			app.readingListNotFound(w, r)
+		default:
+			app.internalServerError(w, r)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (app *application) AddToReadingList(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.getReadingList(w, r, true)
+	if !ok {
+		return
+	}
+	app.addToList(w, r, list.ID)
+}
+
+func (app *application) RemoveFromReadingList(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.getReadingList(w, r, true)
+	if !ok {
+		return
+	}
+	app.removeFromList(w, r, list.ID)
+}
+
+func (app *application) ReorderReadingList(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.getReadingList(w, r, true)
+	if !ok {
+		return
+	}
+	app.reorderList(w, r, list.ID)
+}
+
+func (app *application) GetBookmarks(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.getBookmarks(w, r)
+	if !ok {
+		return
+	}
+	app.writeReadingList(w, r, list)
+}
+
+func (app *application) AddBookmark(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.getBookmarks(w, r)
+	if !ok {
+		return
+	}
+	app.addToList(w, r, list.ID)
+}
+
+func (app *application) RemoveBookmark(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.getBookmarks(w, r)
+	if !ok {
+		return
+	}
+	app.removeFromList(w, r, list.ID)
+}
+
+func (app *application) ReorderBookmarks(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.getBookmarks(w, r)
+	if !ok {
+		return
+	}
+	app.reorderList(w, r, list.ID)
+}
+
+// only posts the user can see can be saved
+func (app *application) addToList(w http.ResponseWriter, r *http.Request, listid primitive.ObjectID) {
+	post, ok := app.getVisiblePost(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.modelsFor(r).Posts.AddToReadingList(listid, post.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNotFound):
+			app.readingListNotFound(w, r)
+		case errors.Is(err, data.ErrAlreadyInList), errors.Is(err, data.ErrListFull):
+			app.sendErrorResponse(w, http.StatusConflict, err.Error())
+		default:
+			requestLogger(r).Error("error while adding to reading list", "err", err)
+			app.internalServerError(w, r)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// the post may be gone or hidden by now, so it is not looked up
+func (app *application) removeFromList(w http.ResponseWriter, r *http.Request, listid primitive.ObjectID) {
+	postid, err := app.readPostID(r)
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = app.modelsFor(r).Posts.RemoveFromReadingList(listid, postid)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNotFound):
+			app.sendErrorResponse(w, http.StatusNotFound, "post is not in the list.")
+		default:
+			app.internalServerError(w, r)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// body is the post ids to move to the front, in their new order
+func (app *application) reorderList(w http.ResponseWriter, r *http.Request, listid primitive.ObjectID) {
+	var input struct {
+		PostIDs []primitive.ObjectID `json:"post_ids"`
+	}
+
+	err := app.readJSON(r, w, &input)
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = app.modelsFor(r).Posts.ReorderReadingList(listid, input.PostIDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNotFound):
+			app.readingListNotFound(w, r)
+		case errors.Is(err, data.ErrNotInList):
+			app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, data.ErrListChanged):
+			app.sendErrorResponse(w, http.StatusConflict, err.Error())
+		default:
+			app.internalServerError(w, r)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (app *application) writeReadingList(w http.ResponseWriter, r *http.Request, list data.ReadingList) {
+	posts, err := app.modelsFor(r).Posts.GetReadingListPosts(&list, app.contextGetUserID(r))
+	if err != nil {
+		app.internalServerError(w, r)
+		return
+	}
+
+	app.writeJSON(w, envelope{"list": list, "posts": posts}, http.StatusOK)
+}
+
+func (app *application) getBookmarks(w http.ResponseWriter, r *http.Request) (data.ReadingList, bool) {
+	list, err := app.modelsFor(r).Posts.GetBookmarks(app.contextGetUserID(r))
+	if err != nil {
+		requestLogger(r).Error("error while getting bookmarks", "err", err)
+		app.internalServerError(w, r)
+		return list, false
+	}
+	return list, true
+}
+
+// the list in the path, writes the error response when there is none the
+// user can see. own is for changes, which only the owner may make
+func (app *application) getReadingList(w http.ResponseWriter, r *http.Request, own bool) (data.ReadingList, bool) {
+	listid, err := primitive.ObjectIDFromHex(mux.Vars(r)["listid"])
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, "invalid list id.")
+		return data.ReadingList{}, false
+	}
+
+	list, err := app.modelsFor(r).Posts.GetReadingList(listid)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNotFound):
+			app.readingListNotFound(w, r)
+		default:
+			app.internalServerError(w, r)
+		}
+		return list, false
+	}
+
+	userid := app.contextGetUserID(r)
+	if list.UserID != userid {
+		if !list.Public {
+			app.readingListNotFound(w, r)
+			return list, false
+		}
+		if own {
+			app.notListOwner(w, r)
+			return list, false
+		}
+	}
+	return list, true
+}
@@ -1,29 +1,39 @@
 package api
 
 import (
+	"io"
+	"log/slog"
+	"net/http"
 	"os"
 
-	"github.com/op/go-logging"
+	"common/logging"
 )
 
-var log *logging.Logger
+var log *slog.Logger
 
-func initLogger() {
-	log = logging.MustGetLogger("atlas_logger")
-	var format = logging.MustStringFormatter(
-		`%{color}%{time:15:04:05} %{shortfunc} ▶ %{level:.4s} %{id:03x}%{color:reset} %{message}`)
+// changed at runtime through Config.LogLevel and the log level endpoint
+var logLevel = new(slog.LevelVar)
 
-	var backend = logging.NewLogBackend(os.Stdout, "", 0)
-	backendFormatter := logging.NewBackendFormatter(backend, format)
-
-	logging.SetBackend(backendFormatter)
+func initLogger(w io.Writer) {
+	log = logging.New(w, logLevel)
+	slog.SetDefault(log)
 }
 
-func GetLogger() *logging.Logger {
+func GetLogger() *slog.Logger {
 	if log != nil {
 		return log
 	} else {
-		initLogger()
+		initLogger(os.Stdout)
 		return log
 	}
 }
+
+// cli tools keep stdout for their own output
+func UseStderrLogger() {
+	initLogger(os.Stderr)
+}
+
+// logger carrying the request id, route and user id of r
+func requestLogger(r *http.Request) *slog.Logger {
+	return logging.FromContext(r.Context())
+}
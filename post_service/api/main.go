@@ -1,10 +1,20 @@
 package api
 
-import "post_service/internal/data"
+import (
+	"common/auth"
+	"common/health"
+	"common/idempotency"
+	"common/openapi"
+	"common/users"
+	"post_service/internal/data"
+)
 
 type application struct {
-	models *data.Models
-}
+	models data.Models
+	health *health.Checker
+	spec   *openapi.Spec
+	users  *users.Client
+	auth   *auth.Middleware
 
-func main() {
+	idempotency *idempotency.Middleware
 }
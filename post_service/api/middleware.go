@@ -0,0 +1,80 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"common/auth"
+	"common/idempotency"
+	"common/logging"
+	"common/users"
+)
+
+// writes the access log and gives handlers a logger with request id and route
+func (app *application) logRequests(next http.Handler) http.Handler {
+	return logging.Middleware(log, routeTemplate)(next)
+}
+
+// matched route template like /posts/{postid}, keeps raw ids out of logs and metrics
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			return tmpl
+		}
+	}
+	return r.URL.Path
+}
+
+// token checks shared with user_service, revoked sessions and admin rights are
+// only known to user_service so every token is also verified there
+func (app *application) newAuth() *auth.Middleware {
+	return &auth.Middleware{
+		Tokens:  auth.NewTokens(Config.JWTkey, 0),
+		Check:   app.checkSession,
+		OnError: app.authenticationError,
+	}
+}
+
+func (app *application) checkSession(r *http.Request, token string, claims *auth.Claims, p *auth.Principal) (*http.Request, error) {
+	if claims == nil {
+		//identity signed by the gateway after the same call
+		return r, nil
+	}
+
+	resp, err := app.users.VerifyToken(r.Context(), token)
+	if err != nil {
+		if errors.Is(err, users.ErrUnauthenticated) {
+			return r, auth.ErrTokenInvalid
+		}
+		return r, err
+	}
+
+	p.Scopes = nil
+	if resp.GetIsAdmin() {
+		p.Scopes = []string{auth.ScopeAdmin}
+	}
+	return r, nil
+}
+
+func (app *application) authenticationError(w http.ResponseWriter, r *http.Request, err error) {
+	requestLogger(r).Error("error while verifying token with user_service", "err", err)
+	app.userServiceUnavailable(w, r)
+}
+
+// every idempotent route is authenticated, keys belong to the user
+func (app *application) newIdempotency() *idempotency.Middleware {
+	return &idempotency.Middleware{
+		Store: func(r *http.Request) idempotency.Store {
+			return app.modelsFor(r).IdempotencyKeys
+		},
+		UserID:      app.contextGetUserID,
+		TTL:         Config.IdempotencyTTL,
+		LockTimeout: Config.IdempotencyLockTimeout,
+		OnError: func(w http.ResponseWriter, r *http.Request, err error) {
+			requestLogger(r).Error("error while checking the idempotency key", "err", err)
+			app.internalServerError(w, r)
+		},
+	}
+}
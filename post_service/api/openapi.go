@@ -0,0 +1,24 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+
+	"common/openapi"
+)
+
+// the api contract, served at /openapi.json
+//
+//go:embed openapi.yaml
+var openapiDocument []byte
+
+func LoadOpenAPI() (*openapi.Spec, error) {
+	return openapi.Load(openapiDocument)
+}
+
+// rejects requests that dont match openapi.yaml with 400
+func (app *application) validateRequests(next http.Handler) http.Handler {
+	return app.spec.Middleware(openapi.Options{
+		ValidateResponses: Config.OpenAPIValidateResponses,
+	})(next)
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"common/openapi"
+)
+
+func loadSpec(t *testing.T) *openapi.Spec {
+	t.Helper()
+	spec, err := LoadOpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+// stands in for the handlers, it answers with a status no handler uses
+var reached = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusTeapot)
+})
+
+func TestValidateRequests(t *testing.T) {
+	validate := loadSpec(t).Middleware(openapi.Options{})(reached)
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		wantStatus int
+		wantError  string
+	}{
+		{"valid", http.MethodGet, "/posts?limit=5", "", http.StatusTeapot, ""},
+		{"limit too small", http.MethodGet, "/posts?limit=0", "", http.StatusBadRequest,
+			`invalid query parameter "limit": number must be at least 1`},
+		{"limit not a number", http.MethodGet, "/posts?limit=abc", "", http.StatusBadRequest,
+			`invalid query parameter "limit": value abc: an invalid integer`},
+		{"missing query", http.MethodGet, "/posts/search", "", http.StatusBadRequest,
+			`invalid query parameter "q": value is required but missing`},
+		{"bad post id", http.MethodGet, "/posts/zzz", "", http.StatusBadRequest,
+			`invalid path parameter "postid": string doesn't match the regular expression`},
+		{"unknown format", http.MethodPost, "/posts?format=pdf", `{"title":"t","content":"c"}`, http.StatusBadRequest,
+			`invalid query parameter "format": value is not one of the allowed values`},
+		{"missing field", http.MethodPost, "/posts", `{"title":"t"}`, http.StatusBadRequest,
+			`invalid request body: field "content": property "content" is missing`},
+		{"bad enum", http.MethodPost, "/posts", `{"title":"t","content":"c","status":"gone"}`, http.StatusBadRequest,
+			`invalid request body: field "status": value is not one of the allowed values`},
+		{"unknown field", http.MethodPost, "/posts", `{"title":"t","content":"c","extra":1}`, http.StatusBadRequest,
+			`invalid request body: property "extra" is unsupported`},
+		{"not json", http.MethodPost, "/posts", `title=t`, http.StatusBadRequest,
+			`invalid request body: failed to decode request body`},
+		{"bad array item", http.MethodDelete, "/posts", `["nope"]`, http.StatusBadRequest,
+			`invalid request body: field "0": string doesn't match the regular expression`},
+		{"undescribed", http.MethodGet, "/metrics", "", http.StatusTeapot, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			validate.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantError == "" {
+				return
+			}
+			var body struct {
+				Error string `json:"error"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(body.Error, tt.wantError) {
+				t.Errorf("got %q, want an error starting with %q", body.Error, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestValidateResponses(t *testing.T) {
+	spec := loadSpec(t)
+	app := &application{}
+	reactions := Config.Reactions
+	Config.Reactions = []string{"👍", "🎉"}
+	defer func() { Config.Reactions = reactions }()
+
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		wantStatus int
+		wantBody   string
+	}{
+		{"handler", app.GetReactionSet, http.StatusOK, `{"reactions":["👍","🎉"]}`},
+		{"wrong type", func(w http.ResponseWriter, r *http.Request) {
+			app.writeJSON(w, envelope{"reactions": 2}, http.StatusOK)
+		}, http.StatusInternalServerError, "response does not match the api specification."},
+		{"missing field", func(w http.ResponseWriter, r *http.Request) {
+			app.writeJSON(w, envelope{}, http.StatusOK)
+		}, http.StatusInternalServerError, "response does not match the api specification."},
+		{"error", func(w http.ResponseWriter, r *http.Request) {
+			app.sendErrorResponse(w, http.StatusServiceUnavailable, "try later.")
+		}, http.StatusServiceUnavailable, `{"error":"try later."}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validate := spec.Middleware(openapi.Options{ValidateResponses: true})(tt.handler)
+
+			r := httptest.NewRequest(http.MethodGet, "/posts/reactions", nil)
+			w := httptest.NewRecorder()
+			validate.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("got %s, want %s", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
@@ -1,505 +1,532 @@
 package api
 
 import (
-	"encoding/json"
-	"fmt"
-	"io"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
-	//"strconv"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 
-	"github.com/golang-jwt/jwt"
-	"github.com/gorilla/mux"
-	"github.com/suv-900/blog/models"
+	"common/users"
+	"post_service/internal/data"
 )
 
-func GetPostsByAuthorID(w http.ResponseWriter, r *http.Request) {
-	offsetString := r.URL.Query().Get("offset")
-	limitString := r.URL.Query().Get("limit")
-	authoridString := r.URL.Query().Get("authorid")
-
-	authorid, err := strconv.ParseUint(authoridString, 10, 16)
-	if err != nil {
-		serverError(&w, err)
-		return
-	}
-	offset, err := strconv.ParseUint(offsetString, 10, 16)
+// authors see all of their own posts, everyone else the listed ones
+func (app *application) GetPostsByAuthorID(w http.ResponseWriter, r *http.Request) {
+	authorid, err := strconv.ParseUint(r.URL.Query().Get("authorid"), 10, 64)
 	if err != nil {
-		serverError(&w, err)
+		app.sendErrorResponse(w, http.StatusBadRequest, "invalid authorid.")
 		return
 	}
-	limit, err := strconv.ParseUint(limitString, 10, 16)
+
+	page, err := app.readPage(r, listingByAuthor)
 	if err != nil {
-		serverError(&w, err)
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	posts := models.GetPostsByAuthorID(authorid, limit, offset)
-	for i := 0; i < len(posts); i++ {
-		posts[i].Createdat_str = posts[i].Createdat.Local().Format(time.RFC822)
-	}
-	parsedRes, err := json.Marshal(posts)
+	all := authorid == app.contextGetUserID(r)
+	posts, more, err := app.modelsFor(r).Posts.GetPostsByAuthorID(authorid, all, page)
 	if err != nil {
-		serverError(&w, err)
+		app.internalServerError(w, r)
 		return
 	}
 
-	w.WriteHeader(200)
-	w.Write(parsedRes)
+	app.writePage(w, r, listingByAuthor, page, posts, more)
 }
 
-func CreatePost(w http.ResponseWriter, r *http.Request) {
-
-	tokenExpired, authorid, tokenInvalid := AuthenticateTokenAndSendUserID(r)
-	if tokenExpired || tokenInvalid {
-		w.WriteHeader(401)
+// posts of the authors the requesting user follows
+func (app *application) GetFollowingPosts(w http.ResponseWriter, r *http.Request) {
+	page, err := app.readPage(r, listingFollowing)
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	rbyte, err := io.ReadAll(r.Body)
+	following, err := app.users.GetFollowing(r.Context(), app.contextGetUserID(r))
 	if err != nil {
-		serverError(&w, err)
+		requestLogger(r).Error("error while getting followed users", "err", err)
+		app.userServiceUnavailable(w, r)
 		return
 	}
-	var post models.Posts
-	err = json.Unmarshal(rbyte, &post)
+
+	posts, more, err := app.modelsFor(r).Posts.GetPostsByAuthorIDs(following, page)
 	if err != nil {
-		serverError(&w, err)
-		return
-	}
-	if post.Post_content == "" || post.Post_title == "" {
-		w.WriteHeader(400)
+		app.internalServerError(w, r)
 		return
 	}
-	author_name := models.GetUsername(authorid)
-	post.Author_id = authorid
-	post.Author_name = author_name
 
-	fmt.Println(post)
+	app.writePage(w, r, listingFollowing, page, posts, more)
+}
+
+func (app *application) CreatePost(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Title    string   `json:"title"`
+		Content  string   `json:"content"`
+		Tags     []string `json:"tags"`
+		Category string   `json:"category"`
+		// published when not given
+		Status     string    `json:"status"`
+		Visibility string    `json:"visibility"`
+		PublishAt  time.Time `json:"publish_at"`
+	}
 
-	postid, err := models.CreatePost(post)
+	format, err := readContentFormat(r)
 	if err != nil {
-		serverError(&w, err)
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	parsedres, err := json.Marshal(postid)
+	err = app.readJSON(r, w, &input)
 	if err != nil {
-		serverError(&w, err)
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	w.WriteHeader(200)
-	w.Write(parsedres)
 
-}
-func UpdatePostTitle(w http.ResponseWriter, r *http.Request) {
-	tokenExpired, _, tokenInvalid := AuthenticateTokenAndSendUserID(r)
-	if tokenExpired || tokenInvalid {
-		w.WriteHeader(401)
-		return
+	post := &data.Post{
+		AuthorID:   app.contextGetUserID(r),
+		Title:      input.Title,
+		Content:    input.Content,
+		Visibility: input.Visibility,
 	}
-	postidString := r.URL.Query().Get("postid")
-	if postidString == "" {
-		w.WriteHeader(400)
-		return
+	if post.Visibility == "" {
+		post.Visibility = data.VisibilityPublic
 	}
-
-	postid, err := strconv.ParseUint(postidString, 10, 16)
-	if err != nil {
-		serverError(&w, err)
+	switch input.Status {
+	case "", data.StatusPublished:
+		post.Publish(input.PublishAt)
+	case data.StatusScheduled:
+		if !input.PublishAt.After(time.Now()) {
+			app.sendErrorResponse(w, http.StatusBadRequest, "a scheduled post needs a publish_at in the future.")
+			return
+		}
+		post.Publish(input.PublishAt)
+	case data.StatusDraft:
+		if !input.PublishAt.IsZero() {
+			app.sendErrorResponse(w, http.StatusBadRequest, "publish_at is set when the draft is published.")
+			return
+		}
+		post.Status = data.StatusDraft
+	default:
+		app.sendErrorResponse(w, http.StatusBadRequest, "status must be draft, scheduled or published.")
 		return
 	}
-
-	rbyte, err := io.ReadAll(r.Body)
+	post.Tags, err = data.NormalizeTags(input.Tags)
 	if err != nil {
-		serverError(&w, err)
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	var postTitle string
-	err = json.Unmarshal(rbyte, &postTitle)
-	if err != nil {
-		serverError(&w, err)
-		return
+	if input.Category != "" {
+		post.Category, err = data.NormalizeTag(input.Category)
+		if err != nil {
+			app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
 	}
-
-	postTitleExists, err := models.CheckPostTitleExists(postTitle)
-	if err != nil {
-		serverError(&w, err)
+	if err := post.Validate(); err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	if postTitleExists {
-		w.WriteHeader(409)
+
+	//usernames never change, so the name is stored with the post
+	authorName, ok := app.authorName(w, r)
+	if !ok {
 		return
 	}
+	post.AuthorName = authorName
 
-	err = models.UpdatePostTitle(postid, postTitle)
-	if err != nil {
-		serverError(&w, err)
+	if err := app.modelsFor(r).Posts.AddPost(post); err != nil {
+		requestLogger(r).Error("error while adding post", "err", err)
+		app.internalServerError(w, r)
 		return
 	}
 
-	w.WriteHeader(200)
+	//rendered by AddPost, nothing can fail here
+	_ = formatContent(post, format)
+
+	app.writeJSON(w, envelope{"post": post}, http.StatusCreated)
 }
 
-func UpdatePostContent(w http.ResponseWriter, r *http.Request) {
-	tokenExpired, _, tokenInvalid := AuthenticateTokenAndSendUserID(r)
-	if tokenExpired || tokenInvalid {
-		w.WriteHeader(401)
-		return
-	}
-	postidString := r.URL.Query().Get("postid")
-	if postidString == "" {
-		w.WriteHeader(400)
-		return
+// username of the requesting user from user_service, writes the error
+// response when it cant be had
+func (app *application) authorName(w http.ResponseWriter, r *http.Request) (string, bool) {
+	author, err := app.users.GetUser(r.Context(), app.contextGetUserID(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, users.ErrNotFound):
+			app.authenticationRequired(w, r)
+		default:
+			requestLogger(r).Error("error while looking up author", "err", err)
+			app.userServiceUnavailable(w, r)
+		}
+		return "", false
 	}
+	return author.GetUsername(), true
+}
 
-	postid, err := strconv.ParseUint(postidString, 10, 16)
+// loads the post for a change, only its author may make
+func (app *application) getOwnPost(w http.ResponseWriter, r *http.Request) (data.Post, bool) {
+	postid, err := app.readPostID(r)
 	if err != nil {
-		serverError(&w, err)
-		return
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return data.Post{}, false
 	}
 
-	rbyte, err := io.ReadAll(r.Body)
-	if err != nil {
-		serverError(&w, err)
-		return
-	}
-	var postContent string
-	err = json.Unmarshal(rbyte, &postContent)
+	post, err := app.modelsFor(r).Posts.GetPost(postid)
 	if err != nil {
-		serverError(&w, err)
-		return
+		switch {
+		case errors.Is(err, data.ErrNotFound):
+			app.postNotFound(w, r)
+		default:
+			app.internalServerError(w, r)
+		}
+		return post, false
 	}
 
-	err = models.UpdatePostContent(postid, postContent)
-	if err != nil {
-		serverError(&w, err)
-		return
+	if post.AuthorID != app.contextGetUserID(r) {
+		app.notPostAuthor(w, r)
+		return post, false
 	}
 
-	w.WriteHeader(200)
+	return post, true
 }
-func DeletePosts(w http.ResponseWriter, r *http.Request) {
-	tokenExpired, _, tokenInvalid := AuthenticateTokenAndSendUserID(r)
-	if tokenExpired || tokenInvalid {
-		w.WriteHeader(401)
-		return
+
+// saves a post loaded with getOwnPost, writes the error response when that
+// fails
+func (app *application) updatePost(w http.ResponseWriter, r *http.Request, post *data.Post) bool {
+	err := app.modelsFor(r).Posts.UpdatePost(post)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.postChanged(w, r)
+		case errors.Is(err, data.ErrNotFound):
+			app.postNotFound(w, r)
+		default:
+			requestLogger(r).Error("error while updating post", "err", err)
+			app.internalServerError(w, r)
+		}
+		return false
 	}
+	return true
+}
 
-	rbyte, err := io.ReadAll(r.Body)
+// loads the post for reading, posts the caller may not see are not found
+// rather than forbidden
+func (app *application) getVisiblePost(w http.ResponseWriter, r *http.Request) (data.Post, bool) {
+	postid, err := app.readPostID(r)
 	if err != nil {
-		serverError(&w, err)
-		return
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return data.Post{}, false
 	}
-	var posts []models.Posts
-	err = json.Unmarshal(rbyte, &posts)
+
+	post, err := app.modelsFor(r).Posts.GetPost(postid)
 	if err != nil {
-		serverError(&w, err)
-		return
+		switch {
+		case errors.Is(err, data.ErrNotFound):
+			app.postNotFound(w, r)
+		default:
+			app.internalServerError(w, r)
+		}
+		return post, false
 	}
 
-	err = models.DeletePosts(posts)
-	if err != nil {
-		serverError(&w, err)
-		return
+	if !post.VisibleTo(app.contextGetUserID(r)) {
+		app.postNotFound(w, r)
+		return post, false
 	}
 
-	w.WriteHeader(200)
+	return post, true
 }
-func GetPostsMetaData(w http.ResponseWriter, r *http.Request) {
-	offsetString := r.URL.Query().Get("offset")
-	limitString := r.URL.Query().Get("limit")
 
-	offset, err := strconv.ParseUint(offsetString, 10, 16)
-	if err != nil {
-		serverError(&w, err)
-		return
+func (app *application) UpdatePostTitle(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Title string `json:"title"`
 	}
-	limit, err := strconv.ParseUint(limitString, 10, 16)
+
+	err := app.readJSON(r, w, &input)
 	if err != nil {
-		serverError(&w, err)
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	posts := models.GetPostsMetaData(offset, limit)
 
-	response, err := json.Marshal(posts)
-	if err != nil {
-		serverError(&w, err)
+	post, ok := app.getOwnPost(w, r)
+	if !ok {
 		return
 	}
-	w.WriteHeader(200)
-	w.Write(response)
-}
-
-func GetAllPostsMetaData(w http.ResponseWriter, r *http.Request) {
 
-	posts := models.GetAllPostsMetaData()
-
-	response, err := json.Marshal(posts)
+	postTitleExists, err := app.modelsFor(r).Posts.CheckPostTitleExists(input.Title)
 	if err != nil {
-		serverError(&w, err)
+		app.internalServerError(w, r)
+		return
+	}
+	if postTitleExists {
+		app.postTitleExists(w, r)
 		return
 	}
-	w.WriteHeader(200)
-	w.Write(response)
 
-}
-func GetFeaturedPosts(w http.ResponseWriter, r *http.Request) {
-	var offset uint64
-	offsetString := r.URL.Query().Get("offset")
-	offset, err := strconv.ParseUint(offsetString, 10, 16)
-	if err != nil {
-		serverError(&w, err)
+	post.Title = input.Title
+	if err := post.Validate(); err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	postMetaData := models.GetFeaturedPosts(offset)
-	response, err := json.Marshal(postMetaData)
-	if err != nil {
-		serverError(&w, err)
+	if !app.updatePost(w, r, &post) {
 		return
 	}
-	w.WriteHeader(200)
-	w.Write(response)
+
+	w.WriteHeader(http.StatusOK)
 }
 
-// sends post username and top 5 comments
-func GetPostByID(w http.ResponseWriter, r *http.Request) {
-	postidString := r.URL.Query().Get("postid")
-	postid, err := strconv.ParseUint(postidString, 10, 16)
-	if err != nil {
-		serverError(&w, err)
-		return
+func (app *application) UpdatePostContent(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Content string `json:"content"`
 	}
 
-	post, err := models.PostById(postid)
+	err := app.readJSON(r, w, &input)
 	if err != nil {
-		serverError(&w, err)
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	year, month, day := post.Createdat.Date()
-	post.Createdat_str = fmt.Sprintf("%d-%d-%d", day, month, year)
 
-	comments, err := models.GetComments(postid, 5, 0)
-	if err != nil {
-		serverError(&w, err)
+	post, ok := app.getOwnPost(w, r)
+	if !ok {
 		return
 	}
-	for i := 0; i < len(comments); i++ {
-		comments[i].Createdat_str = comments[i].Createdat.Local().Format(time.RFC822)
-	}
-	finalRes := models.PostandComments{Post: post, Comments: comments}
-	parsedRes, err := json.Marshal(finalRes)
-	if err != nil {
-		serverError(&w, err)
+
+	post.Content = input.Content
+	if err := post.Validate(); err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	w.WriteHeader(200)
-	w.Write(parsedRes)
+	if !app.updatePost(w, r, &post) {
+		return
+	}
 
+	w.WriteHeader(http.StatusOK)
 }
-func GetPostByID_WithUserPreferences(w http.ResponseWriter, r *http.Request) {
 
-	var userid uint64
-	tokenExpired, userid, tokenInvalid := AuthenticateTokenAndSendUserID(r)
-	if tokenExpired {
-		w.WriteHeader(401)
+func (app *application) DeletePost(w http.ResponseWriter, r *http.Request) {
+	post, ok := app.getOwnPost(w, r)
+	if !ok {
 		return
 	}
-	if tokenInvalid {
-		w.WriteHeader(400)
+
+	if err := app.modelsFor(r).Posts.DeletePost(post.ID); err != nil {
+		app.internalServerError(w, r)
 		return
 	}
 
-	postidString := r.URL.Query().Get("postid")
-	postid, err := strconv.ParseUint(postidString, 10, 16)
+	w.WriteHeader(http.StatusOK)
+}
+
+// body is a list of post ids, posts of other authors are skipped
+func (app *application) DeletePosts(w http.ResponseWriter, r *http.Request) {
+	var postids []primitive.ObjectID
+
+	err := app.readJSON(r, w, &postids)
 	if err != nil {
-		serverError(&w, err)
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	post, err := models.PostById(postid)
+	userid := app.contextGetUserID(r)
+	models := app.modelsFor(r)
+
+	deleted := []primitive.ObjectID{}
+	for _, postid := range postids {
+		post, err := models.Posts.GetPost(postid)
+		if errors.Is(err, data.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			app.internalServerError(w, r)
+			return
+		}
+		if post.AuthorID != userid {
+			continue
+		}
+
+		err = models.Posts.DeletePost(postid)
+		if err != nil && !errors.Is(err, data.ErrNotFound) {
+			app.internalServerError(w, r)
+			return
+		}
+		deleted = append(deleted, postid)
+	}
+
+	app.writeJSON(w, envelope{"deleted": deleted}, http.StatusOK)
+}
+
+func (app *application) GetPostsMetaData(w http.ResponseWriter, r *http.Request) {
+	page, err := app.readPage(r, listingPosts)
 	if err != nil {
-		serverError(&w, err)
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	year, month, day := post.Createdat.Date()
-	post.Createdat_str = fmt.Sprintf("%d-%d-%d", day, month, year)
 
-	userLikedPost, userDislikedPost, err := models.CheckUserReaction(userid, postid)
+	posts, more, err := app.modelsFor(r).Posts.GetPostsMetaData(page)
 	if err != nil {
-		serverError(&w, err)
+		app.internalServerError(w, r)
 		return
 	}
 
-	comments := models.GetUserCommentReaction(postid, userid)
-	for i := 0; i < len(comments); i++ {
-		comments[i].Createdat_str = comments[i].Createdat.Format(time.RFC1123)
-	}
-	// if err != nil {
-	// 	serverError(&w, err)
-	// 	return
-	// }
+	app.writePage(w, r, listingPosts, page, posts, more)
+}
 
-	finalResult := &models.PostComments_WithUserPreference{
-		Post:               post,
-		PostLikedByUser:    userLikedPost,
-		PostDislikedByUser: userDislikedPost,
-		Comments:           comments,
+func (app *application) GetFeaturedPosts(w http.ResponseWriter, r *http.Request) {
+	offset, err := app.readIntQuery(r, "offset", 0)
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	var jsonReply []byte
-	jsonReply, err = json.Marshal(finalResult)
+	posts, err := app.modelsFor(r).Posts.GetFeaturedPosts(offset)
 	if err != nil {
-		serverError(&w, err)
+		app.internalServerError(w, r)
 		return
 	}
 
-	w.WriteHeader(200)
-	w.Write(jsonReply)
-
+	app.writeJSON(w, envelope{"posts": posts}, http.StatusOK)
 }
-func LikePost(w http.ResponseWriter, r *http.Request) {
-	var postid uint64
 
-	tokenExpired, userid, tokenInvalid := AuthenticateTokenAndSendUserID(r)
-	if tokenExpired {
-		w.WriteHeader(400)
+func (app *application) GetPostByID(w http.ResponseWriter, r *http.Request) {
+	format, err := readContentFormat(r)
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	if tokenInvalid {
-		w.WriteHeader(401)
+
+	post, ok := app.getVisiblePost(w, r)
+	if !ok {
 		return
 	}
 
-	vars := mux.Vars(r)
-	postidstr := vars["postid"]
-	postid, err := strconv.ParseUint(postidstr, 10, 64)
+	comments, commentsNext, err := app.firstComments(r, &post)
 	if err != nil {
-		serverError(&w, err)
+		app.internalServerError(w, r)
 		return
 	}
 
-	//save user prefrence
-	err = models.LikePost(postid, userid)
+	targetids := []primitive.ObjectID{post.ID}
+	for i := range comments {
+		targetids = append(targetids, comments[i].ID)
+	}
+	mine, err := app.myReactions(r, targetids)
 	if err != nil {
-		serverError(&w, err)
+		app.internalServerError(w, r)
 		return
 	}
-	w.WriteHeader(200)
-}
 
-func DislikePost(w http.ResponseWriter, r *http.Request) {
-	tokenExpired, userid, tokenInvalid := AuthenticateTokenAndSendUserID(r)
-	if tokenExpired {
-		w.WriteHeader(401)
-	}
-	if tokenInvalid {
-		w.WriteHeader(400)
+	if err := formatContent(&post, format); err != nil {
+		app.internalServerError(w, r)
 		return
 	}
 
-	var postid uint64
-	vars := mux.Vars(r)
-	postidstr := vars["postid"]
-	postid, err := strconv.ParseUint(postidstr, 10, 64)
-	if err != nil {
-		serverError(&w, err)
-		return
+	env := envelope{"post": post, "comments": comments, "comments_next": commentsNext}
+	if mine != nil {
+		env["my_reactions"] = mine[post.ID.Hex()]
+		delete(mine, post.ID.Hex())
+		env["my_comment_reactions"] = mine
 	}
+	app.writeJSON(w, env, http.StatusOK)
+}
 
-	//save user prefrence
-	models.DislikePost(postid, userid)
+// post with the reaction of the requesting user
+func (app *application) GetPostByID_WithUserPreferences(w http.ResponseWriter, r *http.Request) {
+	format, err := readContentFormat(r)
 	if err != nil {
-		serverError(&w, err)
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	w.WriteHeader(200)
-}
-
-func RemoveLikeFromPost(w http.ResponseWriter, r *http.Request) {
-	var postid uint64
-	var err error
 
-	tokenExpired, userid, tokenInvalid := AuthenticateTokenAndSendUserID(r)
-	if tokenExpired {
-		w.WriteHeader(401)
-	}
-	if tokenInvalid {
-		w.WriteHeader(400)
+	post, ok := app.getVisiblePost(w, r)
+	if !ok {
 		return
 	}
-	vars := mux.Vars(r)
-	postidstr := vars["postid"]
-	postid, err = strconv.ParseUint(postidstr, 10, 64)
+
+	comments, commentsNext, err := app.firstComments(r, &post)
 	if err != nil {
-		serverError(&w, err)
+		app.internalServerError(w, r)
 		return
 	}
 
-	err = models.RemoveLikeFromPost(postid, userid)
+	targetids := []primitive.ObjectID{post.ID}
+	for i := range comments {
+		targetids = append(targetids, comments[i].ID)
+	}
+	mine, err := app.myReactions(r, targetids)
 	if err != nil {
-		serverError(&w, err)
+		app.internalServerError(w, r)
 		return
 	}
-	w.WriteHeader(200)
-}
-func RemoveDislikeFromPost(w http.ResponseWriter, r *http.Request) {
-	var postid uint64
+	myPostReactions := mine[post.ID.Hex()]
+	delete(mine, post.ID.Hex())
 
-	tokenExpired, userid, tokenInvalid := AuthenticateTokenAndSendUserID(r)
-	if tokenExpired {
-		w.WriteHeader(401)
+	var userLikedPost, userDislikedPost bool
+	for _, reaction := range myPostReactions {
+		switch reaction {
+		case data.ReactionLike:
+			userLikedPost = true
+		case data.ReactionDislike:
+			userDislikedPost = true
+		}
 	}
-	if tokenInvalid {
-		w.WriteHeader(400)
+
+	if err := formatContent(&post, format); err != nil {
+		app.internalServerError(w, r)
 		return
 	}
 
-	vars := mux.Vars(r)
-	postidstr := vars["postid"]
-	postid, err := strconv.ParseUint(postidstr, 10, 64)
+	app.writeJSON(w, envelope{
+		"post":                  post,
+		"post_liked_by_user":    userLikedPost,
+		"post_disliked_by_user": userDislikedPost,
+		"my_reactions":          myPostReactions,
+		"comments":              comments,
+		"comments_next":         commentsNext,
+		"my_comment_reactions":  mine,
+	}, http.StatusOK)
+}
+
+// runs one of the reaction changes for the post in the path and the requesting user
+func (app *application) changeReaction(w http.ResponseWriter, r *http.Request, change func(postid primitive.ObjectID, userid uint64) error) {
+	postid, err := app.readPostID(r)
 	if err != nil {
-		serverError(&w, err)
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	err = models.RemoveDislikeFromPost(postid, userid)
+	err = change(postid, app.contextGetUserID(r))
 	if err != nil {
-		serverError(&w, err)
+		switch {
+		case errors.Is(err, data.ErrNotFound):
+			app.postNotFound(w, r)
+		case errors.Is(err, data.ErrAlreadyReacted):
+			app.alreadyReacted(w, r)
+		default:
+			requestLogger(r).Error("error while changing reaction", "err", err)
+			app.internalServerError(w, r)
+		}
 		return
 	}
-	w.WriteHeader(200)
+
+	w.WriteHeader(http.StatusOK)
 }
-func TokenVerifier(s string, r *http.Request) (bool, *CustomPayload) {
-	t := GetCookieByName(r.Cookies(), s)
-	if t == "" {
-		fmt.Println("no cookie got.")
-		return false, nil
-	}
-	token, err := jwt.ParseWithClaims(t, &CustomPayload{}, nil)
-	if err != nil {
-		fmt.Println(err)
-		return false, nil
-	}
-	if p, ok := token.Claims.(*CustomPayload); ok && token.Valid {
-		return true, p
-	} else {
-		fmt.Println("Token not ok!")
-		return false, nil
-	}
+
+func (app *application) LikePost(w http.ResponseWriter, r *http.Request) {
+	app.changeReaction(w, r, app.modelsFor(r).Posts.LikePost)
 }
 
-func GetCookieByName(cookies []*http.Cookie, cookiename string) string {
-	result := ""
-	for i := 0; i < len(cookies); i++ {
-		if cookies[i].Name == cookiename {
-			result += cookies[i].Value
-			break
-		}
-	}
-	return result
+func (app *application) DislikePost(w http.ResponseWriter, r *http.Request) {
+	app.changeReaction(w, r, app.modelsFor(r).Posts.DislikePost)
+}
+
+func (app *application) RemoveLikeFromPost(w http.ResponseWriter, r *http.Request) {
+	app.changeReaction(w, r, app.modelsFor(r).Posts.RemoveLikeFromPost)
+}
+
+func (app *application) RemoveDislikeFromPost(w http.ResponseWriter, r *http.Request) {
+	app.changeReaction(w, r, app.modelsFor(r).Posts.RemoveDislikeFromPost)
 }
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"post_service/internal/data"
+)
+
+// publishes the post now, or schedules it with a publish_at in the future.
+// the body is optional
+func (app *application) PublishPost(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		PublishAt time.Time `json:"publish_at"`
+	}
+
+	if r.ContentLength != 0 {
+		err := app.readJSON(r, w, &input)
+		if err != nil {
+			app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	post, ok := app.getOwnPost(w, r)
+	if !ok {
+		return
+	}
+
+	//publishing a published post again keeps its publish time
+	if post.Status != data.StatusPublished || !input.PublishAt.IsZero() {
+		post.Publish(input.PublishAt)
+		if !app.updatePost(w, r, &post) {
+			return
+		}
+	}
+
+	app.writeJSON(w, envelope{"status": post.Status, "publish_at": post.PublishAt}, http.StatusOK)
+}
+
+// takes the post out of listings and links, publishing brings it back
+func (app *application) ArchivePost(w http.ResponseWriter, r *http.Request) {
+	post, ok := app.getOwnPost(w, r)
+	if !ok {
+		return
+	}
+
+	post.Status = data.StatusArchived
+	if !app.updatePost(w, r, &post) {
+		return
+	}
+
+	app.writeJSON(w, envelope{"status": post.Status}, http.StatusOK)
+}
+
+func (app *application) UpdatePostVisibility(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Visibility string `json:"visibility"`
+	}
+
+	err := app.readJSON(r, w, &input)
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	post, ok := app.getOwnPost(w, r)
+	if !ok {
+		return
+	}
+
+	post.Visibility = input.Visibility
+	if err := post.Validate(); err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !app.updatePost(w, r, &post) {
+		return
+	}
+
+	app.writeJSON(w, envelope{"visibility": post.Visibility}, http.StatusOK)
+}
+
+// publishes scheduled posts once they are due, until done is closed. every
+// replica runs it, publishing a post twice changes nothing
+func (app *application) publishScheduledPosts(done <-chan struct{}) {
+	ticker := time.NewTicker(Config.PublishInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			n, err := app.models.Posts.PublishScheduled(now)
+			if err != nil {
+				log.Error("error while publishing scheduled posts", "err", err)
+				continue
+			}
+			if n != 0 {
+				log.Info("published scheduled posts", "posts", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+	"time"
+)
+
+// admin only, fixes like counts that drifted from the reactions right away
+// instead of at the next scheduled run
+func (app *application) ReconcileReactions(w http.ResponseWriter, r *http.Request) {
+	fixed, err := app.modelsFor(r).Posts.ReconcileReactionCounts()
+	if err != nil {
+		requestLogger(r).Error("error while reconciling reaction counts", "err", err)
+		app.internalServerError(w, r)
+		return
+	}
+	requestLogger(r).Info("reaction counts reconciled", "posts", fixed)
+
+	app.writeJSON(w, envelope{"fixed": fixed}, http.StatusOK)
+}
+
+// recomputes like counts every ReconcileInterval until done is closed
+func (app *application) reconcileReactionCounts(done <-chan struct{}) {
+	ticker := time.NewTicker(Config.ReconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			fixed, err := app.models.Posts.ReconcileReactionCounts()
+			if err != nil {
+				log.Error("error while reconciling reaction counts", "err", err)
+				continue
+			}
+			if fixed != 0 {
+				log.Warn("fixed like counts that were off", "posts", fixed)
+			}
+		}
+	}
+}
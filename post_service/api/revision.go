@@ -0,0 +1,174 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"post_service/internal/data"
+	"post_service/internal/diff"
+)
+
+// how /posts/{postid}/revisions/diff shows the change of the content
+const (
+	diffUnified = "unified"
+	diffWords   = "words"
+)
+
+// revisions of the post, newest first. only for its author, like edits
+func (app *application) GetPostRevisions(w http.ResponseWriter, r *http.Request) {
+	post, ok := app.getOwnPost(w, r)
+	if !ok {
+		return
+	}
+
+	listing := "revisions:" + post.ID.Hex()
+	page, err := app.readPage(r, listing)
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	revisions, more, err := app.modelsFor(r).Posts.GetRevisions(post.ID, page)
+	if err != nil {
+		app.internalServerError(w, r)
+		return
+	}
+
+	var first, last data.Cursor
+	if len(revisions) != 0 {
+		first, last = revisions[0].Cursor(true), revisions[len(revisions)-1].Cursor(false)
+	}
+	next, prev, err := pageLinks(r, listing, page, len(revisions), first, last, more)
+	if err != nil {
+		app.internalServerError(w, r)
+		return
+	}
+
+	app.writeJSON(w, envelope{"revisions": revisions, "next": next, "prev": prev}, http.StatusOK)
+}
+
+func (app *application) GetPostRevision(w http.ResponseWriter, r *http.Request) {
+	post, ok := app.getOwnPost(w, r)
+	if !ok {
+		return
+	}
+
+	revision, ok := app.getRevision(w, r, post.ID, mux.Vars(r)["revisionid"])
+	if !ok {
+		return
+	}
+
+	app.writeJSON(w, envelope{"revision": revision}, http.StatusOK)
+}
+
+// ?from=&to= revision ids, mode unified for a line diff or words for the
+// changed words
+func (app *application) DiffPostRevisions(w http.ResponseWriter, r *http.Request) {
+	mode := r.URL.Query().Get("mode")
+	switch mode {
+	case "":
+		mode = diffUnified
+	case diffUnified, diffWords:
+	default:
+		app.sendErrorResponse(w, http.StatusBadRequest, "mode must be unified or words.")
+		return
+	}
+
+	post, ok := app.getOwnPost(w, r)
+	if !ok {
+		return
+	}
+
+	from, ok := app.getRevision(w, r, post.ID, r.URL.Query().Get("from"))
+	if !ok {
+		return
+	}
+	to, ok := app.getRevision(w, r, post.ID, r.URL.Query().Get("to"))
+	if !ok {
+		return
+	}
+
+	result := envelope{"from": from.ID, "to": to.ID, "mode": mode}
+	if from.Title != to.Title {
+		result["title"] = diff.Words(from.Title, to.Title)
+	}
+	switch mode {
+	case diffUnified:
+		unified, err := diff.Unified(from.Content, to.Content, "revision "+from.ID.Hex(), "revision "+to.ID.Hex())
+		if err != nil {
+			app.internalServerError(w, r)
+			return
+		}
+		result["content"] = unified
+	case diffWords:
+		result["content"] = diff.Words(from.Content, to.Content)
+	}
+
+	app.writeJSON(w, envelope{"diff": result}, http.StatusOK)
+}
+
+// makes the revision's title and content the current ones, the revert is an
+// edit like any other and gets a revision of its own
+func (app *application) RevertPost(w http.ResponseWriter, r *http.Request) {
+	post, ok := app.getOwnPost(w, r)
+	if !ok {
+		return
+	}
+
+	revision, ok := app.getRevision(w, r, post.ID, mux.Vars(r)["revisionid"])
+	if !ok {
+		return
+	}
+
+	models := app.modelsFor(r)
+
+	if revision.Title != post.Title {
+		postTitleExists, err := models.Posts.CheckPostTitleExists(revision.Title)
+		if err != nil {
+			app.internalServerError(w, r)
+			return
+		}
+		if postTitleExists {
+			app.postTitleExists(w, r)
+			return
+		}
+	}
+
+	post.Title, post.Content = revision.Title, revision.Content
+	if err := post.Validate(); err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !app.updatePost(w, r, &post) {
+		return
+	}
+
+	//markdown, like the edits take it
+	post.ContentHTML = ""
+	app.writeJSON(w, envelope{"post": post}, http.StatusOK)
+}
+
+// revision id of the post, writes the error response when there is none
+func (app *application) getRevision(w http.ResponseWriter, r *http.Request, postid primitive.ObjectID, id string) (data.Revision, bool) {
+	revisionid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, "invalid revision id.")
+		return data.Revision{}, false
+	}
+
+	revision, err := app.modelsFor(r).Posts.GetRevision(postid, revisionid)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNotFound):
+			app.revisionNotFound(w, r)
+		default:
+			app.internalServerError(w, r)
+		}
+		return revision, false
+	}
+	return revision, true
+}
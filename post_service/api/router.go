@@ -1 +1,110 @@
 package api
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"common/auth"
+	"common/logging"
+)
+
+// authenticated user required
+func (app *application) authenticated(next http.HandlerFunc) http.HandlerFunc {
+	return app.auth.Authenticate(app.auth.RequireAuthentication(next))
+}
+
+// replays the stored response of a request retried with the same
+// Idempotency-Key, runs after authentication since keys are per user
+func (app *application) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return app.idempotency.Wrap(next)
+}
+
+// the requesting user when a token is sent, anonymous otherwise
+func (app *application) maybeAuthenticated(next http.HandlerFunc) http.HandlerFunc {
+	return app.auth.Authenticate(next)
+}
+
+// authenticated admin required
+func (app *application) admin(next http.HandlerFunc) http.HandlerFunc {
+	return app.authenticated(app.auth.RequireScope(auth.ScopeAdmin, next))
+}
+
+func (app *application) routes() http.Handler {
+	router := mux.NewRouter()
+
+	//middleware added with Use only runs for matched routes, where the route template is known
+	router.Use(app.traceRequests, app.logRequests, app.instrumentRequests, app.validateRequests)
+
+	//unmatched requests still get an access log line and are counted
+	router.NotFoundHandler = app.logRequests(app.instrumentRequests(http.HandlerFunc(app.routeNotFound)))
+	router.MethodNotAllowedHandler = app.logRequests(app.instrumentRequests(http.HandlerFunc(app.methodNotAllowed)))
+
+	router.Handle("/healthz", app.health.LivenessHandler()).Methods(http.MethodGet)
+	router.Handle("/readyz", app.health.ReadinessHandler()).Methods(http.MethodGet)
+	router.Handle("/metrics", MetricsHandler()).Methods(http.MethodGet)
+	router.Handle("/openapi.json", app.spec.Handler()).Methods(http.MethodGet)
+	router.HandleFunc("/debug/log-level", app.admin(logging.LevelHandler(logLevel).ServeHTTP)).Methods(http.MethodGet, http.MethodPut)
+
+	router.HandleFunc("/posts", app.GetPostsMetaData).Methods(http.MethodGet)
+	router.HandleFunc("/posts", app.authenticated(app.idempotent(app.CreatePost))).Methods(http.MethodPost)
+	router.HandleFunc("/posts", app.authenticated(app.DeletePosts)).Methods(http.MethodDelete)
+	router.HandleFunc("/posts/featured", app.GetFeaturedPosts).Methods(http.MethodGet)
+	router.HandleFunc("/posts/by-author", app.maybeAuthenticated(app.GetPostsByAuthorID)).Methods(http.MethodGet)
+	router.HandleFunc("/posts/following", app.authenticated(app.GetFollowingPosts)).Methods(http.MethodGet)
+	router.HandleFunc("/posts/search", app.SearchPosts).Methods(http.MethodGet)
+	router.HandleFunc("/posts/by-tag", app.GetPostsByTag).Methods(http.MethodGet)
+	router.HandleFunc("/posts/by-category", app.GetPostsByCategory).Methods(http.MethodGet)
+	router.HandleFunc("/posts/tags", app.GetPopularTags).Methods(http.MethodGet)
+	router.HandleFunc("/posts/tags/{tag}/rename", app.admin(app.RenameTag)).Methods(http.MethodPost)
+	router.HandleFunc("/posts/reactions", app.GetReactionSet).Methods(http.MethodGet)
+
+	router.HandleFunc("/posts/bookmarks", app.authenticated(app.GetBookmarks)).Methods(http.MethodGet)
+	router.HandleFunc("/posts/bookmarks/order", app.authenticated(app.ReorderBookmarks)).Methods(http.MethodPut)
+	router.HandleFunc("/posts/bookmarks/{postid}", app.authenticated(app.AddBookmark)).Methods(http.MethodPut)
+	router.HandleFunc("/posts/bookmarks/{postid}", app.authenticated(app.RemoveBookmark)).Methods(http.MethodDelete)
+	router.HandleFunc("/posts/lists", app.maybeAuthenticated(app.GetReadingLists)).Methods(http.MethodGet)
+	router.HandleFunc("/posts/lists", app.authenticated(app.idempotent(app.CreateReadingList))).Methods(http.MethodPost)
+	router.HandleFunc("/posts/lists/{listid}", app.maybeAuthenticated(app.GetReadingList)).Methods(http.MethodGet)
+	router.HandleFunc("/posts/lists/{listid}", app.authenticated(app.UpdateReadingList)).Methods(http.MethodPut)
+	router.HandleFunc("/posts/lists/{listid}", app.authenticated(app.DeleteReadingList)).Methods(http.MethodDelete)
+	router.HandleFunc("/posts/lists/{listid}/order", app.authenticated(app.ReorderReadingList)).Methods(http.MethodPut)
+	router.HandleFunc("/posts/lists/{listid}/posts/{postid}", app.authenticated(app.AddToReadingList)).Methods(http.MethodPut)
+	router.HandleFunc("/posts/lists/{listid}/posts/{postid}", app.authenticated(app.RemoveFromReadingList)).Methods(http.MethodDelete)
+	router.HandleFunc("/posts/reactions/reconcile", app.admin(app.ReconcileReactions)).Methods(http.MethodPost)
+
+	router.HandleFunc("/posts/{postid}", app.maybeAuthenticated(app.GetPostByID)).Methods(http.MethodGet)
+	router.HandleFunc("/posts/{postid}", app.authenticated(app.DeletePost)).Methods(http.MethodDelete)
+	router.HandleFunc("/posts/{postid}/with-preferences", app.authenticated(app.GetPostByID_WithUserPreferences)).Methods(http.MethodGet)
+	router.HandleFunc("/posts/{postid}/title", app.authenticated(app.UpdatePostTitle)).Methods(http.MethodPut)
+	router.HandleFunc("/posts/{postid}/content", app.authenticated(app.UpdatePostContent)).Methods(http.MethodPut)
+	router.HandleFunc("/posts/{postid}/tags", app.authenticated(app.UpdatePostTags)).Methods(http.MethodPut)
+	router.HandleFunc("/posts/{postid}/category", app.authenticated(app.UpdatePostCategory)).Methods(http.MethodPut)
+	router.HandleFunc("/posts/{postid}/visibility", app.authenticated(app.UpdatePostVisibility)).Methods(http.MethodPut)
+	router.HandleFunc("/posts/{postid}/publish", app.authenticated(app.PublishPost)).Methods(http.MethodPost)
+	router.HandleFunc("/posts/{postid}/archive", app.authenticated(app.ArchivePost)).Methods(http.MethodPost)
+
+	router.HandleFunc("/posts/{postid}/revisions", app.authenticated(app.GetPostRevisions)).Methods(http.MethodGet)
+	router.HandleFunc("/posts/{postid}/revisions/diff", app.authenticated(app.DiffPostRevisions)).Methods(http.MethodGet)
+	router.HandleFunc("/posts/{postid}/revisions/{revisionid}", app.authenticated(app.GetPostRevision)).Methods(http.MethodGet)
+	router.HandleFunc("/posts/{postid}/revisions/{revisionid}/revert", app.authenticated(app.RevertPost)).Methods(http.MethodPost)
+
+	router.HandleFunc("/posts/{postid}/comments", app.maybeAuthenticated(app.GetComments)).Methods(http.MethodGet)
+	router.HandleFunc("/posts/{postid}/comments", app.authenticated(app.idempotent(app.CreateComment))).Methods(http.MethodPost)
+	router.HandleFunc("/posts/{postid}/comments/{commentid}", app.authenticated(app.UpdateComment)).Methods(http.MethodPut)
+	router.HandleFunc("/posts/{postid}/comments/{commentid}", app.authenticated(app.DeleteComment)).Methods(http.MethodDelete)
+	router.HandleFunc("/posts/{postid}/comments/{commentid}/reactions/{reaction}", app.authenticated(app.AddCommentReaction)).Methods(http.MethodPut)
+	router.HandleFunc("/posts/{postid}/comments/{commentid}/reactions/{reaction}", app.authenticated(app.RemoveCommentReaction)).Methods(http.MethodDelete)
+	router.HandleFunc("/posts/{postid}/comments/{commentid}/reactions/{reaction}/users", app.maybeAuthenticated(app.GetCommentReactionUsers)).Methods(http.MethodGet)
+
+	router.HandleFunc("/posts/{postid}/reactions/{reaction}", app.authenticated(app.AddPostReaction)).Methods(http.MethodPut)
+	router.HandleFunc("/posts/{postid}/reactions/{reaction}", app.authenticated(app.RemovePostReaction)).Methods(http.MethodDelete)
+	router.HandleFunc("/posts/{postid}/reactions/{reaction}/users", app.maybeAuthenticated(app.GetPostReactionUsers)).Methods(http.MethodGet)
+
+	router.HandleFunc("/posts/{postid}/like", app.authenticated(app.idempotent(app.LikePost))).Methods(http.MethodPost)
+	router.HandleFunc("/posts/{postid}/like", app.authenticated(app.RemoveLikeFromPost)).Methods(http.MethodDelete)
+	router.HandleFunc("/posts/{postid}/dislike", app.authenticated(app.idempotent(app.DislikePost))).Methods(http.MethodPost)
+	router.HandleFunc("/posts/{postid}/dislike", app.authenticated(app.RemoveDislikeFromPost)).Methods(http.MethodDelete)
+
+	return router
+}
@@ -0,0 +1,259 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"post_service/internal/data"
+)
+
+const maxSearchLength = 200
+
+// how much content a snippet shows around the first match, in bytes
+const (
+	snippetLength  = 200
+	snippetContext = 60
+)
+
+type searchHit struct {
+	data.Post
+	Score      float64         `json:"score"`
+	Highlights searchHighlight `json:"highlights"`
+}
+
+// html escaped text with the matched words in <mark>
+type searchHighlight struct {
+	Title   string `json:"title"`
+	Snippet string `json:"snippet"`
+}
+
+// /posts/search?q=... ranked by relevance, narrowed by authorid, tag and a
+// created_at range from/to
+func (app *application) SearchPosts(w http.ResponseWriter, r *http.Request) {
+	query, err := readSearchQuery(r)
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	//a cursor only fits the query it was handed out for
+	listing := searchListing(query)
+	page, err := app.readPage(r, listing)
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	results, more, err := app.modelsFor(r).Posts.SearchPosts(query, page)
+	if err != nil {
+		app.internalServerError(w, r)
+		return
+	}
+
+	terms := searchTerms(query.Text)
+	hits := make([]searchHit, len(results))
+	for i, res := range results {
+		hits[i] = searchHit{
+			Post:  res.Post,
+			Score: res.Score,
+			Highlights: searchHighlight{
+				Title:   highlight(res.Title, terms),
+				Snippet: snippet(res.Content, terms),
+			},
+		}
+		//listings leave out the content
+		hits[i].Content = ""
+	}
+
+	var first, last data.Cursor
+	if len(results) != 0 {
+		first, last = results[0].Cursor(true), results[len(results)-1].Cursor(false)
+	}
+	next, prev, err := pageLinks(r, listing, page, len(results), first, last, more)
+	if err != nil {
+		app.internalServerError(w, r)
+		return
+	}
+
+	app.writeJSON(w, envelope{"results": hits, "next": next, "prev": prev}, http.StatusOK)
+}
+
+func readSearchQuery(r *http.Request) (data.SearchQuery, error) {
+	var query data.SearchQuery
+	params := r.URL.Query()
+
+	query.Text = strings.TrimSpace(params.Get("q"))
+	if query.Text == "" || len(query.Text) > maxSearchLength {
+		return query, fmt.Errorf("q must be between 1 and %d characters.", maxSearchLength)
+	}
+
+	if s := params.Get("authorid"); s != "" {
+		authorid, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return query, errors.New("invalid authorid.")
+		}
+		query.AuthorID = authorid
+	}
+
+	if s := params.Get("tag"); s != "" {
+		tag, err := data.NormalizeTag(s)
+		if err != nil {
+			return query, errors.New("invalid tag.")
+		}
+		query.Tag = tag
+	}
+
+	for _, p := range []struct {
+		name string
+		dest *time.Time
+	}{{"from", &query.From}, {"to", &query.To}} {
+		s := params.Get(p.name)
+		if s == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return query, fmt.Errorf("%s must be an RFC 3339 date-time.", p.name)
+		}
+		*p.dest = t
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		return query, errors.New("from must be before to.")
+	}
+
+	return query, nil
+}
+
+func searchListing(query data.SearchQuery) string {
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s\n%d\n%s\n%d\n%d", query.Text, query.AuthorID, query.Tag, query.From.Unix(), query.To.Unix())))
+	return "search:" + hex.EncodeToString(h[:8])
+}
+
+// lower case words of the query to highlight, phrases count as their words
+// and -excluded words are left out
+func searchTerms(q string) []string {
+	var terms []string
+	seen := map[string]bool{}
+	for _, token := range strings.Fields(q) {
+		if strings.HasPrefix(token, "-") {
+			continue
+		}
+		for _, span := range wordSpans(token) {
+			term := stem(strings.ToLower(token[span[0]:span[1]]))
+			if !seen[term] {
+				seen[term] = true
+				terms = append(terms, term)
+			}
+		}
+	}
+	return terms
+}
+
+// rough stand in for the stemming of the text index, so "posting" in the
+// query marks "posts" in the text
+func stem(word string) string {
+	for _, suffix := range []string{"ing", "ed", "es", "s"} {
+		if strings.HasSuffix(word, suffix) && len(word)-len(suffix) >= 3 {
+			return strings.TrimSuffix(word, suffix)
+		}
+	}
+	return word
+}
+
+// byte ranges of the runs of letters and digits
+func wordSpans(text string) [][2]int {
+	var spans [][2]int
+	start := -1
+	for i, c := range text {
+		isWord := unicode.IsLetter(c) || unicode.IsDigit(c)
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord && start >= 0:
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(text)})
+	}
+	return spans
+}
+
+func matches(word string, terms []string) bool {
+	word = stem(strings.ToLower(word))
+	for _, term := range terms {
+		if word == term {
+			return true
+		}
+	}
+	return false
+}
+
+func highlight(text string, terms []string) string {
+	var b strings.Builder
+	last := 0
+	for _, span := range wordSpans(text) {
+		if !matches(text[span[0]:span[1]], terms) {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[last:span[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[span[0]:span[1]]))
+		b.WriteString("</mark>")
+		last = span[1]
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// about snippetLength bytes of content around the first match, cut at word
+// boundaries. the start of the content when only the title matched
+func snippet(content string, terms []string) string {
+	spans := wordSpans(content)
+	if len(spans) == 0 {
+		return ""
+	}
+
+	first := 0
+	for i, span := range spans {
+		if matches(content[span[0]:span[1]], terms) {
+			first = i
+			break
+		}
+	}
+
+	start := first
+	for start > 0 && spans[first][0]-spans[start-1][0] <= snippetContext {
+		start--
+	}
+	end := first
+	for end < len(spans)-1 && spans[end+1][1]-spans[start][0] <= snippetLength {
+		end++
+	}
+
+	from, to := spans[start][0], spans[end][1]
+	if start == 0 {
+		from = 0
+	}
+	if end == len(spans)-1 {
+		to = len(content)
+	}
+
+	s := highlight(content[from:to], terms)
+	if from > 0 {
+		s = "…" + s
+	}
+	if to < len(content) {
+		s += "…"
+	}
+	return s
+}
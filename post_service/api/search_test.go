@@ -0,0 +1,142 @@
+package api
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSearchTerms(t *testing.T) {
+	tests := []struct {
+		name string
+		q    string
+		want []string
+	}{
+		{"empty", "", nil},
+		{"words", "Go posting", []string{"go", "post"}},
+		{"phrase", `"exact phrase" words`, []string{"exact", "phrase", "word"}},
+		{"excluded", "go -java -rust", []string{"go"}},
+		{"only excluded", "-java", nil},
+		{"lone dash", "go -", []string{"go"}},
+		{"dash inside a word", "go-lang", []string{"go", "lang"}},
+		{"duplicates", "Post posts posting POSTED", []string{"post"}},
+		{"punctuation", "c++, go!", []string{"c", "go"}},
+		{"accents", "Café Übersetzung", []string{"café", "übersetzung"}},
+		{"cjk", "日本語 テスト", []string{"日本語", "テスト"}},
+		{"excluded multibyte", "café -thé", []string{"café"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := searchTerms(tt.q); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("searchTerms(%q) = %q, want %q", tt.q, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStem(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		{"posts", "post"},
+		{"posting", "post"},
+		{"posted", "post"},
+		{"boxes", "box"},
+		{"uses", "use"},
+		{"things", "thing"},
+		//too short to lose the suffix
+		{"bus", "bus"},
+		{"ing", "ing"},
+		{"red", "red"},
+		{"go", "go"},
+		{"", ""},
+		{"cafés", "café"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.word, func(t *testing.T) {
+			if got := stem(tt.word); got != tt.want {
+				t.Errorf("stem(%q) = %q, want %q", tt.word, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		terms []string
+		want  string
+	}{
+		{"no terms", "a post", nil, "a post"},
+		{"stemmed match", "Posting about posts", []string{"post"}, "<mark>Posting</mark> about <mark>posts</mark>"},
+		{"part of a word", "postal", []string{"post"}, "postal"},
+		{"escaped", "<b>post</b> & co", []string{"post"}, "&lt;b&gt;<mark>post</mark>&lt;/b&gt; &amp; co"},
+		{"quotes", `say "go" it's`, []string{"go"}, "say &#34;<mark>go</mark>&#34; it&#39;s"},
+		{"markup is not a match", "<mark>x</mark>", []string{"mark"}, "&lt;<mark>mark</mark>&gt;x&lt;/<mark>mark</mark>&gt;"},
+		{"whitespace collapsed", "a\n\n  post\t b ", []string{"post"}, "a <mark>post</mark> b"},
+		{"multibyte", "Über Café", []string{"café"}, "Über <mark>Café</mark>"},
+		{"cjk", "日本語のテスト", []string{"日本語のテスト"}, "<mark>日本語のテスト</mark>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highlight(tt.text, tt.terms); got != tt.want {
+				t.Errorf("highlight(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	words := func(n int) string {
+		return strings.Repeat("word ", n)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		terms   []string
+		want    string
+	}{
+		{"empty", "", []string{"post"}, ""},
+		{"no words", " ... ", []string{"post"}, ""},
+		{"short", "a short post", []string{"post"}, "a short <mark>post</mark>"},
+		{"escaped", "<script>post</script>", []string{"post"}, "&lt;script&gt;<mark>post</mark>&lt;/script&gt;"},
+		//60 bytes of context before the match and 200 in all
+		{"cut at both ends", words(50) + "target " + words(50), []string{"target"},
+			"…" + words(12) + "<mark>target</mark>" + strings.Repeat(" word", 26) + "…"},
+		{"match at the start", "target " + words(50), []string{"target"},
+			"<mark>target</mark>" + strings.Repeat(" word", 38) + "…"},
+		{"match at the end", words(50) + "target", []string{"target"},
+			"…" + words(12) + "<mark>target</mark>"},
+		//only the title matched
+		{"no match", words(50), []string{"target"}, strings.TrimSpace(words(40)) + "…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := snippet(tt.content, tt.terms); got != tt.want {
+				t.Errorf("snippet = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnippetMultibyte(t *testing.T) {
+	content := strings.Repeat("über ", 50) + "ziel " + strings.Repeat("日本語 ", 50)
+
+	got := snippet(content, []string{"ziel"})
+	if !utf8.ValidString(got) {
+		t.Fatalf("snippet cut inside a rune: %q", got)
+	}
+	if !strings.HasPrefix(got, "…über ") || !strings.HasSuffix(got, "日本語…") {
+		t.Errorf("snippet should be cut at words on both ends: %q", got)
+	}
+	if !strings.Contains(got, "<mark>ziel</mark>") {
+		t.Errorf("snippet misses the match: %q", got)
+	}
+	if body := strings.Trim(got, "…"); len(body) > snippetLength+len("<mark></mark>") {
+		t.Errorf("snippet is %d bytes long", len(body))
+	}
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+
+	"common/server"
+	"common/users"
+	"post_service/internal/data"
+)
+
+// loads the configuration, connects to mongodb and serves http until
+// SIGINT/SIGTERM, args are the command line flags without the program name
+func Run(args []string) error {
+	GetLogger()
+
+	if err := LoadConfig(args); err != nil {
+		return err
+	}
+
+	shutdownTracing, err := SetupTracing(context.Background())
+	if err != nil {
+		log.Error("error while setting up tracing", "err", err)
+		return err
+	}
+	defer shutdownTracing(context.Background())
+
+	app := &application{}
+
+	app.spec, err = LoadOpenAPI()
+	if err != nil {
+		log.Error("error while loading the openapi document", "err", err)
+		return err
+	}
+
+	client, err := app.ConnectMongoDB()
+	if err != nil {
+		return err
+	}
+	defer app.DisconnectMongoDB()
+
+	app.models = data.GetModels(client, Config.MongoDatabase)
+	if err := app.models.Posts.EnsureIndexes(); err != nil {
+		log.Error("error while creating indexes", "err", err)
+		return err
+	}
+	if err := app.models.Posts.UpgradePosts(); err != nil {
+		log.Error("error while upgrading posts", "err", err)
+		return err
+	}
+	if err := app.models.IdempotencyKeys.EnsureIndexes(); err != nil {
+		log.Error("error while creating indexes", "err", err)
+		return err
+	}
+
+	app.users, err = users.New(users.Options{
+		Addr:      Config.UserServiceAddr,
+		Timeout:   Config.UserServiceTimeout,
+		CacheTTL:  Config.UserCacheTTL,
+		CacheSize: Config.UserCacheSize,
+	})
+	if err != nil {
+		return err
+	}
+	defer app.users.Close()
+
+	app.auth = app.newAuth()
+	app.idempotency = app.newIdempotency()
+
+	app.health, err = NewHealthChecker(client, app.users)
+	if err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go app.publishScheduledPosts(done)
+	if Config.ReconcileInterval > 0 {
+		go app.reconcileReactionCounts(done)
+	}
+
+	err = server.ListenAndServe(app.routes(), server.Options{
+		Addr:            Config.HTTPAddr,
+		ReadTimeout:     Config.ReadTimeout,
+		WriteTimeout:    Config.WriteTimeout,
+		IdleTimeout:     Config.IdleTimeout,
+		DrainDelay:      Config.DrainDelay,
+		ShutdownTimeout: Config.ShutdownTimeout,
+		TLSCertFile:     Config.TLSCertFile,
+		TLSKeyFile:      Config.TLSKeyFile,
+		Logger:          log,
+		OnDrain:         app.health.Drain,
+	})
+	if err != nil {
+		log.Error("server stopped with error", "err", err)
+	}
+	return err
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"post_service/internal/data"
+)
+
+const (
+	listingByTag      = "by-tag"
+	listingByCategory = "by-category"
+)
+
+func (app *application) UpdatePostTags(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Tags []string `json:"tags"`
+	}
+
+	err := app.readJSON(r, w, &input)
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tags, err := data.NormalizeTags(input.Tags)
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	post, ok := app.getOwnPost(w, r)
+	if !ok {
+		return
+	}
+
+	post.Tags = tags
+	if !app.updatePost(w, r, &post) {
+		return
+	}
+
+	app.writeJSON(w, envelope{"tags": post.Tags}, http.StatusOK)
+}
+
+// an empty category removes it
+func (app *application) UpdatePostCategory(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Category string `json:"category"`
+	}
+
+	err := app.readJSON(r, w, &input)
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var category string
+	if input.Category != "" {
+		category, err = data.NormalizeTag(input.Category)
+		if err != nil {
+			app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	post, ok := app.getOwnPost(w, r)
+	if !ok {
+		return
+	}
+
+	post.Category = category
+	if !app.updatePost(w, r, &post) {
+		return
+	}
+
+	app.writeJSON(w, envelope{"category": post.Category}, http.StatusOK)
+}
+
+// tag and category are matched case insensitive, like they are stored
+func (app *application) GetPostsByTag(w http.ResponseWriter, r *http.Request) {
+	tag, err := data.NormalizeTag(r.URL.Query().Get("tag"))
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, "invalid tag.")
+		return
+	}
+
+	page, err := app.readPage(r, listingByTag)
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	posts, more, err := app.modelsFor(r).Posts.GetPostsByTag(tag, page)
+	if err != nil {
+		app.internalServerError(w, r)
+		return
+	}
+
+	app.writePage(w, r, listingByTag, page, posts, more)
+}
+
+func (app *application) GetPostsByCategory(w http.ResponseWriter, r *http.Request) {
+	category, err := data.NormalizeTag(r.URL.Query().Get("category"))
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, "invalid category.")
+		return
+	}
+
+	page, err := app.readPage(r, listingByCategory)
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	posts, more, err := app.modelsFor(r).Posts.GetPostsByCategory(category, page)
+	if err != nil {
+		app.internalServerError(w, r)
+		return
+	}
+
+	app.writePage(w, r, listingByCategory, page, posts, more)
+}
+
+// most used tags with the number of posts that have them
+func (app *application) GetPopularTags(w http.ResponseWriter, r *http.Request) {
+	limit, err := app.readIntQuery(r, "limit", 20)
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if limit == 0 || limit > maxPageSize {
+		app.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d.", maxPageSize))
+		return
+	}
+
+	tags, err := app.modelsFor(r).Posts.GetPopularTags(limit)
+	if err != nil {
+		app.internalServerError(w, r)
+		return
+	}
+
+	app.writeJSON(w, envelope{"tags": tags}, http.StatusOK)
+}
+
+// admin only, merges into the new tag when posts use it already
+func (app *application) RenameTag(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		To string `json:"to"`
+	}
+
+	err := app.readJSON(r, w, &input)
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	from, err := data.NormalizeTag(mux.Vars(r)["tag"])
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, "invalid tag.")
+		return
+	}
+	to, err := data.NormalizeTag(input.To)
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if from == to {
+		app.sendErrorResponse(w, http.StatusBadRequest, "the new tag is the same as the old one.")
+		return
+	}
+
+	changed, err := app.modelsFor(r).Posts.RenameTag(from, to)
+	if err != nil {
+		requestLogger(r).Error("error while renaming tag", "from", from, "to", to, "err", err)
+		app.internalServerError(w, r)
+		return
+	}
+	requestLogger(r).Info("tag renamed", "from", from, "to", to, "posts", changed)
+
+	app.writeJSON(w, envelope{"tag": to, "posts": changed}, http.StatusOK)
+}
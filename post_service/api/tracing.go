@@ -0,0 +1,32 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"common/tracing"
+	"post_service/internal/data"
+)
+
+const serviceName = "post_service"
+
+// installs the tracer provider from Config, call the returned func on shutdown
+func SetupTracing(ctx context.Context) (func(context.Context) error, error) {
+	return tracing.Setup(ctx, tracing.Options{
+		ServiceName: serviceName,
+		Exporter:    Config.TracingExporter,
+		Endpoint:    Config.OTLPEndpoint,
+		Insecure:    Config.OTLPInsecure,
+		SampleRatio: Config.TraceSampleRatio,
+	})
+}
+
+// server span for every request, named after the route template
+func (app *application) traceRequests(next http.Handler) http.Handler {
+	return tracing.Middleware(serviceName, routeTemplate)(next)
+}
+
+// models bound to the request context so queries show up under the request span
+func (app *application) modelsFor(r *http.Request) data.Models {
+	return app.models.WithContext(r.Context())
+}
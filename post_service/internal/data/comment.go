@@ -0,0 +1,215 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// replies to replies nest this deep, top level comments have depth 0
+	MaxCommentDepth  = 4
+	maxCommentLength = 10_000
+
+	// what a deleted comment shows instead of its content
+	DeletedComment = "[deleted]"
+)
+
+// orders of a comment thread, top is the most replied to first. replies are
+// one count on the comment an index can sort by, emoji reactions are a count
+// per emoji with no total to sort on
+const (
+	CommentsNewest = "newest"
+	CommentsTop    = "top"
+)
+
+const sortReplies = "replies"
+
+type Comment struct {
+	ID     primitive.ObjectID `bson:"_id" json:"id"`
+	PostID primitive.ObjectID `bson:"post_id" json:"post_id"`
+	// nil for top level comments
+	ParentID *primitive.ObjectID `bson:"parent_id" json:"parent_id"`
+	Depth    int                 `bson:"depth" json:"depth"`
+
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+
+	// cleared when the comment is deleted
+	AuthorID   uint64 `bson:"author_id" json:"author_id"`
+	AuthorName string `bson:"author_name" json:"author_name"`
+
+	Content string `bson:"content" json:"content"`
+	// replies stay where they are, under a placeholder
+	Deleted bool `bson:"deleted,omitempty" json:"deleted,omitempty"`
+	// direct replies, deleted ones included
+	Replies int64 `bson:"replies" json:"replies"`
+	// count per emoji, emojis nobody used are left out
+	Reactions map[string]int64 `bson:"reactions,omitempty" json:"reactions,omitempty"`
+}
+
+func (c *Comment) Validate() error {
+	if len(c.Content) == 0 {
+		return errors.New("comment content cannot be empty")
+	}
+	if len(c.Content) > maxCommentLength {
+		return fmt.Errorf("comment content cannot be longer than %d bytes", maxCommentLength)
+	}
+	if c.Depth > MaxCommentDepth {
+		return fmt.Errorf("replies can only be nested %d deep", MaxCommentDepth)
+	}
+	return nil
+}
+
+func (c *Comment) Cursor(before bool) Cursor {
+	return Cursor{CreatedAt: c.CreatedAt, Replies: c.Replies, ID: c.ID, Before: before}
+}
+
+// adds the comment and counts it on the post and on its parent, all in one
+// transaction. a post or parent deleted meanwhile is ErrNotFound
+func (p PostModels) AddComment(comment *Comment) error {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
+	defer cancel()
+
+	comment.ID = primitive.NewObjectID()
+	comment.CreatedAt = time.Now()
+	comment.UpdatedAt = comment.CreatedAt
+
+	return p.transaction(ctx, func(ctx mongo.SessionContext) error {
+		if _, err := p.comments.InsertOne(ctx, comment); err != nil {
+			return err
+		}
+
+		if comment.ParentID != nil {
+			res, err := p.comments.UpdateOne(ctx,
+				bson.D{{Key: "_id", Value: *comment.ParentID}},
+				bson.D{{Key: "$inc", Value: bson.D{{Key: "replies", Value: 1}}}})
+			if err != nil {
+				return err
+			}
+			if res.MatchedCount == 0 {
+				return ErrNotFound
+			}
+		}
+
+		res, err := p.collection.UpdateOne(ctx,
+			bson.D{{Key: "_id", Value: comment.PostID}},
+			bson.D{{Key: "$inc", Value: bson.D{{Key: "comments", Value: 1}}}})
+		if err != nil {
+			return err
+		}
+		if res.MatchedCount == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+func (p PostModels) GetComment(postid primitive.ObjectID, commentid primitive.ObjectID) (Comment, error) {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
+	defer cancel()
+
+	var comment Comment
+	err := p.comments.FindOne(ctx, bson.D{{Key: "_id", Value: commentid}, {Key: "post_id", Value: postid}}).Decode(&comment)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return comment, ErrNotFound
+		}
+		return comment, err
+	}
+	return comment, nil
+}
+
+// one level of a thread, the top level comments of the post for a nil parent
+// or the replies to parent
+func (p PostModels) GetComments(postid primitive.ObjectID, parent *primitive.ObjectID, order string, page Page) ([]Comment, bool, error) {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
+	defer cancel()
+
+	sortKey := sortCreatedAt
+	if order == CommentsTop {
+		sortKey = sortReplies
+	}
+
+	filter := bson.D{{Key: "post_id", Value: postid}, {Key: "parent_id", Value: parent}}
+	filter = append(filter, page.keyset(sortKey)...)
+	opts := options.Find().
+		SetSort(bson.D{{Key: sortKey, Value: page.order()}, {Key: "_id", Value: page.order()}}).
+		SetLimit(page.Limit + 1)
+
+	cursor, err := p.comments.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, false, err
+	}
+
+	comments := []Comment{}
+	if err := cursor.All(ctx, &comments); err != nil {
+		return nil, false, err
+	}
+
+	comments, more := trimPage(comments, page)
+	return comments, more, nil
+}
+
+// saves a new content, deleted comments are not found
+func (p PostModels) UpdateComment(comment *Comment) error {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
+	defer cancel()
+
+	comment.UpdatedAt = time.Now()
+
+	res, err := p.comments.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: comment.ID}, {Key: "deleted", Value: bson.D{{Key: "$ne", Value: true}}}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "content", Value: comment.Content},
+			{Key: "updated_at", Value: comment.UpdatedAt},
+		}}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// replaces the comment with a placeholder so its replies keep their place,
+// and no longer counts it on the post in the same transaction
+func (p PostModels) DeleteComment(comment *Comment) error {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
+	defer cancel()
+
+	comment.Deleted = true
+	comment.Content = DeletedComment
+	comment.AuthorID, comment.AuthorName = 0, ""
+	comment.UpdatedAt = time.Now()
+
+	return p.transaction(ctx, func(ctx mongo.SessionContext) error {
+		res, err := p.comments.UpdateOne(ctx,
+			bson.D{{Key: "_id", Value: comment.ID}, {Key: "deleted", Value: bson.D{{Key: "$ne", Value: true}}}},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "deleted", Value: true},
+				{Key: "content", Value: comment.Content},
+				{Key: "author_id", Value: comment.AuthorID},
+				{Key: "author_name", Value: comment.AuthorName},
+				{Key: "updated_at", Value: comment.UpdatedAt},
+			}}})
+		if err != nil {
+			return err
+		}
+		if res.ModifiedCount == 0 {
+			return ErrNotFound
+		}
+
+		_, err = p.collection.UpdateOne(ctx,
+			bson.D{{Key: "_id", Value: comment.PostID}},
+			bson.D{{Key: "$inc", Value: bson.D{{Key: "comments", Value: -1}}}})
+		return err
+	})
+}
@@ -0,0 +1,251 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// what an emoji reaction can be on
+const (
+	TargetPost    = "post"
+	TargetComment = "comment"
+)
+
+// emoji reaction of a user to a post or comment. unlike likes a user can add
+// several, each emoji once. the counts per emoji are kept in the reactions
+// of the target
+type EmojiReaction struct {
+	ID         primitive.ObjectID `bson:"_id" json:"-"`
+	TargetType string             `bson:"target_type" json:"-"`
+	TargetID   primitive.ObjectID `bson:"target_id" json:"-"`
+	// the post of a comment, so deleting the post removes everything
+	PostID primitive.ObjectID `bson:"post_id" json:"-"`
+
+	UserID    uint64    `bson:"user_id" json:"user_id"`
+	UserName  string    `bson:"user_name" json:"user_name"`
+	Emoji     string    `bson:"emoji" json:"-"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+func (e *EmojiReaction) Cursor(before bool) Cursor {
+	return Cursor{CreatedAt: e.CreatedAt, ID: e.ID, Before: before}
+}
+
+func (p PostModels) targets(targetType string) *mongo.Collection {
+	if targetType == TargetComment {
+		return p.comments
+	}
+	return p.collection
+}
+
+// moves the count of the emoji on the target, a count that drops to 0 is
+// removed so only emojis in use are listed. a target that is gone is
+// ErrNotFound
+func (p PostModels) addEmojiCount(ctx context.Context, targetType string, targetid primitive.ObjectID, emoji string, delta int64) error {
+	field := "reactions." + emoji
+	targets := p.targets(targetType)
+
+	res, err := targets.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: targetid}},
+		bson.D{{Key: "$inc", Value: bson.D{{Key: field, Value: delta}}}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	if delta > 0 {
+		return nil
+	}
+
+	_, err = targets.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: targetid}, {Key: field, Value: bson.D{{Key: "$lte", Value: 0}}}},
+		bson.D{{Key: "$unset", Value: bson.D{{Key: field, Value: ""}}}})
+	return err
+}
+
+// the emoji has to be one of the configured ones, the same emoji twice from
+// a user is ErrAlreadyReacted. the reaction and the count of the emoji on
+// the target are written in one transaction
+func (p PostModels) AddEmojiReaction(reaction *EmojiReaction) error {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
+	defer cancel()
+
+	reaction.ID = primitive.NewObjectID()
+	reaction.CreatedAt = time.Now()
+
+	return p.transaction(ctx, func(ctx mongo.SessionContext) error {
+		_, err := p.emojis.InsertOne(ctx, reaction)
+		if err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return ErrAlreadyReacted
+			}
+			return err
+		}
+
+		return p.addEmojiCount(ctx, reaction.TargetType, reaction.TargetID, reaction.Emoji, 1)
+	})
+}
+
+func (p PostModels) RemoveEmojiReaction(targetType string, targetid primitive.ObjectID, userid uint64, emoji string) error {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
+	defer cancel()
+
+	return p.transaction(ctx, func(ctx mongo.SessionContext) error {
+		res, err := p.emojis.DeleteOne(ctx, bson.D{
+			{Key: "target_id", Value: targetid},
+			{Key: "user_id", Value: userid},
+			{Key: "emoji", Value: emoji},
+		})
+		if err != nil {
+			return err
+		}
+		if res.DeletedCount == 0 {
+			return ErrNotFound
+		}
+
+		return p.addEmojiCount(ctx, targetType, targetid, emoji, -1)
+	})
+}
+
+// who reacted to the target with the emoji, most recent first
+func (p PostModels) GetEmojiReactions(targetid primitive.ObjectID, emoji string, page Page) ([]EmojiReaction, bool, error) {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
+	defer cancel()
+
+	filter := bson.D{{Key: "target_id", Value: targetid}, {Key: "emoji", Value: emoji}}
+	filter = append(filter, page.keyset(sortCreatedAt)...)
+	opts := options.Find().
+		SetSort(bson.D{{Key: sortCreatedAt, Value: page.order()}, {Key: "_id", Value: page.order()}}).
+		SetLimit(page.Limit + 1)
+
+	cursor, err := p.emojis.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, false, err
+	}
+
+	reactions := []EmojiReaction{}
+	if err := cursor.All(ctx, &reactions); err != nil {
+		return nil, false, err
+	}
+
+	reactions, more := trimPage(reactions, page)
+	return reactions, more, nil
+}
+
+// the reactions of the user to each of the posts or comments, like or dislike
+// and the emojis. targets without any are left out
+func (p PostModels) GetUserReactions(userid uint64, targetids []primitive.ObjectID) (map[primitive.ObjectID][]string, error) {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
+	defer cancel()
+
+	mine := map[primitive.ObjectID][]string{}
+	if len(targetids) == 0 {
+		return mine, nil
+	}
+
+	cursor, err := p.reactions.Find(ctx, bson.D{
+		{Key: "post_id", Value: bson.D{{Key: "$in", Value: targetids}}},
+		{Key: "user_id", Value: userid},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var votes []Reaction
+	if err := cursor.All(ctx, &votes); err != nil {
+		return nil, err
+	}
+	for _, vote := range votes {
+		mine[vote.PostID] = append(mine[vote.PostID], vote.Kind)
+	}
+
+	cursor, err = p.emojis.Find(ctx, bson.D{
+		{Key: "target_id", Value: bson.D{{Key: "$in", Value: targetids}}},
+		{Key: "user_id", Value: userid},
+	}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var emojis []EmojiReaction
+	if err := cursor.All(ctx, &emojis); err != nil {
+		return nil, err
+	}
+	for _, e := range emojis {
+		mine[e.TargetID] = append(mine[e.TargetID], e.Emoji)
+	}
+
+	return mine, nil
+}
+
+// like reconcileLikes for the emoji counts of posts and comments, the counts
+// per emoji are compared as sets since the order of the fields in reactions
+// is not the same everywhere
+func (p PostModels) reconcileEmojiCounts(ctx context.Context, targetType string) (int64, error) {
+	targets := p.targets(targetType)
+
+	cursor, err := targets.Aggregate(ctx, []bson.D{
+		{{Key: "$project", Value: bson.D{{Key: "reactions", Value: 1}}}},
+		{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: p.emojis.Name()},
+			{Key: "localField", Value: "_id"},
+			{Key: "foreignField", Value: "target_id"},
+			{Key: "as", Value: "counted"},
+			//as k and v, the way $objectToArray has the reactions
+			{Key: "pipeline", Value: bson.A{
+				bson.D{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$emoji"}, {Key: "v", Value: bson.D{{Key: "$sum", Value: 1}}}}}},
+				bson.D{{Key: "$project", Value: bson.D{{Key: "_id", Value: 0}, {Key: "k", Value: "$_id"}, {Key: "v", Value: 1}}}},
+			}},
+		}}},
+		{{Key: "$match", Value: bson.D{{Key: "$expr", Value: bson.D{{Key: "$not", Value: bson.A{
+			bson.D{{Key: "$setEquals", Value: bson.A{
+				bson.D{{Key: "$objectToArray", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$reactions", bson.D{}}}}}},
+				"$counted",
+			}}},
+		}}}}}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var fixed int64
+	for cursor.Next(ctx) {
+		var target struct {
+			ID        primitive.ObjectID `bson:"_id"`
+			Reactions bson.D             `bson:"reactions"`
+			Counted   []struct {
+				Emoji string `bson:"k"`
+				Count int64  `bson:"v"`
+			} `bson:"counted"`
+		}
+		if err := cursor.Decode(&target); err != nil {
+			return fixed, err
+		}
+
+		//replaced only if unchanged since, as read
+		filter := bson.D{{Key: "_id", Value: target.ID}, {Key: "reactions", Value: target.Reactions}}
+		if target.Reactions == nil {
+			filter = bson.D{{Key: "_id", Value: target.ID}, {Key: "reactions", Value: bson.D{{Key: "$exists", Value: false}}}}
+		}
+		counts := bson.D{}
+		for _, c := range target.Counted {
+			counts = append(counts, bson.E{Key: c.Emoji, Value: c.Count})
+		}
+		update := bson.D{{Key: "$set", Value: bson.D{{Key: "reactions", Value: counts}}}}
+		if len(counts) == 0 {
+			update = bson.D{{Key: "$unset", Value: bson.D{{Key: "reactions", Value: ""}}}}
+		}
+
+		res, err := targets.UpdateOne(ctx, filter, update)
+		if err != nil {
+			return fixed, err
+		}
+		fixed += res.ModifiedCount
+	}
+	return fixed, cursor.Err()
+}
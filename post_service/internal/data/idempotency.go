@@ -0,0 +1,133 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"common/idempotency"
+)
+
+// response stored for an Idempotency-Key, Status is 0 while the request runs
+type idempotencyRecord struct {
+	ID          idempotencyRecordID `bson:"_id"`
+	Fingerprint string              `bson:"fingerprint"`
+	Status      int                 `bson:"status"`
+	Header      http.Header         `bson:"header,omitempty"`
+	Body        []byte              `bson:"body,omitempty"`
+	CreatedAt   time.Time           `bson:"created_at"`
+	ExpiresAt   time.Time           `bson:"expires_at"`
+}
+
+type idempotencyRecordID struct {
+	UserID uint64 `bson:"user_id"`
+	Key    string `bson:"key"`
+}
+
+type IdempotencyModels struct {
+	// parent for the per query timeout, set through Models.WithContext
+	ctx        context.Context
+	collection *mongo.Collection
+}
+
+// mongodb removes expired records by itself, within a minute or so
+func (m IdempotencyModels) EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(m.ctx, context_timeout)
+	defer cancel()
+
+	_, err := m.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+func (m IdempotencyModels) Begin(key idempotency.Key, fingerprint string, lockedUntil time.Time) (*idempotency.Response, error) {
+	ctx, cancel := context.WithTimeout(m.ctx, context_timeout)
+	defer cancel()
+
+	id := idempotencyRecordID{UserID: key.UserID, Key: key.Value}
+	now := time.Now()
+
+	_, err := m.collection.InsertOne(ctx, idempotencyRecord{
+		ID:          id,
+		Fingerprint: fingerprint,
+		CreatedAt:   now,
+		ExpiresAt:   lockedUntil,
+	})
+	if err == nil {
+		return nil, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, err
+	}
+
+	//the ttl monitor may not have removed an expired record yet, the filter
+	//makes sure only one request takes it over
+	res, err := m.collection.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: id}, {Key: "expires_at", Value: bson.D{{Key: "$lt", Value: now}}}},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "fingerprint", Value: fingerprint},
+				{Key: "status", Value: 0},
+				{Key: "created_at", Value: now},
+				{Key: "expires_at", Value: lockedUntil},
+			}},
+			{Key: "$unset", Value: bson.D{{Key: "header", Value: ""}, {Key: "body", Value: ""}}},
+		})
+	if err != nil {
+		return nil, err
+	}
+	if res.MatchedCount == 1 {
+		return nil, nil
+	}
+
+	var record idempotencyRecord
+	err = m.collection.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&record)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			//removed in between, the retry gets it
+			return nil, idempotency.ErrInFlight
+		}
+		return nil, err
+	}
+
+	switch {
+	case record.Fingerprint != fingerprint:
+		return nil, idempotency.ErrMismatch
+	case record.Status == 0:
+		return nil, idempotency.ErrInFlight
+	}
+
+	return &idempotency.Response{Status: record.Status, Header: record.Header, Body: record.Body}, nil
+}
+
+func (m IdempotencyModels) Complete(key idempotency.Key, response *idempotency.Response, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(m.ctx, context_timeout)
+	defer cancel()
+
+	id := idempotencyRecordID{UserID: key.UserID, Key: key.Value}
+	_, err := m.collection.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: id}, {Key: "status", Value: 0}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "status", Value: response.Status},
+			{Key: "header", Value: response.Header},
+			{Key: "body", Value: response.Body},
+			{Key: "expires_at", Value: expiresAt},
+		}}})
+	return err
+}
+
+func (m IdempotencyModels) Release(key idempotency.Key) error {
+	ctx, cancel := context.WithTimeout(m.ctx, context_timeout)
+	defer cancel()
+
+	id := idempotencyRecordID{UserID: key.UserID, Key: key.Value}
+	_, err := m.collection.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}, {Key: "status", Value: 0}})
+	return err
+}
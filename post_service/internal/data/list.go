@@ -0,0 +1,373 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	MaxReadingLists   = 50
+	MaxListItems      = 500
+	maxListNameLength = 100
+)
+
+var (
+	ErrListNameExists = errors.New("you already have a reading list with this name.")
+	ErrAlreadyInList  = errors.New("post is already in the list.")
+	ErrListFull       = fmt.Errorf("a list can hold at most %d posts.", MaxListItems)
+	ErrTooManyLists   = fmt.Errorf("you can have at most %d reading lists.", MaxReadingLists)
+	ErrNotInList      = errors.New("the order can only name posts in the list.")
+	// the posts in the list changed while it was reordered
+	ErrListChanged = errors.New("the list changed meanwhile, reload it and try again.")
+)
+
+type ListItem struct {
+	PostID  primitive.ObjectID `bson:"post_id" json:"post_id"`
+	AddedAt time.Time          `bson:"added_at" json:"added_at"`
+}
+
+// posts a user saved for later, in the order they chose. the bookmarks of a
+// user are the one list without a name, it is always private
+type ReadingList struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	UserID    uint64             `bson:"user_id" json:"user_id"`
+	Name      string             `bson:"name" json:"name"`
+	Public    bool               `bson:"public" json:"public"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+
+	Items []ListItem `bson:"items" json:"-"`
+	// len(Items), set when the list is read
+	Count int `bson:"-" json:"count"`
+}
+
+func (l *ReadingList) Validate() error {
+	l.Name = strings.TrimSpace(l.Name)
+	if len(l.Name) == 0 {
+		return errors.New("reading list name cannot be empty")
+	}
+	if len(l.Name) > maxListNameLength {
+		return fmt.Errorf("reading list name cannot be longer than %d bytes", maxListNameLength)
+	}
+	return nil
+}
+
+func (l *ReadingList) Bookmarks() bool {
+	return l.Name == ""
+}
+
+var namedListFilter = bson.D{{Key: "name", Value: bson.D{{Key: "$ne", Value: ""}}}}
+
+func (p PostModels) AddReadingList(list *ReadingList) error {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
+	defer cancel()
+
+	//checked before the insert, two at once can go one over
+	n, err := p.lists.CountDocuments(ctx, append(bson.D{{Key: "user_id", Value: list.UserID}}, namedListFilter...))
+	if err != nil {
+		return err
+	}
+	if n >= MaxReadingLists {
+		return ErrTooManyLists
+	}
+
+	list.ID = primitive.NewObjectID()
+	list.CreatedAt = time.Now()
+	list.UpdatedAt = list.CreatedAt
+	list.Items = []ListItem{}
+
+	_, err = p.lists.InsertOne(ctx, list)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrListNameExists
+	}
+	return err
+}
+
+func (p PostModels) GetReadingList(listid primitive.ObjectID) (ReadingList, error) {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
+	defer cancel()
+
+	var list ReadingList
+	err := p.lists.FindOne(ctx, bson.D{{Key: "_id", Value: listid}}).Decode(&list)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return list, ErrNotFound
+		}
+		return list, err
+	}
+	list.Count = len(list.Items)
+	return list, nil
+}
+
+// the named lists of the user by name, the private ones only with all
+func (p PostModels) GetReadingLists(userid uint64, all bool) ([]ReadingList, error) {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
+	defer cancel()
+
+	filter := append(bson.D{{Key: "user_id", Value: userid}}, namedListFilter...)
+	if !all {
+		filter = append(filter, bson.E{Key: "public", Value: true})
+	}
+	cursor, err := p.lists.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	lists := []ReadingList{}
+	if err := cursor.All(ctx, &lists); err != nil {
+		return nil, err
+	}
+	for i := range lists {
+		lists[i].Count = len(lists[i].Items)
+	}
+	return lists, nil
+}
+
+// the bookmarks of the user, created on first use
+func (p PostModels) GetBookmarks(userid uint64) (ReadingList, error) {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
+	defer cancel()
+
+	filter := bson.D{{Key: "user_id", Value: userid}, {Key: "name", Value: ""}}
+	now := time.Now()
+	var list ReadingList
+	err := p.lists.FindOneAndUpdate(ctx, filter,
+		bson.D{{Key: "$setOnInsert", Value: bson.D{
+			{Key: "_id", Value: primitive.NewObjectID()},
+			{Key: "public", Value: false},
+			{Key: "created_at", Value: now},
+			{Key: "updated_at", Value: now},
+			{Key: "items", Value: bson.A{}},
+		}}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&list)
+	//two first requests both try to insert, the unique index on user_id,name
+	//lets one win and the other reads what it created
+	if mongo.IsDuplicateKeyError(err) {
+		err = p.lists.FindOne(ctx, filter).Decode(&list)
+	}
+	if err != nil {
+		return list, err
+	}
+	list.Count = len(list.Items)
+	return list, nil
+}
+
+// renames the list and changes whether it is public, bookmarks are not found
+func (p PostModels) UpdateReadingList(list *ReadingList) error {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
+	defer cancel()
+
+	list.UpdatedAt = time.Now()
+
+	res, err := p.lists.UpdateOne(ctx,
+		append(bson.D{{Key: "_id", Value: list.ID}}, namedListFilter...),
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "name", Value: list.Name},
+			{Key: "public", Value: list.Public},
+			{Key: "updated_at", Value: list.UpdatedAt},
+		}}})
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrListNameExists
+		}
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// bookmarks are not found, they can only be emptied
+func (p PostModels) DeleteReadingList(listid primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
+	defer cancel()
+
+	res, err := p.lists.DeleteOne(ctx, append(bson.D{{Key: "_id", Value: listid}}, namedListFilter...))
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// appends the post to the list
+func (p PostModels) AddToReadingList(listid primitive.ObjectID, postid primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
+	defer cancel()
+
+	now := time.Now()
+	res, err := p.lists.UpdateOne(ctx,
+		bson.D{
+			{Key: "_id", Value: listid},
+			{Key: "items.post_id", Value: bson.D{{Key: "$ne", Value: postid}}},
+			//shorter than MaxListItems
+			{Key: "items." + strconv.Itoa(MaxListItems-1), Value: bson.D{{Key: "$exists", Value: false}}},
+		},
+		bson.D{
+			{Key: "$push", Value: bson.D{{Key: "items", Value: ListItem{PostID: postid, AddedAt: now}}}},
+			{Key: "$set", Value: bson.D{{Key: "updated_at", Value: now}}},
+		})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount != 0 {
+		return nil
+	}
+
+	//tell apart why nothing matched
+	var list ReadingList
+	err = p.lists.FindOne(ctx, bson.D{{Key: "_id", Value: listid}}).Decode(&list)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrNotFound
+		}
+		return err
+	}
+	for _, item := range list.Items {
+		if item.PostID == postid {
+			return ErrAlreadyInList
+		}
+	}
+	return ErrListFull
+}
+
+func (p PostModels) RemoveFromReadingList(listid primitive.ObjectID, postid primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
+	defer cancel()
+
+	res, err := p.lists.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: listid}, {Key: "items.post_id", Value: postid}},
+		bson.D{
+			{Key: "$pull", Value: bson.D{{Key: "items", Value: bson.D{{Key: "post_id", Value: postid}}}}},
+			{Key: "$set", Value: bson.D{{Key: "updated_at", Value: time.Now()}}},
+		})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// moves the posts to the front of the list in the given order, the others
+// follow in the order they had. the list is only saved if it still holds the
+// same posts as when it was read
+func (p PostModels) ReorderReadingList(listid primitive.ObjectID, postids []primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
+	defer cancel()
+
+	var list ReadingList
+	err := p.lists.FindOne(ctx, bson.D{{Key: "_id", Value: listid}}).Decode(&list)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrNotFound
+		}
+		return err
+	}
+	if len(list.Items) == 0 {
+		if len(postids) != 0 {
+			return ErrNotInList
+		}
+		return nil
+	}
+
+	byPost := make(map[primitive.ObjectID]ListItem, len(list.Items))
+	current := make([]primitive.ObjectID, len(list.Items))
+	for i, item := range list.Items {
+		byPost[item.PostID] = item
+		current[i] = item.PostID
+	}
+
+	items := make([]ListItem, 0, len(list.Items))
+	moved := map[primitive.ObjectID]bool{}
+	for _, postid := range postids {
+		item, ok := byPost[postid]
+		if !ok {
+			return ErrNotInList
+		}
+		if moved[postid] {
+			continue
+		}
+		moved[postid] = true
+		items = append(items, item)
+	}
+	for _, item := range list.Items {
+		if !moved[item.PostID] {
+			items = append(items, item)
+		}
+	}
+
+	res, err := p.lists.UpdateOne(ctx,
+		bson.D{
+			{Key: "_id", Value: listid},
+			{Key: "items", Value: bson.D{{Key: "$size", Value: len(current)}}},
+			{Key: "items.post_id", Value: bson.D{{Key: "$all", Value: current}}},
+		},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "items", Value: items},
+			{Key: "updated_at", Value: time.Now()},
+		}}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrListChanged
+	}
+	return nil
+}
+
+// the posts of the list in its order without the content, posts the viewer
+// cant see are left out
+func (p PostModels) GetReadingListPosts(list *ReadingList, viewer uint64) ([]Post, error) {
+	if len(list.Items) == 0 {
+		return []Post{}, nil
+	}
+
+	postids := make([]primitive.ObjectID, len(list.Items))
+	for i, item := range list.Items {
+		postids[i] = item.PostID
+	}
+
+	found, err := p.find(
+		bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: postids}}}},
+		options.Find().SetProjection(metadataProjection))
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[primitive.ObjectID]Post, len(found))
+	for _, post := range found {
+		byID[post.ID] = post
+	}
+
+	posts := make([]Post, 0, len(found))
+	for _, postid := range postids {
+		post, ok := byID[postid]
+		if ok && post.VisibleTo(viewer) {
+			posts = append(posts, post)
+		}
+	}
+	return posts, nil
+}
+
+// takes a deleted post out of every list
+func (p PostModels) removeFromReadingLists(ctx context.Context, postid primitive.ObjectID) error {
+	_, err := p.lists.UpdateMany(ctx,
+		bson.D{{Key: "items.post_id", Value: postid}},
+		bson.D{{Key: "$pull", Value: bson.D{{Key: "items", Value: bson.D{{Key: "post_id", Value: postid}}}}}})
+	return err
+}
@@ -1,22 +1,124 @@
 package data
 
-import "go.mongodb.org/mongo-driver/mongo"
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"common/idempotency"
+)
 
 type Models struct {
+	client       *mongo.Client
+	databaseName string
+
 	Posts interface {
+		EnsureIndexes() error
+		UpgradePosts() error
+
 		AddPost(post *Post) error
-		GetPost(postid uint64) Post
-		UpdatePost(post *Post) (Post, error)
-		DeletePost(postid uint64) error
+		GetPost(postid primitive.ObjectID) (Post, error)
+		UpdatePost(post *Post) error
+		DeletePost(postid primitive.ObjectID) error
+
+		GetRevisions(postid primitive.ObjectID, page Page) ([]Revision, bool, error)
+		GetRevision(postid primitive.ObjectID, revisionid primitive.ObjectID) (Revision, error)
+
+		GetPostsMetaData(page Page) ([]Post, bool, error)
+		GetFeaturedPosts(offset int64) ([]Post, error)
+		GetPostsByAuthorID(authorid uint64, all bool, page Page) ([]Post, bool, error)
+		GetPostsByAuthorIDs(authorids []uint64, page Page) ([]Post, bool, error)
+		CheckPostTitleExists(title string) (bool, error)
+		SearchPosts(query SearchQuery, page Page) ([]SearchResult, bool, error)
+
+		GetPostsByTag(tag string, page Page) ([]Post, bool, error)
+		GetPostsByCategory(category string, page Page) ([]Post, bool, error)
+		GetPopularTags(limit int64) ([]TagCount, error)
+		RenameTag(from string, to string) (int64, error)
+
+		PublishScheduled(now time.Time) (int64, error)
+
+		AddComment(comment *Comment) error
+		GetComment(postid primitive.ObjectID, commentid primitive.ObjectID) (Comment, error)
+		GetComments(postid primitive.ObjectID, parent *primitive.ObjectID, order string, page Page) ([]Comment, bool, error)
+		UpdateComment(comment *Comment) error
+		DeleteComment(comment *Comment) error
+
+		LikePost(postid primitive.ObjectID, userid uint64) error
+		DislikePost(postid primitive.ObjectID, userid uint64) error
+		RemoveLikeFromPost(postid primitive.ObjectID, userid uint64) error
+		RemoveDislikeFromPost(postid primitive.ObjectID, userid uint64) error
+		ReconcileReactionCounts() (int64, error)
+
+		AddEmojiReaction(reaction *EmojiReaction) error
+		RemoveEmojiReaction(targetType string, targetid primitive.ObjectID, userid uint64, emoji string) error
+		GetEmojiReactions(targetid primitive.ObjectID, emoji string, page Page) ([]EmojiReaction, bool, error)
+		GetUserReactions(userid uint64, targetids []primitive.ObjectID) (map[primitive.ObjectID][]string, error)
+
+		AddReadingList(list *ReadingList) error
+		GetReadingList(listid primitive.ObjectID) (ReadingList, error)
+		GetReadingLists(userid uint64, all bool) ([]ReadingList, error)
+		GetBookmarks(userid uint64) (ReadingList, error)
+		UpdateReadingList(list *ReadingList) error
+		DeleteReadingList(listid primitive.ObjectID) error
+		AddToReadingList(listid primitive.ObjectID, postid primitive.ObjectID) error
+		RemoveFromReadingList(listid primitive.ObjectID, postid primitive.ObjectID) error
+		ReorderReadingList(listid primitive.ObjectID, postids []primitive.ObjectID) error
+		GetReadingListPosts(list *ReadingList, viewer uint64) ([]Post, error)
 	}
+
+	IdempotencyKeys interface {
+		EnsureIndexes() error
+		idempotency.Store
+	}
+}
+
+func GetModels(client *mongo.Client, database string) Models {
+	return getModels(context.Background(), client, database)
 }
 
-func GetModels(client *mongo.Client) Models {
+func getModels(ctx context.Context, client *mongo.Client, database string) Models {
 	return Models{
+		client:       client,
+		databaseName: database,
 		Posts: PostModels{
+			ctx:        ctx,
 			client:     client,
-			database:   client.Database(""),
-			collection: client.Database("").Collection(""),
+			database:   client.Database(database),
+			collection: client.Database(database).Collection("posts"),
+			reactions:  client.Database(database).Collection("post_reactions"),
+			revisions:  client.Database(database).Collection("post_revisions"),
+			comments:   client.Database(database).Collection("post_comments"),
+			emojis:     client.Database(database).Collection("post_emoji_reactions"),
+			lists:      client.Database(database).Collection("reading_lists"),
+		},
+		IdempotencyKeys: IdempotencyModels{
+			ctx:        ctx,
+			collection: client.Database(database).Collection("idempotency_keys"),
 		},
 	}
 }
+
+// models whose queries run under ctx, so request cancellation and trace spans
+// reach the mongodb calls
+func (m Models) WithContext(ctx context.Context) Models {
+	return getModels(ctx, m.client, m.databaseName)
+}
+
+// runs fn in a transaction, so the server has to be a replica set. fn runs
+// again when the transaction is retried, and an error from it aborts the
+// transaction and is returned as is
+func (p PostModels) transaction(ctx context.Context, fn func(ctx mongo.SessionContext) error) error {
+	session, err := p.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(ctx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(ctx)
+	})
+	return err
+}
@@ -0,0 +1,115 @@
+package data
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// keys listings are sorted by, always descending and with _id as tie breaker
+const (
+	sortLikes     = "likes"
+	sortCreatedAt = "created_at"
+)
+
+// position between two posts of a listing, the sort keys of the post next to
+// it. only the key of the listing's sort is used. comment threads and
+// revisions are paged the same way
+type Cursor struct {
+	Likes     int64              `json:"l,omitempty"`
+	CreatedAt time.Time          `json:"c,omitempty"`
+	Score     float64            `json:"r,omitempty"`
+	Replies   int64              `json:"p,omitempty"`
+	ID        primitive.ObjectID `json:"i"`
+	// the page ends before the post instead of starting after it
+	Before bool `json:"b,omitempty"`
+}
+
+// cursor for the posts after p, Before for the ones before it
+func (p *Post) Cursor(before bool) Cursor {
+	return Cursor{Likes: p.Likes, CreatedAt: p.CreatedAt, ID: p.ID, Before: before}
+}
+
+func (c *Cursor) value(sortKey string) interface{} {
+	switch sortKey {
+	case sortCreatedAt:
+		return c.CreatedAt
+	case sortScore:
+		return c.Score
+	case sortReplies:
+		return c.Replies
+	}
+	return c.Likes
+}
+
+type Page struct {
+	Limit int64
+	// nil for the first page
+	Cursor *Cursor
+}
+
+func (p Page) Backward() bool {
+	return p.Cursor != nil && p.Cursor.Before
+}
+
+// sort order that walks away from the cursor
+func (p Page) order() int {
+	if p.Backward() {
+		return 1
+	}
+	return -1
+}
+
+// matches what lies past the cursor in the order of the page, empty on the
+// first page
+func (p Page) keyset(sortKey string) bson.D {
+	if p.Cursor == nil {
+		return nil
+	}
+	cmp := "$lt"
+	if p.Backward() {
+		cmp = "$gt"
+	}
+	value := p.Cursor.value(sortKey)
+	return bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: sortKey, Value: bson.D{{Key: cmp, Value: value}}}},
+		bson.D{{Key: sortKey, Value: value}, {Key: "_id", Value: bson.D{{Key: cmp, Value: p.Cursor.ID}}}},
+	}}}
+}
+
+// drops the extra item fetched to tell if there is another page and puts a
+// backward page in listing order
+func trimPage[T any](items []T, page Page) ([]T, bool) {
+	more := int64(len(items)) > page.Limit
+	if more {
+		items = items[:page.Limit]
+	}
+	if page.Backward() {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+	return items, more
+}
+
+// posts of filter on the page, listing order, and whether there are more in
+// the direction of the page. with a cursor a post whose sort key changed
+// moves, the others are neither skipped nor repeated
+func (p PostModels) findPage(filter bson.D, sortKey string, page Page) ([]Post, bool, error) {
+	filter = append(filter, page.keyset(sortKey)...)
+
+	//one more than asked for tells if there is another page
+	opts := options.Find().
+		SetProjection(metadataProjection).
+		SetSort(bson.D{{Key: sortKey, Value: page.order()}, {Key: "_id", Value: page.order()}}).
+		SetLimit(page.Limit + 1)
+	posts, err := p.find(filter, opts)
+	if err != nil {
+		return nil, false, err
+	}
+
+	posts, more := trimPage(posts, page)
+	return posts, more, nil
+}
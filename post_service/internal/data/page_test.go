@@ -0,0 +1,89 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestKeyset(t *testing.T) {
+	id := primitive.NewObjectID()
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	forward := &Cursor{Likes: 7, CreatedAt: created, ID: id}
+	backward := &Cursor{Likes: 7, CreatedAt: created, ID: id, Before: true}
+
+	keyset := func(sortKey, cmp string, value interface{}) bson.D {
+		return bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: sortKey, Value: bson.D{{Key: cmp, Value: value}}}},
+			bson.D{{Key: sortKey, Value: value}, {Key: "_id", Value: bson.D{{Key: cmp, Value: id}}}},
+		}}}
+	}
+
+	tests := []struct {
+		name      string
+		page      Page
+		sortKey   string
+		want      bson.D
+		wantOrder int
+	}{
+		{"first page", Page{Limit: 10}, sortLikes, nil, -1},
+		{"forward by likes", Page{Limit: 10, Cursor: forward}, sortLikes, keyset(sortLikes, "$lt", int64(7)), -1},
+		{"backward by likes", Page{Limit: 10, Cursor: backward}, sortLikes, keyset(sortLikes, "$gt", int64(7)), 1},
+		{"forward by date", Page{Limit: 10, Cursor: forward}, sortCreatedAt, keyset(sortCreatedAt, "$lt", created), -1},
+		{"backward by date", Page{Limit: 10, Cursor: backward}, sortCreatedAt, keyset(sortCreatedAt, "$gt", created), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.page.keyset(tt.sortKey); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("keyset = %v, want %v", got, tt.want)
+			}
+			if got := tt.page.order(); got != tt.wantOrder {
+				t.Errorf("order = %d, want %d", got, tt.wantOrder)
+			}
+		})
+	}
+}
+
+func TestTrimPage(t *testing.T) {
+	forward := &Cursor{ID: primitive.NewObjectID()}
+	backward := &Cursor{ID: primitive.NewObjectID(), Before: true}
+
+	tests := []struct {
+		name     string
+		items    []int
+		page     Page
+		want     []int
+		wantMore bool
+	}{
+		{"empty", []int{}, Page{Limit: 3}, []int{}, false},
+		{"short", []int{1, 2}, Page{Limit: 3}, []int{1, 2}, false},
+		{"full", []int{1, 2, 3}, Page{Limit: 3}, []int{1, 2, 3}, false},
+		{"one extra", []int{1, 2, 3, 4}, Page{Limit: 3}, []int{1, 2, 3}, true},
+		{"forward cursor", []int{1, 2, 3, 4}, Page{Limit: 3, Cursor: forward}, []int{1, 2, 3}, true},
+		//backward pages are read walking away from the cursor
+		{"backward short", []int{3, 2}, Page{Limit: 3, Cursor: backward}, []int{2, 3}, false},
+		{"backward one extra", []int{4, 3, 2, 1}, Page{Limit: 3, Cursor: backward}, []int{2, 3, 4}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, more := trimPage(tt.items, tt.page)
+			if !reflect.DeepEqual(got, tt.want) || more != tt.wantMore {
+				t.Errorf("trimPage = %v, %v, want %v, %v", got, more, tt.want, tt.wantMore)
+			}
+		})
+	}
+}
+
+func TestPostCursor(t *testing.T) {
+	post := &Post{ID: primitive.NewObjectID(), Likes: 3, CreatedAt: time.Now()}
+
+	for _, before := range []bool{false, true} {
+		c := post.Cursor(before)
+		if c.ID != post.ID || c.Likes != post.Likes || !c.CreatedAt.Equal(post.CreatedAt) || c.Before != before {
+			t.Errorf("Cursor(%v) = %+v", before, c)
+		}
+	}
+}
@@ -3,291 +3,358 @@ package data
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"post_service/internal/markdown"
 )
 
 const context_timeout = 10 * time.Second
 
 var (
-	ErrNotFound = errors.New("not found.")
+	ErrNotFound       = errors.New("not found.")
+	ErrAlreadyReacted = errors.New("user already reacted to this post this way.")
+	// the post was saved by another request since it was read
+	ErrEditConflict = errors.New("the post changed meanwhile, reload it and try again.")
 )
 
 type Post struct {
-	ID        primitive.ObjectID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-
-	AuthorID   uint64
-	AuthorName string
-
-	Title   string
-	Content string
-	Likes   uint16
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+
+	AuthorID   uint64 `bson:"author_id" json:"author_id"`
+	AuthorName string `bson:"author_name" json:"author_name"`
+
+	Title string `bson:"title" json:"title"`
+	// markdown as written by the author
+	Content string `bson:"content,omitempty" json:"content,omitempty"`
+	// sanitized html of Content, rendered whenever the post is saved
+	ContentHTML string `bson:"content_html,omitempty" json:"content_html,omitempty"`
+	// normalized, see NormalizeTag
+	Tags     []string `bson:"tags,omitempty" json:"tags,omitempty"`
+	Category string   `bson:"category,omitempty" json:"category,omitempty"`
+	// likes minus dislikes
+	Likes int64 `bson:"likes" json:"likes"`
+	// comments that arent deleted
+	Comments int64 `bson:"comments" json:"comments"`
+	// count per emoji, only the ones in use
+	Reactions map[string]int64 `bson:"reactions,omitempty" json:"reactions,omitempty"`
+
+	Status     string `bson:"status" json:"status"`
+	Visibility string `bson:"visibility" json:"visibility"`
+	// when a scheduled post goes out or a published one went out
+	PublishAt *time.Time `bson:"publish_at,omitempty" json:"publish_at,omitempty"`
 }
 
 type PostModels struct {
+	// parent for the per query timeout, set through Models.WithContext
+	ctx        context.Context
 	client     *mongo.Client
 	database   *mongo.Database
 	collection *mongo.Collection
+	reactions  *mongo.Collection
+	revisions  *mongo.Collection
+	comments   *mongo.Collection
+	emojis     *mongo.Collection
+	lists      *mongo.Collection
 }
 
-func (p PostModels) AddPost(post *Post) (uint64, error) {
-	var postid uint64
+// listings leave out the content
+var metadataProjection = bson.D{{Key: "content", Value: 0}, {Key: "content_html", Value: 0}}
 
-	ctx, cancel := context.WithTimeout(context.Background(), context_timeout)
-	defer cancel()
-	_, err := p.collection.InsertOne(ctx, bson.D{})
-	if err != nil {
-		return postid, err
+func (p *Post) Validate() error {
+	if len(p.Title) == 0 {
+		return errors.New("post title cannot be empty")
 	}
-	return postid, nil
+	if len(p.Content) == 0 {
+		return errors.New("post content cannot be empty")
+	}
+	if len(p.Tags) > MaxTags {
+		return fmt.Errorf("a post can have at most %d tags.", MaxTags)
+	}
+	return p.validateStatus()
 }
 
-// FindOneAndDelete finds one deletes it returns the one before save
-func (p PostModels) GetPost(postid primitive.ObjectID) (Post, error) {
-	var post Post
+// sets ContentHTML from Content
+func (p *Post) RenderContent() error {
+	html, err := markdown.Render(p.Content)
+	if err != nil {
+		return err
+	}
+	p.ContentHTML = html
+	return nil
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), context_timeout)
+func (p PostModels) EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
 	defer cancel()
 
-	var doc bson.D
+	_, err := p.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "author_id", Value: 1}, {Key: "likes", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "author_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "likes", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "visibility", Value: 1}, {Key: "likes", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "publish_at", Value: 1}}},
+		{Keys: bson.D{{Key: "title", Value: 1}}},
+		{Keys: bson.D{{Key: "tags", Value: 1}, {Key: "likes", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "category", Value: 1}, {Key: "likes", Value: -1}, {Key: "_id", Value: -1}}},
+		{
+			Keys: bson.D{{Key: "title", Value: "text"}, {Key: "content", Value: "text"}},
+			Options: options.Index().
+				SetName("posts_text").
+				SetWeights(bson.D{{Key: "title", Value: 5}, {Key: "content", Value: 1}}),
+		},
+	})
+	if err != nil {
+		return err
+	}
 
-	opt := options.FindOne()
-	err := p.collection.FindOne(ctx, bson.D{{Key: "_id", Value: postid}}, opt).Decode(&doc)
+	//prefixes of the ones above, from before listings were paged by cursor
+	for _, name := range []string{"author_id_1_likes_-1", "author_id_1_created_at_-1", "likes_-1"} {
+		_, err := p.collection.Indexes().DropOne(ctx, name)
+		var cmdErr mongo.CommandError
+		if err != nil && !(errors.As(err, &cmdErr) && cmdErr.Name == "IndexNotFound") {
+			return err
+		}
+	}
 
+	_, err = p.reactions.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "post_id", Value: 1}, {Key: "user_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
 	if err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
-			return post, ErrNotFound
-		}
-		return post, err
+		return err
 	}
 
-	doc_bytes, err := bson.Marshal(doc)
+	_, err = p.revisions.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "post_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}},
+	})
 	if err != nil {
-		return post, err
+		return err
 	}
-	err = bson.Unmarshal(doc_bytes, &post)
+
+	_, err = p.comments.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "post_id", Value: 1}, {Key: "parent_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "post_id", Value: 1}, {Key: "parent_id", Value: 1}, {Key: "replies", Value: -1}, {Key: "_id", Value: -1}}},
+	})
 	if err != nil {
-		return post, err
+		return err
 	}
 
-	return post, nil
+	_, err = p.emojis.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "target_id", Value: 1}, {Key: "user_id", Value: 1}, {Key: "emoji", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "target_id", Value: 1}, {Key: "emoji", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "post_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = p.lists.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "name", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "items.post_id", Value: 1}}},
+	})
+	return err
 }
 
-func (p *Post) Validate() error {
-	if len(p.Title) == 0 {
-		return errors.New("post title cannot be empty")
+func (p PostModels) AddPost(post *Post) error {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
+	defer cancel()
+
+	if err := post.RenderContent(); err != nil {
+		return err
 	}
-	if len(p.Content) == 0 {
-		return errors.New("post content cannot be empty")
+
+	post.ID = primitive.NewObjectID()
+	post.CreatedAt = time.Now()
+	post.UpdatedAt = post.CreatedAt
+
+	if _, err := p.collection.InsertOne(ctx, post); err != nil {
+		return err
 	}
-	return nil
+	return p.addRevision(ctx, nil, post)
 }
 
-// creating a child context or context.TODO()
-
-// make sure title and content are not empty
-func (p PostModels) UpdatePost(post *Post) error {
+func (p PostModels) GetPost(postid primitive.ObjectID) (Post, error) {
+	var post Post
 
-	ctx, cancel := context.WithTimeout(context.Background(), context_timeout)
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
 	defer cancel()
 
-	options := options.Update()
-	filter := bson.D{{Key: "_id", Value: post.ID}}
-	update := bson.D{
-		{Key: "$set", Value: bson.D{
-			{Key: "post_title", Value: post.Title}}},
-		{Key: "$set", Value: bson.D{
-			{Key: "content", Value: post.Content}}}}
+	err := p.collection.FindOne(ctx, bson.D{{Key: "_id", Value: postid}}).Decode(&post)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return post, ErrNotFound
+		}
+		return post, err
+	}
 
-	result, err := p.collection.UpdateOne(ctx, filter, update, options)
+	return post, nil
+}
 
-	if err != nil {
+// make sure the post is valid, see Validate. a change of title or content is
+// kept as a revision. the post is only saved if nobody else saved it since it
+// was read, going by UpdatedAt, ErrEditConflict otherwise
+func (p PostModels) UpdatePost(post *Post) error {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
+	defer cancel()
+
+	if err := post.RenderContent(); err != nil {
 		return err
 	}
+	read := post.UpdatedAt
+	//as stored, so the post can be saved again
+	post.UpdatedAt = time.Now().Truncate(time.Millisecond)
 
-	if result.MatchedCount == 0 {
-		return ErrNotFound
+	filter := bson.D{{Key: "_id", Value: post.ID}, {Key: "updated_at", Value: read}}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "title", Value: post.Title},
+			{Key: "content", Value: post.Content},
+			{Key: "content_html", Value: post.ContentHTML},
+			{Key: "tags", Value: post.Tags},
+			{Key: "category", Value: post.Category},
+			{Key: "status", Value: post.Status},
+			{Key: "visibility", Value: post.Visibility},
+			{Key: "publish_at", Value: post.PublishAt},
+			{Key: "updated_at", Value: post.UpdatedAt},
+		}}}
+
+	var before Post
+	opts := options.FindOneAndUpdate().SetProjection(bson.D{
+		{Key: "author_id", Value: 1},
+		{Key: "title", Value: 1},
+		{Key: "content", Value: 1},
+		{Key: "updated_at", Value: 1},
+	})
+	err := p.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&before)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return err
+		}
+		//tell apart a deleted post from one saved meanwhile
+		n, err := p.collection.CountDocuments(ctx, bson.D{{Key: "_id", Value: post.ID}})
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrNotFound
+		}
+		return ErrEditConflict
 	}
 
-	return nil
+	if before.Title == post.Title && before.Content == post.Content {
+		return nil
+	}
+	return p.addRevision(ctx, &before, post)
 }
 
+// removes the post with its reactions, revisions and comments
 func (p PostModels) DeletePost(postid primitive.ObjectID) error {
-	ctx, cancel := context.WithTimeout(context.Background(), context_timeout)
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
 	defer cancel()
 
-	opts := options.Delete()
-	filter := bson.D{{Key: "_id", Value: postid}}
-	res, err := p.collection.DeleteOne(ctx, filter, opts)
-
+	res, err := p.collection.DeleteOne(ctx, bson.D{{Key: "_id", Value: postid}})
+	if err != nil {
+		return err
+	}
 	if res.DeletedCount == 0 {
 		return ErrNotFound
 	}
 
-	return err
-}
-
-func GetFeaturedPosts(offset uint64) []Posts {
-	var postMetaData []Posts
-	limit := 3
-	sql := "SELECT post_id,post_title FROM posts ORDER BY post_likes DESC OFFSET ? LIMIT ?"
-	db.Raw(sql, offset, limit).Scan(&postMetaData)
-	return postMetaData
-}
-func GetAllPostsMetaData() []Posts {
-	var posts []Posts
-	db.Raw(`SELECT post_id,post_title,author_name,post_likes FROM posts ORDER BY post_likes DESC`).Scan(&posts)
-	return posts
-}
-func CheckPostTitleExists(posttitle string) (bool, error) {
-	var exists bool
-	r := db.Raw("SELECT EXISTS (SELECT 1 FROM posts WHERE post_title = ?)", posttitle).Scan(&exists)
-	if r.Error != nil {
-		return exists, r.Error
+	_, err = p.reactions.DeleteMany(ctx, bson.D{{Key: "post_id", Value: postid}})
+	if err != nil {
+		return err
 	}
-	return exists, nil
-}
-
-func GetPostsByAuthorID(authorid uint64, limit uint64, offset uint64) []Posts {
-	var posts []Posts
-	db.Raw(`SELECT 
-	post_id,post_title,author_name,post_likes,createdat 
-	FROM posts WHERE author_id=? 
-	ORDER BY post_likes DESC LIMIT ? OFFSET ?`, authorid, limit, offset*limit).Scan(&posts)
-	return posts
-}
 
-func GetPostsMetaData(offset uint64, limit uint64) []Posts {
-	var posts []Posts
-	db.Raw(`SELECT post_id,post_title,
-	author_name,post_likes 
-	FROM posts ORDER BY post_likes DESC LIMIT ? OFFSET ?`, limit, offset*limit).Scan(&posts)
-	return posts
-}
-
-// func GetPostAndUserPreferences(postid uint64, userid uint64) (Posts, error) {
-// 	var post Posts
-// 	r := db.Raw("SELECT * FROM posts WHERE post_id=?", postid).Scan(&post)
-// 	if r.Error != nil {
-// 		return post,r.Error
-// 	}
-// 	return post, nil
-// }
-
-func CheckUserReaction(userid uint64, postid uint64) (bool, bool, error) {
-	var userLikedPost bool
-	var userDislikedPost bool
-	var count1 int
-	var count2 int
-	var err error
-	r := db.Raw("SELECT COUNT(*) from posts_liked_by_users WHERE user_id=? AND post_id=?", userid, postid).Scan(&count1)
-	if r.Error != nil {
-		return userLikedPost, userDislikedPost, r.Error
-	}
-	r = db.Raw("SELECT COUNT(*) FROM posts_disliked_by_users WHERE user_id=? AND post_id=?", userid, postid).Scan(&count2)
-	if r.Error != nil {
-		return userLikedPost, userDislikedPost, r.Error
+	_, err = p.revisions.DeleteMany(ctx, bson.D{{Key: "post_id", Value: postid}})
+	if err != nil {
+		return err
 	}
 
-	if count1 == 1 && count2 == 2 {
-		return userLikedPost, userDislikedPost, errors.New("illegalState:User reaction like and dislike both exists.check CheckUserReaction() function")
+	_, err = p.comments.DeleteMany(ctx, bson.D{{Key: "post_id", Value: postid}})
+	if err != nil {
+		return err
 	}
 
-	if count1 == 1 {
-		userLikedPost = true
-		userDislikedPost = false
-	} else if count2 == 1 {
-		userLikedPost = false
-		userDislikedPost = true
+	_, err = p.emojis.DeleteMany(ctx, bson.D{{Key: "post_id", Value: postid}})
+	if err != nil {
+		return err
 	}
-	return userLikedPost, userDislikedPost, err
+
+	return p.removeFromReadingLists(ctx, postid)
 }
 
-func LikePost(postid uint64, userid uint64) error {
-	tx := db.Begin()
-	r := tx.Exec("INSERT INTO posts_liked_by_users(user_id,post_id) VALUES(?,?) ", userid, postid)
-	if r.Error != nil {
-		tx.Rollback()
-		return r.Error
-	} else {
-		tx.Commit()
+func (p PostModels) find(filter bson.D, opts *options.FindOptions) ([]Post, error) {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
+	defer cancel()
+
+	cursor, err := p.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
 	}
 
-	tx = db.Begin()
-	r = tx.Exec("UPDATE posts SET post_likes= post_likes + 1 WHERE post_id=?", postid)
-	if r.Error != nil {
-		tx.Rollback()
-		return r.Error
-	} else {
-		tx.Commit()
+	posts := []Post{}
+	if err := cursor.All(ctx, &posts); err != nil {
+		return nil, err
 	}
-	return nil
+	return posts, nil
 }
 
-func DislikePost(postid uint64, userid uint64) error {
-	tx := db.Begin()
-	r := tx.Exec("INSERT INTO posts_disliked_by_users(user_id,post_id) VALUES(?,?) ", userid, postid)
-	if r.Error != nil {
-		tx.Rollback()
-		return r.Error
-	} else {
-		tx.Commit()
-	}
+// most liked first
+func (p PostModels) GetPostsMetaData(page Page) ([]Post, bool, error) {
+	return p.findPage(listed(bson.D{}), sortLikes, page)
+}
 
-	tx = db.Begin()
-	r = tx.Exec("UPDATE posts SET post_likes=post_likes - 1 WHERE post_id=?", postid)
-	if r.Error != nil {
-		tx.Rollback()
-		return r.Error
-	} else {
-		tx.Commit()
-	}
-	return nil
+func (p PostModels) GetFeaturedPosts(offset int64) ([]Post, error) {
+	var limit int64 = 3
+	opts := options.Find().
+		SetProjection(bson.D{{Key: "title", Value: 1}}).
+		SetSort(bson.D{{Key: "likes", Value: -1}}).
+		SetSkip(offset).
+		SetLimit(limit)
+	return p.find(listed(bson.D{}), opts)
 }
-func RemoveLikeFromPost(postid uint64, userid uint64) error {
-	tx := db.Begin()
-	r := tx.Exec("DELETE FROM posts_liked_by_users WHERE user_id=? AND post_id=?", userid, postid)
-	if r.Error != nil {
-		tx.Rollback()
-		return r.Error
-	} else {
-		tx.Commit()
-	}
 
-	tx = db.Begin()
-	r = tx.Exec("UPDATE posts SET post_likes=post_likes - 1 WHERE post_id=?", postid)
-	if r.Error != nil {
-		tx.Rollback()
-		return r.Error
-	} else {
-		tx.Commit()
+// most liked first. all of the author's posts with all, for the author
+// themselves, otherwise only the listed ones
+func (p PostModels) GetPostsByAuthorID(authorid uint64, all bool, page Page) ([]Post, bool, error) {
+	filter := bson.D{{Key: "author_id", Value: authorid}}
+	if !all {
+		filter = listed(filter)
 	}
-	return nil
+	return p.findPage(filter, sortLikes, page)
 }
 
-func RemoveDislikeFromPost(postid uint64, userid uint64) error {
-	tx := db.Begin()
-	r := tx.Exec("DELETE FROM posts_disliked_by_users WHERE user_id=? AND post_id=?", userid, postid)
-	if r.Error != nil {
-		tx.Rollback()
-		return r.Error
-	} else {
-		tx.Commit()
+// newest first
+func (p PostModels) GetPostsByAuthorIDs(authorids []uint64, page Page) ([]Post, bool, error) {
+	if len(authorids) == 0 {
+		return []Post{}, false, nil
 	}
 
-	tx = db.Begin()
-	r = tx.Exec("UPDATE posts SET post_likes=post_likes + 1 WHERE post_id=?", postid)
-	if r.Error != nil {
-		tx.Rollback()
-		return r.Error
-	} else {
-		tx.Commit()
+	filter := bson.D{{Key: "author_id", Value: bson.D{{Key: "$in", Value: authorids}}}}
+	return p.findPage(listed(filter), sortCreatedAt, page)
+}
+
+func (p PostModels) CheckPostTitleExists(title string) (bool, error) {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
+	defer cancel()
+
+	count, err := p.collection.CountDocuments(ctx, bson.D{{Key: "title", Value: title}}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
 	}
-	return nil
+	return count > 0, nil
 }
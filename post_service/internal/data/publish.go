@@ -0,0 +1,114 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	StatusDraft     = "draft"
+	StatusScheduled = "scheduled"
+	StatusPublished = "published"
+	StatusArchived  = "archived"
+)
+
+// unlisted posts can be read by anyone with the link but dont show up in
+// listings, private ones only by their author
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+// what listings show to everyone
+var listedFilter = bson.D{{Key: "status", Value: StatusPublished}, {Key: "visibility", Value: VisibilityPublic}}
+
+// what can be read and reacted to through a link
+var readableFilter = bson.D{{Key: "status", Value: StatusPublished}, {Key: "visibility", Value: bson.D{{Key: "$ne", Value: VisibilityPrivate}}}}
+
+func validStatus(status string) bool {
+	switch status {
+	case StatusDraft, StatusScheduled, StatusPublished, StatusArchived:
+		return true
+	}
+	return false
+}
+
+func validVisibility(visibility string) bool {
+	switch visibility {
+	case VisibilityPublic, VisibilityUnlisted, VisibilityPrivate:
+		return true
+	}
+	return false
+}
+
+func (p *Post) validateStatus() error {
+	if !validStatus(p.Status) {
+		return errors.New("post status must be draft, scheduled, published or archived")
+	}
+	if !validVisibility(p.Visibility) {
+		return errors.New("post visibility must be public, unlisted or private")
+	}
+	if p.Status == StatusScheduled && p.PublishAt == nil {
+		return errors.New("a scheduled post needs a publish time")
+	}
+	return nil
+}
+
+// publishes the post now, or schedules it when publishAt is in the future
+func (p *Post) Publish(publishAt time.Time) {
+	now := time.Now()
+	if publishAt.After(now) {
+		p.Status = StatusScheduled
+		p.PublishAt = &publishAt
+		return
+	}
+	p.Status = StatusPublished
+	p.PublishAt = &now
+}
+
+// drafts, scheduled, archived and private posts are only shown to their author
+func (p *Post) VisibleTo(userid uint64) bool {
+	if userid != 0 && p.AuthorID == userid {
+		return true
+	}
+	return p.Status == StatusPublished && p.Visibility != VisibilityPrivate
+}
+
+// posts saved before there were statuses were all public
+func (p PostModels) UpgradePosts() error {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
+	defer cancel()
+
+	_, err := p.collection.UpdateMany(ctx,
+		bson.D{{Key: "status", Value: bson.D{{Key: "$exists", Value: false}}}},
+		bson.A{bson.D{{Key: "$set", Value: bson.D{
+			{Key: "status", Value: StatusPublished},
+			{Key: "visibility", Value: VisibilityPublic},
+			{Key: "publish_at", Value: "$created_at"},
+		}}}})
+	return err
+}
+
+// publishes the scheduled posts that are due, returns how many
+func (p PostModels) PublishScheduled(now time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
+	defer cancel()
+
+	res, err := p.collection.UpdateMany(ctx,
+		bson.D{{Key: "status", Value: StatusScheduled}, {Key: "publish_at", Value: bson.D{{Key: "$lte", Value: now}}}},
+		//moves updated_at so an edit of a post read before fails, see UpdatePost
+		bson.D{{Key: "$set", Value: bson.D{{Key: "status", Value: StatusPublished}, {Key: "updated_at", Value: now}}}})
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+// filter narrowed to what listings show to everyone
+func listed(filter bson.D) bson.D {
+	return append(append(bson.D{}, listedFilter...), filter...)
+}
@@ -0,0 +1,193 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	ReactionLike    = "like"
+	ReactionDislike = "dislike"
+)
+
+// a full pass over posts and reactions takes longer than a query
+const reconcileTimeout = 10 * time.Minute
+
+// reaction of a user to a post, the unique index on post_id,user_id keeps it
+// to one per user and post. these documents are the source of truth, the
+// likes on a post are a count of them
+type Reaction struct {
+	PostID primitive.ObjectID `bson:"post_id"`
+	UserID uint64             `bson:"user_id"`
+	Kind   string             `bson:"kind"`
+}
+
+// what a reaction adds to the likes of a post
+func reactionValue(kind string) int64 {
+	switch kind {
+	case ReactionLike:
+		return 1
+	case ReactionDislike:
+		return -1
+	}
+	return 0
+}
+
+// a dislike turns into a like
+func (p PostModels) LikePost(postid primitive.ObjectID, userid uint64) error {
+	return p.react(postid, userid, ReactionLike)
+}
+
+// a like turns into a dislike
+func (p PostModels) DislikePost(postid primitive.ObjectID, userid uint64) error {
+	return p.react(postid, userid, ReactionDislike)
+}
+
+func (p PostModels) RemoveLikeFromPost(postid primitive.ObjectID, userid uint64) error {
+	return p.unreact(postid, userid, ReactionLike)
+}
+
+func (p PostModels) RemoveDislikeFromPost(postid primitive.ObjectID, userid uint64) error {
+	return p.unreact(postid, userid, ReactionDislike)
+}
+
+// sets the user's reaction to kind, whatever it was before, and moves the
+// likes of the post by the difference in the same transaction. posts that
+// cant be read through a link are not found
+func (p PostModels) react(postid primitive.ObjectID, userid uint64, kind string) error {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
+	defer cancel()
+
+	return p.transaction(ctx, func(ctx mongo.SessionContext) error {
+		var previous Reaction
+		err := p.reactions.FindOneAndUpdate(ctx,
+			bson.D{{Key: "post_id", Value: postid}, {Key: "user_id", Value: userid}},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "kind", Value: kind}}}},
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before),
+		).Decode(&previous)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return err
+		}
+		if previous.Kind == kind {
+			return ErrAlreadyReacted
+		}
+
+		//also checks the post can be read, a post deleted meanwhile
+		//conflicts with this write
+		filter := append(bson.D{{Key: "_id", Value: postid}}, readableFilter...)
+		res, err := p.collection.UpdateOne(ctx, filter,
+			bson.D{{Key: "$inc", Value: bson.D{{Key: "likes", Value: reactionValue(kind) - reactionValue(previous.Kind)}}}})
+		if err != nil {
+			return err
+		}
+		if res.MatchedCount == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// removes the user's reaction if it is of kind
+func (p PostModels) unreact(postid primitive.ObjectID, userid uint64, kind string) error {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
+	defer cancel()
+
+	return p.transaction(ctx, func(ctx mongo.SessionContext) error {
+		filter := bson.D{{Key: "post_id", Value: postid}, {Key: "user_id", Value: userid}, {Key: "kind", Value: kind}}
+		res, err := p.reactions.DeleteOne(ctx, filter)
+		if err != nil {
+			return err
+		}
+		if res.DeletedCount == 0 {
+			return ErrNotFound
+		}
+
+		_, err = p.collection.UpdateOne(ctx,
+			bson.D{{Key: "_id", Value: postid}},
+			bson.D{{Key: "$inc", Value: bson.D{{Key: "likes", Value: -reactionValue(kind)}}}})
+		return err
+	})
+}
+
+// recomputes the likes and emoji counts of every post and comment from the
+// reactions and fixes the ones that are off, returns how many
+func (p PostModels) ReconcileReactionCounts() (int64, error) {
+	ctx, cancel := context.WithTimeout(p.ctx, reconcileTimeout)
+	defer cancel()
+
+	fixed, err := p.reconcileLikes(ctx)
+	if err != nil {
+		return fixed, err
+	}
+	for _, targetType := range []string{TargetPost, TargetComment} {
+		n, err := p.reconcileEmojiCounts(ctx, targetType)
+		fixed += n
+		if err != nil {
+			return fixed, err
+		}
+	}
+	return fixed, nil
+}
+
+// likes counted from the reactions of each post, only the posts that are off
+// come back. each post is read before its reactions and its likes are only
+// replaced if unchanged since, so a post reacted to in between is left for
+// the next run instead of being set to a stale count
+func (p PostModels) reconcileLikes(ctx context.Context) (int64, error) {
+	cursor, err := p.collection.Aggregate(ctx, []bson.D{
+		{{Key: "$project", Value: bson.D{{Key: "likes", Value: 1}}}},
+		{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: p.reactions.Name()},
+			{Key: "localField", Value: "_id"},
+			{Key: "foreignField", Value: "post_id"},
+			{Key: "as", Value: "counted"},
+			{Key: "pipeline", Value: bson.A{
+				bson.D{{Key: "$group", Value: bson.D{
+					{Key: "_id", Value: nil},
+					{Key: "likes", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$switch", Value: bson.D{
+						{Key: "branches", Value: bson.A{
+							bson.D{{Key: "case", Value: bson.D{{Key: "$eq", Value: bson.A{"$kind", ReactionLike}}}}, {Key: "then", Value: 1}},
+							bson.D{{Key: "case", Value: bson.D{{Key: "$eq", Value: bson.A{"$kind", ReactionDislike}}}}, {Key: "then", Value: -1}},
+						}},
+						{Key: "default", Value: 0},
+					}}}}}},
+				}}},
+			}},
+		}}},
+		{{Key: "$set", Value: bson.D{{Key: "counted", Value: bson.D{{Key: "$ifNull", Value: bson.A{
+			bson.D{{Key: "$first", Value: "$counted.likes"}}, 0,
+		}}}}}}},
+		{{Key: "$match", Value: bson.D{{Key: "$expr", Value: bson.D{{Key: "$ne", Value: bson.A{"$likes", "$counted"}}}}}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var fixed int64
+	for cursor.Next(ctx) {
+		var count struct {
+			PostID  primitive.ObjectID `bson:"_id"`
+			Likes   int64              `bson:"likes"`
+			Counted int64              `bson:"counted"`
+		}
+		if err := cursor.Decode(&count); err != nil {
+			return fixed, err
+		}
+
+		res, err := p.collection.UpdateOne(ctx,
+			bson.D{{Key: "_id", Value: count.PostID}, {Key: "likes", Value: count.Likes}},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "likes", Value: count.Counted}}}})
+		if err != nil {
+			return fixed, err
+		}
+		fixed += res.ModifiedCount
+	}
+	return fixed, cursor.Err()
+}
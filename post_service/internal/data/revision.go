@@ -0,0 +1,98 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// title and content of a post as saved at one point, a revision is stored
+// whenever a post is created and whenever its title or content change
+type Revision struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	PostID    primitive.ObjectID `bson:"post_id" json:"post_id"`
+	AuthorID  uint64             `bson:"author_id" json:"author_id"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+
+	Title   string `bson:"title" json:"title"`
+	Content string `bson:"content,omitempty" json:"content,omitempty"`
+}
+
+func (r *Revision) Cursor(before bool) Cursor {
+	return Cursor{CreatedAt: r.CreatedAt, ID: r.ID, Before: before}
+}
+
+func newRevision(post *Post, at time.Time) Revision {
+	return Revision{
+		ID:        primitive.NewObjectID(),
+		PostID:    post.ID,
+		AuthorID:  post.AuthorID,
+		CreatedAt: at,
+		Title:     post.Title,
+		Content:   post.Content,
+	}
+}
+
+// stores the saved post as a revision. before is the post as it was, it is
+// stored first for posts from before there were revisions
+func (p PostModels) addRevision(ctx context.Context, before *Post, post *Post) error {
+	if before != nil {
+		n, err := p.revisions.CountDocuments(ctx, bson.D{{Key: "post_id", Value: post.ID}}, options.Count().SetLimit(1))
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			if _, err := p.revisions.InsertOne(ctx, newRevision(before, before.UpdatedAt)); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := p.revisions.InsertOne(ctx, newRevision(post, post.UpdatedAt))
+	return err
+}
+
+// newest first, without the content
+func (p PostModels) GetRevisions(postid primitive.ObjectID, page Page) ([]Revision, bool, error) {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
+	defer cancel()
+
+	filter := append(bson.D{{Key: "post_id", Value: postid}}, page.keyset(sortCreatedAt)...)
+	opts := options.Find().
+		SetProjection(bson.D{{Key: "content", Value: 0}}).
+		SetSort(bson.D{{Key: sortCreatedAt, Value: page.order()}, {Key: "_id", Value: page.order()}}).
+		SetLimit(page.Limit + 1)
+
+	cursor, err := p.revisions.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, false, err
+	}
+
+	revisions := []Revision{}
+	if err := cursor.All(ctx, &revisions); err != nil {
+		return nil, false, err
+	}
+
+	revisions, more := trimPage(revisions, page)
+	return revisions, more, nil
+}
+
+func (p PostModels) GetRevision(postid primitive.ObjectID, revisionid primitive.ObjectID) (Revision, error) {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
+	defer cancel()
+
+	var revision Revision
+	err := p.revisions.FindOne(ctx, bson.D{{Key: "_id", Value: revisionid}, {Key: "post_id", Value: postid}}).Decode(&revision)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return revision, ErrNotFound
+		}
+		return revision, err
+	}
+	return revision, nil
+}
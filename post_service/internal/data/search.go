@@ -0,0 +1,86 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// relevance of a post for a text search, computed by mongodb
+const sortScore = "score"
+
+type SearchQuery struct {
+	// mongodb $text syntax: words, "phrases" and -excluded words
+	Text string
+	// 0 for every author
+	AuthorID uint64
+	// normalized, empty for any
+	Tag string
+	// created_at range, zero for open ends. To is exclusive
+	From time.Time
+	To   time.Time
+}
+
+// post with its content, for the snippet, and the relevance score
+type SearchResult struct {
+	Post  `bson:",inline"`
+	Score float64 `bson:"score" json:"score"`
+}
+
+func (s *SearchResult) Cursor(before bool) Cursor {
+	c := s.Post.Cursor(before)
+	c.Score = s.Score
+	return c
+}
+
+// most relevant first, title matches weigh more than content matches
+func (p PostModels) SearchPosts(query SearchQuery, page Page) ([]SearchResult, bool, error) {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
+	defer cancel()
+
+	match := listed(bson.D{{Key: "$text", Value: bson.D{{Key: "$search", Value: query.Text}}}})
+	if query.AuthorID != 0 {
+		match = append(match, bson.E{Key: "author_id", Value: query.AuthorID})
+	}
+	if query.Tag != "" {
+		match = append(match, bson.E{Key: "tags", Value: query.Tag})
+	}
+	created := bson.D{}
+	if !query.From.IsZero() {
+		created = append(created, bson.E{Key: "$gte", Value: query.From})
+	}
+	if !query.To.IsZero() {
+		created = append(created, bson.E{Key: "$lt", Value: query.To})
+	}
+	if len(created) != 0 {
+		match = append(match, bson.E{Key: "created_at", Value: created})
+	}
+
+	//$text has to be in the first stage, the score only exists after it
+	pipeline := []bson.D{
+		{{Key: "$match", Value: match}},
+		{{Key: "$project", Value: bson.D{{Key: "content_html", Value: 0}}}},
+		{{Key: "$addFields", Value: bson.D{{Key: sortScore, Value: bson.D{{Key: "$meta", Value: "textScore"}}}}}},
+	}
+	if keyset := page.keyset(sortScore); keyset != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: keyset}})
+	}
+	pipeline = append(pipeline,
+		bson.D{{Key: "$sort", Value: bson.D{{Key: sortScore, Value: page.order()}, {Key: "_id", Value: page.order()}}}},
+		bson.D{{Key: "$limit", Value: page.Limit + 1}},
+	)
+
+	cursor, err := p.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, false, err
+	}
+
+	results := []SearchResult{}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, false, err
+	}
+
+	results, more := trimPage(results, page)
+	return results, more, nil
+}
@@ -0,0 +1,123 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	MaxTags      = 10
+	maxTagLength = 32
+)
+
+var ErrInvalidTag = fmt.Errorf("tags and categories may only contain letters, digits, - and _ and be up to %d characters long.", maxTagLength)
+
+// how often a tag is used
+type TagCount struct {
+	Tag   string `bson:"_id" json:"tag"`
+	Posts int64  `bson:"posts" json:"posts"`
+}
+
+// lower case with inner spaces as -, so "Go Lang" and "go-lang" are the same
+// tag. used for categories too
+func NormalizeTag(s string) (string, error) {
+	tag := strings.ToLower(strings.Join(strings.Fields(s), "-"))
+	if tag == "" || len([]rune(tag)) > maxTagLength {
+		return "", ErrInvalidTag
+	}
+	for _, c := range tag {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '-' && c != '_' {
+			return "", ErrInvalidTag
+		}
+	}
+	return tag, nil
+}
+
+// normalized tags without duplicates, in the order given
+func NormalizeTags(tags []string) ([]string, error) {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, s := range tags {
+		tag, err := NormalizeTag(s)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	if len(normalized) > MaxTags {
+		return nil, fmt.Errorf("a post can have at most %d tags.", MaxTags)
+	}
+	return normalized, nil
+}
+
+// most liked first, tag has to be normalized
+func (p PostModels) GetPostsByTag(tag string, page Page) ([]Post, bool, error) {
+	return p.findPage(listed(bson.D{{Key: "tags", Value: tag}}), sortLikes, page)
+}
+
+// most liked first, category has to be normalized
+func (p PostModels) GetPostsByCategory(category string, page Page) ([]Post, bool, error) {
+	return p.findPage(listed(bson.D{{Key: "category", Value: category}}), sortLikes, page)
+}
+
+// most used first, counting listed posts only
+func (p PostModels) GetPopularTags(limit int64) ([]TagCount, error) {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
+	defer cancel()
+
+	cursor, err := p.collection.Aggregate(ctx, []bson.D{
+		{{Key: "$match", Value: listed(bson.D{{Key: "tags.0", Value: bson.D{{Key: "$exists", Value: true}}}})}},
+		{{Key: "$project", Value: bson.D{{Key: "tags", Value: 1}}}},
+		{{Key: "$unwind", Value: "$tags"}},
+		{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$tags"}, {Key: "posts", Value: bson.D{{Key: "$sum", Value: 1}}}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "posts", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tags := []TagCount{}
+	if err := cursor.All(ctx, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// replaces tag from with to on every post, a merge when to is in use
+// already. returns the number of posts changed, both have to be normalized.
+// updated_at moves so an edit of a post read before fails, see UpdatePost
+func (p PostModels) RenameTag(from string, to string) (int64, error) {
+	ctx, cancel := context.WithTimeout(p.ctx, context_timeout)
+	defer cancel()
+
+	now := time.Now()
+
+	//posts with both only lose the old one, tags stay free of duplicates
+	merged, err := p.collection.UpdateMany(ctx,
+		bson.D{{Key: "tags", Value: bson.D{{Key: "$all", Value: bson.A{from, to}}}}},
+		bson.D{
+			{Key: "$pull", Value: bson.D{{Key: "tags", Value: from}}},
+			{Key: "$set", Value: bson.D{{Key: "updated_at", Value: now}}},
+		})
+	if err != nil {
+		return 0, err
+	}
+
+	renamed, err := p.collection.UpdateMany(ctx,
+		bson.D{{Key: "tags", Value: from}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "tags.$", Value: to}, {Key: "updated_at", Value: now}}}})
+	if err != nil {
+		return merged.ModifiedCount, err
+	}
+
+	return merged.ModifiedCount + renamed.ModifiedCount, nil
+}
@@ -0,0 +1,99 @@
+package diff
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+const (
+	OpEqual  = "equal"
+	OpInsert = "insert"
+	OpDelete = "delete"
+)
+
+// piece of a word diff, joining the texts of the equal and insert segments
+// gives the new text, of the equal and delete ones the old text
+type Segment struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// unified diff of the lines with 3 lines of context, empty when a and b are
+// the same
+func Unified(a, b, fromName, toName string) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(a),
+		B:        splitLines(b),
+		FromFile: fromName,
+		ToFile:   toName,
+		Context:  3,
+	})
+}
+
+// word level diff, whitespace counts as its own words
+func Words(a, b string) []Segment {
+	wa, wb := splitWords(a), splitWords(b)
+
+	//without autojunk, spaces are too common and would never match
+	m := difflib.NewMatcherWithJunk(wa, wb, false, nil)
+
+	segments := []Segment{}
+	add := func(op string, words []string) {
+		text := strings.Join(words, "")
+		if text == "" {
+			return
+		}
+		if n := len(segments); n != 0 && segments[n-1].Op == op {
+			segments[n-1].Text += text
+			return
+		}
+		segments = append(segments, Segment{Op: op, Text: text})
+	}
+	for _, op := range m.GetOpCodes() {
+		switch op.Tag {
+		case 'e':
+			add(OpEqual, wa[op.I1:op.I2])
+		case 'd':
+			add(OpDelete, wa[op.I1:op.I2])
+		case 'i':
+			add(OpInsert, wb[op.J1:op.J2])
+		case 'r':
+			add(OpDelete, wa[op.I1:op.I2])
+			add(OpInsert, wb[op.J1:op.J2])
+		}
+	}
+	return segments
+}
+
+// lines with their newline, the last one gets one too so it prints on a line
+// of its own
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+	lines[len(lines)-1] += "\n"
+	return lines
+}
+
+// runs of whitespace and of everything else, in order
+func splitWords(s string) []string {
+	var words []string
+	start, space := 0, false
+	for i, c := range s {
+		if i == 0 {
+			space = unicode.IsSpace(c)
+			continue
+		}
+		if unicode.IsSpace(c) != space {
+			words = append(words, s[start:i])
+			start, space = i, !space
+		}
+	}
+	if start < len(s) {
+		words = append(words, s[start:])
+	}
+	return words
+}
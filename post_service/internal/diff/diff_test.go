@@ -0,0 +1,150 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitLines(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want []string
+	}{
+		{"empty", "", []string{}},
+		{"one line", "a\n", []string{"a\n"}},
+		{"no trailing newline", "a\nb", []string{"a\n", "b\n"}},
+		{"only a newline", "\n", []string{"\n"}},
+		{"blank lines", "a\n\n\nb\n", []string{"a\n", "\n", "\n", "b\n"}},
+		{"crlf", "a\r\nb\r\n", []string{"a\r\n", "b\r\n"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitLines(tt.s); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitLines(%q) = %q, want %q", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitWords(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want []string
+	}{
+		{"empty", "", nil},
+		{"one word", "a", []string{"a"}},
+		{"only whitespace", " \t\n", []string{" \t\n"}},
+		{"leading and trailing", "  a b ", []string{"  ", "a", " ", "b", " "}},
+		{"punctuation stays with the word", "hi, there.", []string{"hi,", " ", "there."}},
+		{"multibyte", "héllo wörld", []string{"héllo", " ", "wörld"}},
+		{"unicode space", "a\u00a0b", []string{"a", "\u00a0", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitWords(tt.s)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitWords(%q) = %q, want %q", tt.s, got, tt.want)
+			}
+			if strings.Join(got, "") != tt.s {
+				t.Errorf("words of %q dont join back to it", tt.s)
+			}
+		})
+	}
+}
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Segment
+	}{
+		{"both empty", "", "", []Segment{}},
+		{"from empty", "", "hello world", []Segment{{OpInsert, "hello world"}}},
+		{"to empty", "hello world", "", []Segment{{OpDelete, "hello world"}}},
+		{"same", "hello world", "hello world", []Segment{{OpEqual, "hello world"}}},
+		{"word replaced", "the quick fox", "the slow fox", []Segment{
+			{OpEqual, "the "}, {OpDelete, "quick"}, {OpInsert, "slow"}, {OpEqual, " fox"},
+		}},
+		{"word added", "the fox", "the quick fox", []Segment{
+			{OpEqual, "the "}, {OpInsert, "quick "}, {OpEqual, "fox"},
+		}},
+		{"whitespace only", "hello world", "hello  world", []Segment{
+			{OpEqual, "hello"}, {OpDelete, " "}, {OpInsert, "  "}, {OpEqual, "world"},
+		}},
+		{"newline for a space", "hello world", "hello\nworld", []Segment{
+			{OpEqual, "hello"}, {OpDelete, " "}, {OpInsert, "\n"}, {OpEqual, "world"},
+		}},
+		{"trailing newline added", "a", "a\n", []Segment{{OpEqual, "a"}, {OpInsert, "\n"}}},
+		{"multibyte", "grüße dich", "grüße euch", []Segment{
+			{OpEqual, "grüße "}, {OpDelete, "dich"}, {OpInsert, "euch"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Words(tt.a, tt.b)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Words(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+			}
+
+			var oldText, newText strings.Builder
+			for _, s := range got {
+				if s.Op != OpInsert {
+					oldText.WriteString(s.Text)
+				}
+				if s.Op != OpDelete {
+					newText.WriteString(s.Text)
+				}
+			}
+			if oldText.String() != tt.a || newText.String() != tt.b {
+				t.Errorf("segments give %q and %q, want %q and %q", oldText.String(), newText.String(), tt.a, tt.b)
+			}
+		})
+	}
+}
+
+func TestUnified(t *testing.T) {
+	numbered := func(from, to int, changed int) string {
+		var b strings.Builder
+		for i := from; i <= to; i++ {
+			if i == changed {
+				b.WriteString("changed\n")
+				continue
+			}
+			b.WriteString(strings.Repeat("x", i) + "\n")
+		}
+		return b.String()
+	}
+
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{"both empty", "", "", ""},
+		{"same", "a\nb\n", "a\nb\n", ""},
+		{"from empty", "", "a\n", "--- old\n+++ new\n@@ -0,0 +1 @@\n+a\n"},
+		{"to empty", "a\n", "", "--- old\n+++ new\n@@ -1 +0,0 @@\n-a\n"},
+		{"line changed", "a\nb\nc\n", "a\nB\nc\n", "--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n"},
+		//the last line still ends up on a line of its own
+		{"no trailing newline", "a\nb", "a\nc", "--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n"},
+		{"only the trailing newline", "a\nb", "a\nb\n", ""},
+		{"trailing whitespace", "a\nb\n", "a\nb \n", "--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\n-b\n+b \n"},
+		{"blank line added", "a\nb\n", "a\n\nb\n", "--- old\n+++ new\n@@ -1,2 +1,3 @@\n a\n+\n b\n"},
+		{"three lines of context", numbered(1, 10, 0), numbered(1, 10, 5),
+			"--- old\n+++ new\n@@ -2,7 +2,7 @@\n xx\n xxx\n xxxx\n-xxxxx\n+changed\n xxxxxx\n xxxxxxx\n xxxxxxxx\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Unified(tt.a, tt.b, "old", "new")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Unified(%q, %q) =\n%s\nwant\n%s", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package markdown
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// commonmark with the github tables, strikethrough and autolinks. raw html
+// in the source is left out, code fences get a language-<name> class for
+// client side highlighting
+var md = goldmark.New(
+	goldmark.WithExtensions(
+		extension.Table,
+		extension.Strikethrough,
+		extension.Linkify,
+	),
+)
+
+// the renderer already drops raw html and javascript: links, the policy is
+// there for whatever it misses
+var policy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#-]+$`)).OnElements("code")
+	return p
+}()
+
+// html of the markdown source, safe to put into a page as it is
+func Render(source string) (string, error) {
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return policy.Sanitize(buf.String()), nil
+}
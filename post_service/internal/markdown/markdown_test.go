@@ -0,0 +1,118 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "script",
+			source:  "hi <script>alert(1)</script> there",
+			notWant: []string{"<script"},
+		},
+		{
+			name:    "javascript link",
+			source:  "[x](javascript:alert(1))",
+			want:    []string{"<p>x</p>"},
+			notWant: []string{"javascript:", "href"},
+		},
+		{
+			name:    "javascript link mixed case",
+			source:  "[x](JaVaScRiPt:alert(1))",
+			notWant: []string{"alert", "href"},
+		},
+		{
+			name:    "javascript html link",
+			source:  `<a href="javascript:alert(1)">x</a>`,
+			notWant: []string{"javascript:", "href"},
+		},
+		{
+			name:    "javascript image",
+			source:  "![x](javascript:alert(1))",
+			notWant: []string{"javascript:", "src"},
+		},
+		{
+			name:    "event handler",
+			source:  "*a* <b onmouseover=alert(1)>bold</b>",
+			want:    []string{"<em>a</em>"},
+			notWant: []string{"onmouseover", "alert"},
+		},
+		{
+			name:    "event handler on image",
+			source:  "<img src=x onerror=alert(1)>",
+			notWant: []string{"onerror", "<img"},
+		},
+		{
+			name:    "raw html block",
+			source:  `<div onclick="alert(1)">x</div>`,
+			notWant: []string{"<div", "onclick"},
+		},
+		{
+			name:    "iframe",
+			source:  `<iframe src="https://example.com"></iframe>`,
+			notWant: []string{"<iframe"},
+		},
+		{
+			name:   "link",
+			source: "[ok](https://example.com)",
+			want:   []string{`<a href="https://example.com" rel="nofollow">ok</a>`},
+		},
+		{
+			name:   "code language",
+			source: "```go\nfmt.Println()\n```",
+			want:   []string{`<pre><code class="language-go">fmt.Println()`},
+		},
+		{
+			name:   "code language with symbols",
+			source: "```c++\nx\n```",
+			want:   []string{`<code class="language-c++">`},
+		},
+		{
+			name:    "code info string attribute",
+			source:  "```\" onclick=\"alert(1)\nx\n```",
+			want:    []string{"<pre><code>x"},
+			notWant: []string{"onclick", "class"},
+		},
+		{
+			name:    "class on other elements",
+			source:  `<p class="language-go">x</p>`,
+			notWant: []string{"class"},
+		},
+		{
+			name:   "table",
+			source: "| a | b |\n|---|---|\n| 1 | 2 |",
+			want:   []string{"<table>", "<th>a</th>", "<td>2</td>"},
+		},
+		{
+			name:   "strikethrough",
+			source: "~~gone~~",
+			want:   []string{"<del>gone</del>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("got %q, want it to contain %q", got, want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(got, notWant) {
+					t.Errorf("got %q, want it without %q", got, notWant)
+				}
+			}
+		})
+	}
+}
@@ -2,75 +2,145 @@ package api
 
 import (
 	"errors"
-	"os"
-	"strconv"
+	"io/fs"
+	"time"
 
 	"github.com/joho/godotenv"
+
+	"common/config"
 )
 
 // Application Configuraton
 type configuration struct {
-	Host       string
-	DBName     string
-	DBUsername string
-	DBPassword string
+	Host       string `config:"host" validate:"required"`
+	DBName     string `config:"dbname"`
+	DBUsername string `config:"dbusername"`
+	DBPassword string `config:"dbpassword" secret:"true"`
+	DBPort     string `config:"dbport"`
+
+	// postgres, mysql or sqlite
+	DBDriver string `config:"db_driver" default:"postgres" validate:"oneof=postgres mysql sqlite"`
+	// database file when DBDriver is sqlite
+	DBPath string `config:"db_path" default:"user_service.db"`
+
+	BCryptCost int `config:"bcrypt_cost" default:"10" validate:"min=4,max=31"`
+
+	// shared by every service, so JWT_KEY has no prefix
+	JWTkey string `config:"jwt_key" env:"JWT_KEY" secret:"true" validate:"required"`
+
+	// apply pending schema migrations when the server starts
+	MigrateOnStart bool `config:"migrate_on_start" default:"false"`
+
+	// base url used in links sent to users, defaults to http://host
+	PublicURL string `config:"public_url"`
+
+	SMTPHost     string `config:"smtp_host"`
+	SMTPPort     int    `config:"smtp_port" default:"587" validate:"min=1,max=65535"`
+	SMTPUsername string `config:"smtp_username"`
+	SMTPPassword string `config:"smtp_password" secret:"true"`
+	SMTPSender   string `config:"smtp_sender"`
+
+	// directory profile pictures are written to
+	ImagesDir string `config:"images_dir" default:"/home/core/go/kl/user_service/store/images/" validate:"required"`
+
+	// address the http server listens on
+	HTTPAddr        string        `config:"http_addr" default:":8080" validate:"required"`
+	ReadTimeout     time.Duration `config:"read_timeout" default:"10s"`
+	WriteTimeout    time.Duration `config:"write_timeout" default:"30s"`
+	IdleTimeout     time.Duration `config:"idle_timeout" default:"2m"`
+	DrainDelay      time.Duration `config:"drain_delay" default:"5s"`
+	ShutdownTimeout time.Duration `config:"shutdown_timeout" default:"20s"`
+	// https is served when both are set
+	TLSCertFile string `config:"tls_cert_file"`
+	TLSKeyFile  string `config:"tls_key_file"`
 
-	BCryptCost int
-	JWTkey     string
+	// internal grpc api for the other services, keep it off the public network
+	GRPCAddr           string        `config:"grpc_addr" default:":9090" validate:"required"`
+	GRPCRequestTimeout time.Duration `config:"grpc_request_timeout" default:"5s"`
+
+	LogLevel string `config:"log_level" default:"info" validate:"oneof=debug info warn error"`
+
+	// check responses against openapi.yaml too, for tests and staging
+	OpenAPIValidateResponses bool `config:"openapi_validate_responses" default:"false"`
+
+	// how long responses to requests with an Idempotency-Key are replayed
+	IdempotencyTTL time.Duration `config:"idempotency_ttl" default:"24h"`
+	// a key whose request never finished is free again after this
+	IdempotencyLockTimeout time.Duration `config:"idempotency_lock_timeout" default:"1m"`
+
+	// per check limit for /readyz
+	HealthCheckTimeout time.Duration `config:"health_check_timeout" default:"2s"`
+	// health urls of services this one depends on, checked by /readyz
+	DownstreamURLs []string `config:"downstream_urls"`
+
+	// none, stdout or otlp
+	TracingExporter  string  `config:"tracing_exporter" default:"none" validate:"oneof=none stdout otlp"`
+	OTLPEndpoint     string  `config:"otlp_endpoint" default:"localhost:4318"`
+	OTLPInsecure     bool    `config:"otlp_insecure" default:"true"`
+	TraceSampleRatio float64 `config:"trace_sample_ratio" default:"1" validate:"min=0,max=1"`
 }
 
 var Config = configuration{}
 
-var defaultBcryptCost = 3
+// checks that depend on more than one field
+func (c *configuration) Validate() error {
+	var errs config.Errors
 
-func LoadEnvVars() error {
-	err := godotenv.Load(".env")
-	if err != nil {
-		log.Error("Couldnt load .env: ", err)
-		return err
+	if c.DBDriver != "sqlite" {
+		if c.DBName == "" {
+			errs = append(errs, errors.New("dbname: is required for db_driver "+c.DBDriver))
+		}
+		if c.DBUsername == "" {
+			errs = append(errs, errors.New("dbusername: is required for db_driver "+c.DBDriver))
+		}
 	}
-	var present bool
-
-	Config.DBName, present = os.LookupEnv("dbname")
-	if !present {
-		log.Error("dbname not found in .env file")
-		return errors.New("read .env:unsuccessfull")
+	if c.SMTPHost != "" && c.SMTPSender == "" {
+		errs = append(errs, errors.New("smtp_sender: is required when smtp_host is set"))
 	}
-	Config.DBUsername, present = os.LookupEnv("dbusername")
-	if !present {
-		log.Error("dbusername not found in .env file")
-		return errors.New("read .env:unsuccessfull")
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		errs = append(errs, errors.New("tls_cert_file, tls_key_file: set both or neither"))
 	}
-	Config.DBPassword, present = os.LookupEnv("dbpassword")
-	if !present {
-		log.Error("dbpassword not found in .env file")
-		return errors.New("read .env:unsuccessfull")
+	if c.IdempotencyTTL <= 0 {
+		errs = append(errs, errors.New("idempotency_ttl: must be positive"))
 	}
-	Config.Host, present = os.LookupEnv("host")
-	if !present {
-		log.Error("host not found in .env file")
-		return errors.New("read .env:unsuccessfull")
+	if c.IdempotencyLockTimeout <= 0 {
+		errs = append(errs, errors.New("idempotency_lock_timeout: must be positive"))
 	}
 
-	Config.JWTkey, present = os.LookupEnv("jwt_key")
-	if !present {
-		log.Error("jwtkey not found in .env file")
-		return errors.New("read .env:unsuccessfull")
+	if len(errs) != 0 {
+		return errs
 	}
+	return nil
+}
 
-	bcoststr, present := os.LookupEnv("bcrypt_cost")
-	if !present {
-		//default value 3
-		Config.BCryptCost = defaultBcryptCost
-	} else {
+// loads configuration without command line flags, used by the cli tools
+func LoadEnvVars() error {
+	return LoadConfig(nil)
+}
 
-		bcost, err := strconv.Atoi(bcoststr)
-		if err != nil {
-			log.Error("Unable to convert bcryptcost(string) to int")
-			return err
-		}
-		Config.BCryptCost = bcost
+// merges defaults, the config file, .env/environment and flags into Config
+func LoadConfig(args []string) error {
+	//.env is optional, the environment may already be set
+	err := godotenv.Load(".env")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Error("couldnt load .env", "err", err)
+		return err
 	}
 
+	if err := config.Load(&Config, config.Options{Args: args, EnvPrefix: "USER_SERVICE_"}); err != nil {
+		log.Error("invalid configuration", "err", err)
+		return err
+	}
+
+	if err := logLevel.UnmarshalText([]byte(Config.LogLevel)); err != nil {
+		return err
+	}
+
+	if Config.PublicURL == "" {
+		Config.PublicURL = "http://" + Config.Host
+	}
+
+	log.Info("effective configuration", "config", config.Describe(&Config))
+
 	return nil
 }
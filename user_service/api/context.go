@@ -4,6 +4,8 @@ import (
 	"context"
 	"net/http"
 
+	"common/auth"
+
 	"user_service/internal/data"
 )
 
@@ -17,7 +19,12 @@ func (app *application) contextSetUser(r *http.Request, user *data.User) *http.R
 	return r.WithContext(ctx)
 }
 
+// the anonymous user for requests without a token
 func (app *application) contextGetUser(r *http.Request) *data.User {
+	if auth.ContextGetPrincipal(r).IsAnonymous() {
+		return data.AnonymousUser
+	}
+
 	user, ok := r.Context().Value(userContextKey).(*data.User)
 	if !ok {
 		panic("cannot typecast to *data.User probably unknown value")
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+
+	"github.com/glebarez/sqlite"
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"common/tracing/gormtrace"
+	"user_service/internal/migrate"
+)
+
+// opens the database described by Config, LoadEnvVars has to be called first
+func OpenDB() (*gorm.DB, error) {
+	db, err := openDB(false)
+	if err != nil {
+		return nil, err
+	}
+
+	//metrics are optional, a second OpenDB in the same process is already registered
+	if err := RegisterDBMetrics(db); err != nil {
+		log.Warn("couldnt register database metrics", "err", err)
+	}
+
+	return db, nil
+}
+
+// connection for the migrator. on mysql it allows several statements in one
+// Exec so a whole migration file runs at once, the connection of the handlers
+// never does that
+func OpenMigrationDB() (*gorm.DB, error) {
+	return openDB(true)
+}
+
+func openDB(multiStatements bool) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+
+	switch Config.DBDriver {
+	case "postgres":
+		dialector = postgres.Open(postgresDSN())
+
+	case "mysql":
+		dialector = mysql.New(mysql.Config{DSNConfig: mysqlDSN(multiStatements)})
+
+	case "sqlite":
+		//pure go driver, no cgo or database server needed
+		dialector = sqlite.Open(Config.DBPath + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+
+	default:
+		return nil, fmt.Errorf("unsupported db_driver %q", Config.DBDriver)
+	}
+
+	//driver errors like duplicate keys come back as the gorm.Err* values
+	db, err := gorm.Open(dialector, &gorm.Config{TranslateError: true})
+	if err != nil {
+		log.Error("error while connecting to database", "driver", Config.DBDriver, "err", err)
+		return nil, err
+	}
+
+	if err := db.Use(gormtrace.Plugin{}); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// url form, it escapes spaces, quotes and @ in the credentials
+func postgresDSN() string {
+	port := Config.DBPort
+	if port == "" {
+		port = "5432"
+	}
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(Config.DBUsername, Config.DBPassword),
+		Host:     net.JoinHostPort(Config.Host, port),
+		Path:     "/" + Config.DBName,
+		RawQuery: "sslmode=disable",
+	}
+	return dsn.String()
+}
+
+// the driver formats the dsn itself, so the password can hold any character
+func mysqlDSN(multiStatements bool) *mysqldriver.Config {
+	port := Config.DBPort
+	if port == "" {
+		port = "3306"
+	}
+	dsn := mysqldriver.NewConfig()
+	dsn.User = Config.DBUsername
+	dsn.Passwd = Config.DBPassword
+	dsn.Net = "tcp"
+	dsn.Addr = net.JoinHostPort(Config.Host, port)
+	dsn.DBName = Config.DBName
+	dsn.ParseTime = true
+	dsn.MultiStatements = multiStatements
+	return dsn
+}
+
+// applies pending migrations when migrate_on_start is set, on a connection
+// of their own that is closed again afterwards
+func RunStartupMigrations() error {
+	if !Config.MigrateOnStart {
+		return nil
+	}
+
+	db, err := OpenMigrationDB()
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	migrator, err := migrate.New(db)
+	if err != nil {
+		return err
+	}
+
+	ran, err := migrator.Up()
+	if err != nil {
+		log.Error("error while applying migrations", "err", err)
+		return err
+	}
+	for _, m := range ran {
+		log.Info("applied migration", "version", m.Version, "name", m.Name)
+	}
+
+	return nil
+}
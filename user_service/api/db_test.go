@@ -0,0 +1,75 @@
+package api
+
+import (
+	"testing"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// credentials the dsn formats trip over
+var awkwardPasswords = []string{
+	"plain",
+	"with space",
+	`quotes ' and "`,
+	`back\slash`,
+	"p@ss:w/rd?#%&=",
+	"",
+}
+
+func withDBConfig(t *testing.T, password string) {
+	t.Helper()
+	saved := Config
+	t.Cleanup(func() { Config = saved })
+
+	Config.Host = "db.internal"
+	Config.DBPort = "6000"
+	Config.DBUsername = "user name"
+	Config.DBPassword = password
+	Config.DBName = "users"
+}
+
+func TestPostgresDSN(t *testing.T) {
+	for _, password := range awkwardPasswords {
+		t.Run(password, func(t *testing.T) {
+			withDBConfig(t, password)
+
+			parsed, err := pgconn.ParseConfig(postgresDSN())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.Password != password || parsed.User != "user name" {
+				t.Errorf("got user %q password %q, want %q %q", parsed.User, parsed.Password, "user name", password)
+			}
+			if parsed.Host != "db.internal" || parsed.Port != 6000 || parsed.Database != "users" {
+				t.Errorf("got %s:%d/%s, want db.internal:6000/users", parsed.Host, parsed.Port, parsed.Database)
+			}
+		})
+	}
+}
+
+func TestMySQLDSN(t *testing.T) {
+	for _, password := range awkwardPasswords {
+		t.Run(password, func(t *testing.T) {
+			withDBConfig(t, password)
+
+			parsed, err := mysqldriver.ParseDSN(mysqlDSN(false).FormatDSN())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.Passwd != password || parsed.User != "user name" {
+				t.Errorf("got user %q password %q, want %q %q", parsed.User, parsed.Passwd, "user name", password)
+			}
+			if parsed.Addr != "db.internal:6000" || parsed.DBName != "users" || !parsed.ParseTime {
+				t.Errorf("got %s/%s parseTime %v, want db.internal:6000/users with parseTime", parsed.Addr, parsed.DBName, parsed.ParseTime)
+			}
+			if parsed.MultiStatements {
+				t.Error("got multiStatements on the connection of the handlers")
+			}
+		})
+	}
+
+	if !mysqlDSN(true).MultiStatements {
+		t.Error("got no multiStatements on the migration connection")
+	}
+}
@@ -8,21 +8,11 @@ func (app *application) sendErrorResponse(w http.ResponseWriter, statusCode int,
 	err := app.writeJSON(w, env, statusCode)
 	if err != nil {
 		//err while sending
-		log.Error(err)
+		log.Error("error while sending error response", "err", err)
 		w.WriteHeader(http.StatusInternalServerError)
 	}
 }
 
-func (app *application) invalidAuthenticationHeader(w http.ResponseWriter, r *http.Request) {
-	message := "invalid authentication headers."
-	app.sendErrorResponse(w, http.StatusExpectationFailed, message)
-}
-
-func (app *application) invalidToken(w http.ResponseWriter, r *http.Request) {
-	message := "token invalid"
-	app.sendErrorResponse(w, http.StatusUnauthorized, message)
-}
-
 func (app *application) invalidTokenDeletedUser(w http.ResponseWriter, r *http.Request) {
 	message := "user is deleted[dead token] please create new account."
 	app.sendErrorResponse(w, http.StatusNotFound, message)
@@ -33,16 +23,16 @@ func (app *application) internalServerError(w http.ResponseWriter, r *http.Reque
 	app.sendErrorResponse(w, http.StatusInternalServerError, message)
 }
 
-func (app *application) authenticationRequired(w http.ResponseWriter, r *http.Request) {
-	message := "authentication required."
-	app.sendErrorResponse(w, http.StatusUnauthorized, message)
-}
-
 func (app *application) routeNotFound(w http.ResponseWriter, r *http.Request) {
 	message := "route not available."
 	app.sendErrorResponse(w, http.StatusNotFound, message)
 }
 
+func (app *application) methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	message := "method not allowed on this route."
+	app.sendErrorResponse(w, http.StatusMethodNotAllowed, message)
+}
+
 func (app *application) wrongcredentials(w http.ResponseWriter, r *http.Request) {
 	message := "wrong credentials."
 	app.sendErrorResponse(w, http.StatusUnauthorized, message)
@@ -62,3 +52,13 @@ func (app *application) fileSizeLimitExceded(w http.ResponseWriter, r *http.Requ
 	message := "file should be less than 32mb"
 	app.sendErrorResponse(w, http.StatusBadRequest, message)
 }
+
+func (app *application) passwordResetRequired(w http.ResponseWriter, r *http.Request) {
+	message := "password reset required, use the link sent to your email."
+	app.sendErrorResponse(w, http.StatusForbidden, message)
+}
+
+func (app *application) invalidOrExpiredLink(w http.ResponseWriter, r *http.Request) {
+	message := "link is invalid or has expired."
+	app.sendErrorResponse(w, http.StatusNotFound, message)
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"user_service/internal/data"
+)
+
+// target user is read from ?id=
+func (app *application) FollowUser(w http.ResponseWriter, r *http.Request) {
+	followeeid, err := app.readParamID(r)
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user := app.contextGetUser(r)
+	if followeeid == user.ID {
+		app.sendErrorResponse(w, http.StatusBadRequest, "cannot follow yourself.")
+		return
+	}
+
+	models := app.modelsFor(r)
+
+	if _, err := models.Users.GetUser(followeeid); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.userNotFound(w, r)
+		default:
+			app.internalServerError(w, r)
+		}
+		return
+	}
+
+	if err := models.Follows.Follow(user.ID, followeeid); err != nil {
+		requestLogger(r).Error("error while following user", "err", err)
+		app.internalServerError(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (app *application) UnfollowUser(w http.ResponseWriter, r *http.Request) {
+	followeeid, err := app.readParamID(r)
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	if err := app.modelsFor(r).Follows.Unfollow(user.ID, followeeid); err != nil {
+		app.internalServerError(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (app *application) GetFollowing(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	following, err := app.modelsFor(r).Follows.GetFollowing(user.ID)
+	if err != nil {
+		app.internalServerError(w, r)
+		return
+	}
+
+	app.writeJSON(w, envelope{"following": following}, http.StatusOK)
+}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"common/userpb"
+	"user_service/internal/data"
+)
+
+// BatchGetUsers limit, keeps a single IN query reasonable
+const maxBatchGetUsers = 500
+
+// internal api used by post_service, see common/userpb/user.proto
+type userServer struct {
+	userpb.UnimplementedUserServiceServer
+	app *application
+}
+
+func (app *application) newGRPCServer() (*grpc.Server, *grpchealth.Server) {
+	srv := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(grpcDeadline, grpcLogErrors),
+	)
+
+	userpb.RegisterUserServiceServer(srv, &userServer{app: app})
+
+	//standard health protocol, checked by the readiness of dependent services
+	health := grpchealth.NewServer()
+	health.SetServingStatus(userpb.UserService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, health)
+
+	return srv, health
+}
+
+// listens on Config.GRPCAddr before serving so a taken port fails startup
+func serveGRPC(srv *grpc.Server) error {
+	lis, err := net.Listen("tcp", Config.GRPCAddr)
+	if err != nil {
+		log.Error("error while listening for grpc", "addr", Config.GRPCAddr, "err", err)
+		return err
+	}
+	log.Info("grpc server listening", "addr", Config.GRPCAddr)
+
+	go func() {
+		if err := srv.Serve(lis); err != nil {
+			log.Error("grpc server stopped with error", "err", err)
+		}
+	}()
+	return nil
+}
+
+// waits for calls in flight like GracefulStop, but not longer than timeout
+func stopGRPC(srv *grpc.Server, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		srv.Stop()
+	}
+}
+
+// callers without a deadline get Config.GRPCRequestTimeout
+func grpcDeadline(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, Config.GRPCRequestTimeout)
+		defer cancel()
+	}
+	return handler(ctx, req)
+}
+
+func grpcLogErrors(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	if status.Code(err) == codes.Internal {
+		log.ErrorContext(ctx, "grpc call failed", "method", info.FullMethod, "err", err)
+	}
+	return resp, err
+}
+
+func toProtoUser(user *data.User) *userpb.User {
+	return &userpb.User{
+		Id:        user.ID,
+		Username:  user.Username,
+		Bio:       user.Bio,
+		IsAdmin:   user.IsAdmin,
+		CreatedAt: timestamppb.New(user.CreatedAt),
+	}
+}
+
+func (s *userServer) GetUser(ctx context.Context, req *userpb.GetUserRequest) (*userpb.User, error) {
+	user, err := s.app.models.WithContext(ctx).Users.GetUser(req.GetId())
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, status.Error(codes.NotFound, "user not found")
+		default:
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	return toProtoUser(&user), nil
+}
+
+func (s *userServer) BatchGetUsers(ctx context.Context, req *userpb.BatchGetUsersRequest) (*userpb.BatchGetUsersResponse, error) {
+	if len(req.GetIds()) > maxBatchGetUsers {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d ids per call", maxBatchGetUsers)
+	}
+
+	users, err := s.app.models.WithContext(ctx).Users.GetUsers(req.GetIds())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &userpb.BatchGetUsersResponse{Users: make([]*userpb.User, len(users))}
+	for i := range users {
+		resp.Users[i] = toProtoUser(&users[i])
+	}
+	return resp, nil
+}
+
+func (s *userServer) VerifyToken(ctx context.Context, req *userpb.VerifyTokenRequest) (*userpb.VerifyTokenResponse, error) {
+	user, claims, err := s.app.userForToken(s.app.models.WithContext(ctx), req.GetToken())
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrTokenInvalid):
+			return nil, status.Error(codes.Unauthenticated, "token invalid")
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, status.Error(codes.Unauthenticated, "user is deleted")
+		default:
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	return &userpb.VerifyTokenResponse{
+		UserId:    user.ID,
+		IsAdmin:   user.IsAdmin,
+		ExpiresAt: timestamppb.New(time.Unix(claims.ExpiresAt, 0)),
+	}, nil
+}
+
+func (s *userServer) GetFollowing(ctx context.Context, req *userpb.GetFollowingRequest) (*userpb.GetFollowingResponse, error) {
+	ids, err := s.app.models.WithContext(ctx).Follows.GetFollowing(req.GetUserId())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &userpb.GetFollowingResponse{UserIds: ids}, nil
+}
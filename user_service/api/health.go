@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/url"
+
+	"gorm.io/gorm"
+
+	"common/health"
+	"common/tracing"
+)
+
+// readiness checks for the database, the profile picture directory and the
+// services listed in downstream_urls
+func NewHealthChecker(db *gorm.DB) (*health.Checker, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	checker := health.New(Config.HealthCheckTimeout)
+	checker.Add("database", health.SQLCheck(sqlDB))
+	checker.Add("image_storage", health.DirWritable(Config.ImagesDir))
+
+	client := tracing.HTTPClient()
+	for _, raw := range Config.DownstreamURLs {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		checker.Add("downstream:"+u.Host, health.HTTPCheck(client, raw))
+	}
+
+	return checker, nil
+}
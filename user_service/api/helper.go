@@ -20,6 +20,7 @@ func (app *application) writeJSON(w http.ResponseWriter, data envelope, statusCo
 	}
 	js = append(js, '\n')
 
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 	w.Write(js)
 
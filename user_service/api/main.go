@@ -1,11 +1,21 @@
 package api
 
-import "user_service/internal/data"
+import (
+	"common/auth"
+	"common/health"
+	"common/idempotency"
+	"common/openapi"
+	"user_service/internal/data"
+	"user_service/internal/mailer"
+)
 
 type application struct {
 	models data.Models
-}
-
-func main() {
+	mailer mailer.Sender
+	health *health.Checker
+	spec   *openapi.Spec
+	tokens *auth.Tokens
+	auth   *auth.Middleware
 
+	idempotency *idempotency.Middleware
 }
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+
+	"common/metrics"
+)
+
+const (
+	loginSuccess = "success"
+	loginFailure = "failure"
+	loginLockout = "lockout"
+)
+
+var (
+	loginResults = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: serviceName,
+		Name:      "login_attempts_total",
+		Help:      "Login attempts by result: success, failure (unknown user or wrong password) or lockout.",
+	}, []string{"result"})
+
+	uploadSize = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: serviceName,
+		Name:      "profile_picture_upload_bytes",
+		Help:      "Size of uploaded profile pictures.",
+		// 16KiB .. 256MiB
+		Buckets: prometheus.ExponentialBuckets(16<<10, 4, 8),
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(loginResults, uploadSize)
+}
+
+// request counts and latencies by route template and status
+func (app *application) instrumentRequests(next http.Handler) http.Handler {
+	return metrics.Middleware(serviceName, routeLabel)(next)
+}
+
+// like routeTemplate but unmatched paths share one label instead of one each
+func routeLabel(r *http.Request) string {
+	if mux.CurrentRoute(r) == nil {
+		return "unmatched"
+	}
+	return routeTemplate(r)
+}
+
+// serves /metrics
+func MetricsHandler() http.Handler {
+	return metrics.Handler()
+}
+
+// exports the sql connection pool stats of db
+func RegisterDBMetrics(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return metrics.RegisterDBStats(sqlDB, serviceName)
+}
@@ -3,66 +3,86 @@ package api
 import (
 	"errors"
 	"net/http"
-	"strings"
 
+	"github.com/gorilla/mux"
+
+	"common/auth"
+	"common/idempotency"
+	"common/logging"
 	"user_service/internal/data"
 )
 
-// adds user with the request
-func (app *application) authenticator(next http.HandlerFunc) http.HandlerFunc {
-
-	return func(w http.ResponseWriter, r *http.Request) {
-
-		authorizationHeader := r.Header.Get("Authorization")
-
-		if len(authorizationHeader) == 0 {
-			app.contextSetUser(r, data.AnonymousUser)
-			next.ServeHTTP(w, r)
-			return
-		}
+// writes the access log and gives handlers a logger with request id and route
+func (app *application) logRequests(next http.Handler) http.Handler {
+	return logging.Middleware(log, routeTemplate)(next)
+}
 
-		headerParts := strings.Split(authorizationHeader, " ")
-		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
-			//invalid request header send 401 response
-			app.invalidAuthenticationHeader(w, r)
-			return
+// matched route template like /users/{id}, keeps raw ids out of logs and metrics
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			return tmpl
 		}
+	}
+	return r.URL.Path
+}
 
-		//expired malformed token or empty string
-		userid, err := app.verifyToken(headerParts[1])
-		if err != nil {
-			app.invalidToken(w, r)
-			return
-		}
+// token checks shared with post_service, the user is loaded here so revoked
+// sessions, deleted users and changed admin rights take effect right away
+func (app *application) newAuth() *auth.Middleware {
+	return &auth.Middleware{
+		Tokens:  app.tokens,
+		Check:   app.checkSession,
+		OnError: app.authenticationError,
+	}
+}
 
-		//not accounted errors yet
-		user, err := app.models.Users.GetUser(userid)
+func (app *application) checkSession(r *http.Request, token string, claims *auth.Claims, p *auth.Principal) (*http.Request, error) {
+	if claims == nil {
+		//identity signed by the gateway, it checked revocation through VerifyToken
+		user, err := app.modelsFor(r).Users.GetUser(p.UserID)
 		if err != nil {
-			switch {
-			case errors.Is(err, data.ErrRecordNotFound):
-				app.invalidTokenDeletedUser(w, r)
-			default:
-				app.internalServerError(w, r)
-			}
+			return r, err
 		}
+		p.Scopes = userScopes(&user)
+		return app.contextSetUser(r, &user), nil
+	}
 
-		app.contextSetUser(r, &user)
-
-		next.ServeHTTP(w, r)
+	user, err := app.userForClaims(app.modelsFor(r), claims)
+	if err != nil {
+		return r, err
 	}
 
+	p.Scopes = userScopes(user)
+	return app.contextSetUser(r, user), nil
 }
 
-// ordinary function , HandlerFunc(w,r)->(ServeHTTP) , Handler(ServeHTTP)
-func (app *application) requireAuthentication(next *http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-
-		user := app.contextGetUser(r)
+func (app *application) authenticationError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.invalidTokenDeletedUser(w, r)
+	default:
+		requestLogger(r).Error("error while authenticating", "err", err)
+		app.internalServerError(w, r)
+	}
+}
 
-		if user.IsAnonymousUser() {
-			app.authenticationRequired(w, r)
-			return
-		}
-		next.ServeHTTP(w, r)
+// keys of anonymous requests like register are shared under user 0. they have
+// to be long enough not to collide, and the fingerprint keeps one client from
+// getting the response of another
+func (app *application) newIdempotency() *idempotency.Middleware {
+	return &idempotency.Middleware{
+		Store: func(r *http.Request) idempotency.Store {
+			return app.modelsFor(r).IdempotencyKeys
+		},
+		UserID: func(r *http.Request) uint64 {
+			return auth.ContextLookupPrincipal(r).UserID
+		},
+		TTL:         Config.IdempotencyTTL,
+		LockTimeout: Config.IdempotencyLockTimeout,
+		OnError: func(w http.ResponseWriter, r *http.Request, err error) {
+			requestLogger(r).Error("error while checking the idempotency key", "err", err)
+			app.internalServerError(w, r)
+		},
 	}
 }
@@ -0,0 +1,264 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"user_service/internal/data"
+	"user_service/internal/mailer"
+)
+
+var (
+	passwordResetExpiry = 24 * time.Hour
+	revokeLinkExpiry    = 72 * time.Hour
+)
+
+// the pages behind the "this wasn't me" link, the form is only shown with a
+// token
+var revokePage = template.Must(template.New("revoke").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Secure your account</title></head>
+<body>
+<p>{{.Message}}</p>
+{{if .Token}}<form method="post" action="/users/devices/revoke">
+<input type="hidden" name="token" value="{{.Token}}">
+<button type="submit">Sign out everywhere and reset my password</button>
+</form>{{end}}
+</body>
+</html>
+`))
+
+func writeRevokePage(w http.ResponseWriter, statusCode int, message string, token string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(statusCode)
+	revokePage.Execute(w, struct{ Message, Token string }{message, token})
+}
+
+func newMailer() mailer.Sender {
+	if Config.SMTPHost == "" {
+		return mailer.LogSender{Logger: log}
+	}
+	return mailer.SMTPSender{
+		Host:     Config.SMTPHost,
+		Port:     Config.SMTPPort,
+		Username: Config.SMTPUsername,
+		Password: Config.SMTPPassword,
+		From:     Config.SMTPSender,
+	}
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// records the device used for login and notifies the user when it is new
+func (app *application) checkLoginDevice(user *data.User, r *http.Request) error {
+	logger := requestLogger(r)
+
+	ip := clientIP(r)
+	userAgent := r.UserAgent()
+
+	device, err := app.modelsFor(r).Devices.FindDevice(user.ID, ip, userAgent)
+	if err == nil {
+		return app.modelsFor(r).Devices.UpdateLastSeen(device.ID)
+	}
+	if !errors.Is(err, data.ErrRecordNotFound) {
+		return err
+	}
+
+	revokeToken, err := app.generateRandomToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	revokeExpiry := now.Add(revokeLinkExpiry)
+	device = data.Device{
+		UserID:            user.ID,
+		IPAddress:         ip,
+		UserAgent:         userAgent,
+		LastSeen:          now,
+		RevokeTokenHash:   app.hashToken(revokeToken),
+		RevokeTokenExpiry: &revokeExpiry,
+	}
+	if err := app.modelsFor(r).Devices.AddDevice(&device); err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("New login from %s (%s) at %s.", ip, userAgent, device.LastSeen.Format(time.RFC1123))
+
+	notification := &data.Notification{
+		UserID:  user.ID,
+		Kind:    data.NotificationNewDevice,
+		Message: message,
+	}
+	if err := app.modelsFor(r).Notifications.AddNotification(notification); err != nil {
+		return err
+	}
+
+	revokeLink := Config.PublicURL + "/users/devices/revoke?token=" + url.QueryEscape(revokeToken)
+	body := message + "\n\nIf this wasn't you, open the link below to sign out everywhere and reset your password:\n" + revokeLink
+
+	//mail is sent in the background so a slow smtp server doesnt block login
+	go func() {
+		if err := app.mailer.Send(user.Email, "New login to your account", body); err != nil {
+			logger.Error("error while sending new device mail", "err", err)
+		}
+	}()
+
+	return nil
+}
+
+// "this wasn't me" link. only shows a form that posts the token back, mail
+// scanners open links to check them and must not lock the user out
+func (app *application) ConfirmRevokeDevice(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+
+	_, err := app.modelsFor(r).Devices.GetDeviceByRevokeToken(app.hashToken(token))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			writeRevokePage(w, http.StatusNotFound, "This link is invalid or has expired.", "")
+		default:
+			requestLogger(r).Error("error while looking up revoke token", "err", err)
+			writeRevokePage(w, http.StatusInternalServerError, "Something went wrong, please try again later.", "")
+		}
+		return
+	}
+
+	writeRevokePage(w, http.StatusOK, "Someone logged in to your account from a new device. If this wasn't you, sign out everywhere and reset your password.", token)
+}
+
+// revokes the device and all sessions and mails a password reset token. the
+// link stays valid until the mail went out, so a failed mail can be retried
+func (app *application) RevokeDevice(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	models := app.modelsFor(r)
+
+	failed := func(err error, msg string) {
+		logger.Error(msg, "err", err)
+		writeRevokePage(w, http.StatusInternalServerError, "Something went wrong, please open the link again.", "")
+	}
+
+	device, err := models.Devices.GetDeviceByRevokeToken(app.hashToken(r.PostFormValue("token")))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			writeRevokePage(w, http.StatusNotFound, "This link is invalid or has expired.", "")
+		default:
+			failed(err, "error while looking up revoke token")
+		}
+		return
+	}
+
+	user, err := models.Users.GetUser(device.UserID)
+	if err != nil {
+		failed(err, "error while looking up user of revoked device")
+		return
+	}
+
+	resetToken, err := app.generateRandomToken()
+	if err != nil {
+		failed(err, "error while generating reset token")
+		return
+	}
+
+	err = models.Users.RevokeSessions(user.ID, app.hashToken(resetToken), time.Now().Add(passwordResetExpiry))
+	if err != nil {
+		failed(err, "error while revoking sessions")
+		return
+	}
+
+	body := fmt.Sprintf("All sessions of your account were signed out. Use this code to set a new password, "+
+		"it is valid for %d hours:\n\n%s", int(passwordResetExpiry.Hours()), resetToken)
+	if err := app.mailer.Send(user.Email, "Reset your password", body); err != nil {
+		failed(err, "error while sending password reset mail")
+		return
+	}
+
+	if err := models.Devices.RevokeDevice(device.ID); err != nil {
+		failed(err, "error while revoking device")
+		return
+	}
+
+	writeRevokePage(w, http.StatusOK, "You were signed out everywhere. We sent you an email with a code to set a new password.", "")
+}
+
+func (app *application) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	err := app.readJSON(r, w, &input)
+	if err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, err := app.modelsFor(r).Users.GetUserByResetHash(app.hashToken(input.Token))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidOrExpiredLink(w, r)
+		default:
+			app.internalServerError(w, r)
+		}
+		return
+	}
+
+	if user.PasswordResetExpiry == nil || time.Now().After(*user.PasswordResetExpiry) {
+		app.invalidOrExpiredLink(w, r)
+		return
+	}
+
+	if err := data.ValidatePassword(input.Password); err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	hashedpassword, err := app.generateHashedPassword([]byte(input.Password))
+	if err != nil {
+		app.internalServerError(w, r)
+		return
+	}
+
+	if err := app.modelsFor(r).Users.CompletePasswordReset(user.ID, hashedpassword); err != nil {
+		app.internalServerError(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (app *application) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	notifications, err := app.modelsFor(r).Notifications.GetNotifications(user.ID)
+	if err != nil {
+		app.internalServerError(w, r)
+		return
+	}
+
+	app.writeJSON(w, envelope{"notifications": notifications}, http.StatusOK)
+}
+
+func (app *application) MarkNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	if err := app.modelsFor(r).Notifications.MarkNotificationsRead(user.ID); err != nil {
+		app.internalServerError(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"common/openapi"
+)
+
+func loadSpec(t *testing.T) *openapi.Spec {
+	t.Helper()
+	spec, err := LoadOpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+// stands in for the handlers, it answers with a status no handler uses
+var reached = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusTeapot)
+})
+
+func TestValidateRequests(t *testing.T) {
+	validate := loadSpec(t).Middleware(openapi.Options{})(reached)
+
+	const form = "application/x-www-form-urlencoded"
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		header      http.Header
+		body        string
+		wantStatus  int
+		wantError   string
+	}{
+		{"valid", http.MethodPost, "/users/register", "", nil, `{"username":"u","email":"u@example.com","password":"long enough"}`,
+			http.StatusTeapot, ""},
+		{"short password", http.MethodPost, "/users/register", "", nil, `{"username":"u","email":"u@example.com","password":"short"}`,
+			http.StatusBadRequest, `invalid request body: field "password": minimum string length is 8`},
+		{"empty username", http.MethodPost, "/users/register", "", nil, `{"username":"","email":"u@example.com","password":"long enough"}`,
+			http.StatusBadRequest, `invalid request body: field "username": minimum string length is 1`},
+		{"unknown field", http.MethodPost, "/users/register", "", nil, `{"username":"u","email":"u@example.com","password":"long enough","admin":true}`,
+			http.StatusBadRequest, `invalid request body: property "admin" is unsupported`},
+		{"long idempotency key", http.MethodPost, "/users/register", "", http.Header{"Idempotency-Key": {strings.Repeat("k", 256)}},
+			`{"username":"u","email":"u@example.com","password":"long enough"}`,
+			http.StatusBadRequest, `invalid header parameter "Idempotency-Key": maximum string length is 255`},
+		{"missing field", http.MethodPost, "/users/login", "", nil, `{"username":"u"}`,
+			http.StatusBadRequest, `invalid request body: field "password": property "password" is missing`},
+		{"wrong body type", http.MethodPost, "/users/exists", "", nil, `42`,
+			http.StatusBadRequest, `invalid request body: value must be a string`},
+		{"id too small", http.MethodPost, "/users/follow?id=0", "", nil, "",
+			http.StatusBadRequest, `invalid query parameter "id": number must be at least 1`},
+		{"id not a number", http.MethodPost, "/users/follow?id=x", "", nil, "",
+			http.StatusBadRequest, `invalid query parameter "id": value x: an invalid integer`},
+		{"missing id", http.MethodDelete, "/users/follow", "", nil, "",
+			http.StatusBadRequest, `invalid query parameter "id": value is required but missing`},
+		{"missing token", http.MethodGet, "/users/devices/revoke", "", nil, "",
+			http.StatusBadRequest, `invalid query parameter "token": value is required but missing`},
+		{"form", http.MethodPost, "/users/devices/revoke", form, nil, "token=abc", http.StatusTeapot, ""},
+		{"undescribed", http.MethodGet, "/metrics", "", nil, "", http.StatusTeapot, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			for k, v := range tt.header {
+				r.Header[k] = v
+			}
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			validate.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantError == "" {
+				return
+			}
+			var body struct {
+				Error string `json:"error"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(body.Error, tt.wantError) {
+				t.Errorf("got %q, want an error starting with %q", body.Error, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestValidateResponses(t *testing.T) {
+	spec := loadSpec(t)
+	app := &application{}
+
+	tests := []struct {
+		name       string
+		target     string
+		handler    http.HandlerFunc
+		wantStatus int
+		wantBody   string
+	}{
+		{"html page", "/users/devices/revoke?token=abc", func(w http.ResponseWriter, r *http.Request) {
+			writeRevokePage(w, http.StatusNotFound, "This link is invalid or has expired.", "")
+		}, http.StatusNotFound, "This link is invalid or has expired."},
+		{"json instead of html", "/users/devices/revoke?token=abc", func(w http.ResponseWriter, r *http.Request) {
+			app.writeJSON(w, envelope{"message": "gone"}, http.StatusOK)
+		}, http.StatusInternalServerError, "response does not match the api specification."},
+		{"incomplete user", "/users/deleted", func(w http.ResponseWriter, r *http.Request) {
+			app.writeJSON(w, envelope{"deleted_users": []envelope{{"ID": 1}}}, http.StatusOK)
+		}, http.StatusInternalServerError, "response does not match the api specification."},
+		{"empty list", "/users/deleted", func(w http.ResponseWriter, r *http.Request) {
+			app.writeJSON(w, envelope{"deleted_users": []envelope{}}, http.StatusOK)
+		}, http.StatusOK, `{"deleted_users":[]}`},
+		{"error", "/users/deleted", func(w http.ResponseWriter, r *http.Request) {
+			app.sendErrorResponse(w, http.StatusForbidden, "admins only.")
+		}, http.StatusForbidden, `{"error":"admins only."}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validate := spec.Middleware(openapi.Options{ValidateResponses: true})(tt.handler)
+
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			w := httptest.NewRecorder()
+			validate.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("got %s, want %s", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
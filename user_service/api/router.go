@@ -0,0 +1,71 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"common/auth"
+	"common/logging"
+)
+
+// authenticated user required
+func (app *application) authenticated(next http.HandlerFunc) http.HandlerFunc {
+	return app.auth.Authenticate(app.auth.RequireAuthentication(next))
+}
+
+// replays the stored response of a request retried with the same
+// Idempotency-Key. keys are per user, so on routes with a user it runs after
+// authentication. register and password reset have none, their keys are
+// shared under user 0 and have to be longer, see newIdempotency
+func (app *application) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return app.idempotency.Wrap(next)
+}
+
+// authenticated admin required
+func (app *application) admin(next http.HandlerFunc) http.HandlerFunc {
+	return app.authenticated(app.auth.RequireScope(auth.ScopeAdmin, next))
+}
+
+func (app *application) routes() http.Handler {
+	router := mux.NewRouter()
+
+	//middleware added with Use only runs for matched routes, where the route template is known
+	router.Use(app.traceRequests, app.logRequests, app.instrumentRequests, app.validateRequests)
+
+	//unmatched requests still get an access log line and are counted
+	router.NotFoundHandler = app.logRequests(app.instrumentRequests(http.HandlerFunc(app.routeNotFound)))
+	router.MethodNotAllowedHandler = app.logRequests(app.instrumentRequests(http.HandlerFunc(app.methodNotAllowed)))
+
+	router.Handle("/healthz", app.health.LivenessHandler()).Methods(http.MethodGet)
+	router.Handle("/readyz", app.health.ReadinessHandler()).Methods(http.MethodGet)
+	router.Handle("/metrics", MetricsHandler()).Methods(http.MethodGet)
+	router.Handle("/openapi.json", app.spec.Handler()).Methods(http.MethodGet)
+	router.HandleFunc("/debug/log-level", app.admin(logging.LevelHandler(logLevel).ServeHTTP)).Methods(http.MethodGet, http.MethodPut)
+
+	router.HandleFunc("/users/register", app.idempotent(app.RegisterUser)).Methods(http.MethodPost)
+	router.HandleFunc("/users/login", app.LoginUser).Methods(http.MethodPost)
+	router.HandleFunc("/users/exists", app.CheckUserExists).Methods(http.MethodPost)
+
+	router.HandleFunc("/users/password", app.authenticated(app.UpdatePassword)).Methods(http.MethodPut)
+	router.HandleFunc("/users/password/reset", app.idempotent(app.ResetPassword)).Methods(http.MethodPost)
+	router.HandleFunc("/users/details", app.authenticated(app.UpdateUserDetails)).Methods(http.MethodPut)
+
+	router.HandleFunc("/users/profile-picture", app.GetUserProfilePicture).Methods(http.MethodGet)
+	router.HandleFunc("/users/profile-picture", app.authenticated(app.UpdateProfilePicture)).Methods(http.MethodPut)
+
+	//linked from the new device mail
+	router.HandleFunc("/users/devices/revoke", app.ConfirmRevokeDevice).Methods(http.MethodGet)
+	router.HandleFunc("/users/devices/revoke", app.RevokeDevice).Methods(http.MethodPost)
+
+	router.HandleFunc("/users/notifications", app.authenticated(app.GetNotifications)).Methods(http.MethodGet)
+	router.HandleFunc("/users/notifications/read", app.authenticated(app.idempotent(app.MarkNotificationsRead))).Methods(http.MethodPost)
+
+	router.HandleFunc("/users/follow", app.authenticated(app.idempotent(app.FollowUser))).Methods(http.MethodPost)
+	router.HandleFunc("/users/follow", app.authenticated(app.UnfollowUser)).Methods(http.MethodDelete)
+	router.HandleFunc("/users/following", app.authenticated(app.GetFollowing)).Methods(http.MethodGet)
+
+	router.HandleFunc("/users/deleted", app.admin(app.GetDeletedUsers)).Methods(http.MethodGet)
+
+	return router
+}
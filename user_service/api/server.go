@@ -1 +1,87 @@
 package api
+
+import (
+	"context"
+
+	"common/auth"
+	"common/server"
+	"user_service/internal/data"
+)
+
+// loads the configuration, connects to the database and serves http until
+// SIGINT/SIGTERM, args are the command line flags without the program name
+func Run(args []string) error {
+	GetLogger()
+
+	if err := LoadConfig(args); err != nil {
+		return err
+	}
+
+	shutdownTracing, err := SetupTracing(context.Background())
+	if err != nil {
+		log.Error("error while setting up tracing", "err", err)
+		return err
+	}
+	defer shutdownTracing(context.Background())
+
+	db, err := OpenDB()
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	if err := RunStartupMigrations(); err != nil {
+		return err
+	}
+
+	checker, err := NewHealthChecker(db)
+	if err != nil {
+		return err
+	}
+
+	spec, err := LoadOpenAPI()
+	if err != nil {
+		log.Error("error while loading the openapi document", "err", err)
+		return err
+	}
+
+	app := &application{
+		models: data.GetModels(db),
+		mailer: newMailer(),
+		health: checker,
+		spec:   spec,
+		tokens: auth.NewTokens(Config.JWTkey, tokenLifetime),
+	}
+	app.auth = app.newAuth()
+	app.idempotency = app.newIdempotency()
+
+	grpcServer, grpcHealth := app.newGRPCServer()
+	if err := serveGRPC(grpcServer); err != nil {
+		return err
+	}
+	defer stopGRPC(grpcServer, Config.ShutdownTimeout)
+
+	err = server.ListenAndServe(app.routes(), server.Options{
+		Addr:            Config.HTTPAddr,
+		ReadTimeout:     Config.ReadTimeout,
+		WriteTimeout:    Config.WriteTimeout,
+		IdleTimeout:     Config.IdleTimeout,
+		DrainDelay:      Config.DrainDelay,
+		ShutdownTimeout: Config.ShutdownTimeout,
+		TLSCertFile:     Config.TLSCertFile,
+		TLSKeyFile:      Config.TLSKeyFile,
+		Logger:          log,
+		OnDrain: func() {
+			checker.Drain()
+			grpcHealth.Shutdown()
+		},
+	})
+	if err != nil {
+		log.Error("server stopped with error", "err", err)
+	}
+	return err
+}
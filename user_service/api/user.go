@@ -10,30 +10,56 @@ import (
 	"user_service/internal/data"
 )
 
-var imagesDir = "/home/core/go/kl/user_service/store/images/"
-
 func (app *application) RegisterUser(w http.ResponseWriter, r *http.Request) {
-	var user data.User
+	//only what a user may choose, everything else keeps its zero value
+	var input struct {
+		Username  string     `json:"username"`
+		Email     string     `json:"email"`
+		Password  string     `json:"password"`
+		Bio       string     `json:"bio"`
+		BirthDate *time.Time `json:"birthdate"`
+	}
 
-	err := app.readJSON(r, w, user)
+	err := app.readJSON(r, w, &input)
 	if err != nil {
-		app.sendErrorResponse(w, http.StatusBadRequest, err)
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user := data.User{
+		Username:  input.Username,
+		Email:     input.Email,
+		Bio:       input.Bio,
+		BirthDate: input.BirthDate,
+	}
+	if err := user.Validate(); err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := data.ValidatePassword(input.Password); err != nil {
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	user.Password, err = app.generateHashedPassword([]byte(user.Password))
+	user.Password, err = app.generateHashedPassword([]byte(input.Password))
 	if err != nil {
 		app.internalServerError(w, r)
 		return
 	}
 
-	err = app.models.Users.AddUser(&user)
+	err = app.modelsFor(r).Users.AddUser(&user)
 	if err != nil {
-		app.internalServerError(w, r)
+		switch {
+		case errors.Is(err, data.ErrConflict):
+			app.userExists(w, r)
+		default:
+			requestLogger(r).Error("error while adding user", "err", err)
+			app.internalServerError(w, r)
+		}
 		return
 	}
 
-	token, err := app.generateToken(user.ID)
+	token, err := app.generateToken(&user)
 	if err != nil {
 		app.internalServerError(w, r)
 		return
@@ -50,40 +76,42 @@ func (app *application) LoginUser(w http.ResponseWriter, r *http.Request) {
 		Password string `json:"password"`
 	}
 
-	err := app.readJSON(r, w, userLogin)
+	err := app.readJSON(r, w, &userLogin)
 	if err != nil {
-		app.sendErrorResponse(w, http.StatusBadRequest, err)
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	//check login attempts
-	loginAttempts, err := app.models.Users.GetLoginAttempts(userLogin.Username)
+	loginAttempts, err := app.modelsFor(r).Users.GetLoginAttempts(userLogin.Username)
 	if err != nil {
 		app.internalServerError(w, r)
 		return
 	}
 
 	//avoid hardcoding
-	if loginAttempts.FailedLoginAttempts > 5 {
+	if loginAttempts.FailedLoginAttempts > 5 && loginAttempts.FailedLoginTime != nil {
 		timeNow := time.Now()
 		timeDiff := loginAttempts.FailedLoginTime.Sub(timeNow)
 
 		if timeDiff.Abs().Hours() < 2 {
 			//try again after this hours
+			loginResults.WithLabelValues(loginLockout).Inc()
 			return
 		} else {
-			if err := app.models.Users.ResetLoginAttempts(userLogin.Username); err != nil {
-				log.Error("error while reseting login attempts ", err)
+			if err := app.modelsFor(r).Users.ResetLoginAttempts(userLogin.Username); err != nil {
+				requestLogger(r).Error("error while reseting login attempts", "err", err)
 				app.internalServerError(w, r)
 				return
 			}
 		}
 	}
 
-	dbpassword, err := app.models.Users.GetUserPassword(userLogin.Username)
+	dbpassword, err := app.modelsFor(r).Users.GetUserPassword(userLogin.Username)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
+			loginResults.WithLabelValues(loginFailure).Inc()
 			app.userNotFound(w, r)
 		default:
 			app.internalServerError(w, r)
@@ -92,30 +120,56 @@ func (app *application) LoginUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if mismatch := app.comparePassword([]byte(userLogin.Password), []byte(dbpassword)); mismatch != nil {
-		if err := app.models.Users.UpdateLoginAttempts(userLogin.Username); err != nil {
-			log.Error("error while updating login attempts ", err)
+		if err := app.modelsFor(r).Users.UpdateLoginAttempts(userLogin.Username); err != nil {
+			requestLogger(r).Error("error while updating login attempts", "err", err)
 			app.internalServerError(w, r)
 			return
 		}
+		loginResults.WithLabelValues(loginFailure).Inc()
 		app.wrongcredentials(w, r)
 		return
 	}
 
+	user, err := app.modelsFor(r).Users.GetUserByUsername(userLogin.Username)
+	if err != nil {
+		app.internalServerError(w, r)
+		return
+	}
+
+	if user.PasswordResetRequired {
+		app.passwordResetRequired(w, r)
+		return
+	}
+
+	//a failed notification should not block the login
+	if err := app.checkLoginDevice(&user, r); err != nil {
+		requestLogger(r).Error("error while checking login device", "err", err)
+	}
+
+	token, err := app.generateToken(&user)
+	if err != nil {
+		app.internalServerError(w, r)
+		return
+	}
+
+	loginResults.WithLabelValues(loginSuccess).Inc()
+
+	w.Header().Add("Authentication-Token", token)
 	w.WriteHeader(http.StatusAccepted)
 }
 
 func (app *application) CheckUserExists(w http.ResponseWriter, r *http.Request) {
 	var username string
 
-	err := app.readJSON(r, w, username)
+	err := app.readJSON(r, w, &username)
 	if err != nil {
-		app.sendErrorResponse(w, http.StatusBadRequest, err)
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	exists, err := app.models.Users.CheckUserExists(username)
+	exists, err := app.modelsFor(r).Users.CheckUserExists(username)
 	if err != nil {
-		log.Error(err)
+		requestLogger(r).Error("error while checking user exists", "err", err)
 		app.internalServerError(w, r)
 		return
 	}
@@ -132,9 +186,9 @@ func (app *application) UpdatePassword(w http.ResponseWriter, r *http.Request) {
 	//READJSON
 	var password string
 
-	err := app.readJSON(r, w, password)
+	err := app.readJSON(r, w, &password)
 	if err != nil {
-		app.sendErrorResponse(w, http.StatusBadRequest, err)
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -146,7 +200,7 @@ func (app *application) UpdatePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := app.models.Users.UpdatePassword(user.ID, hashedpassword); err != nil {
+	if err := app.modelsFor(r).Users.UpdatePassword(user.ID, hashedpassword); err != nil {
 		app.internalServerError(w, r)
 		return
 	}
@@ -156,22 +210,24 @@ func (app *application) UpdatePassword(w http.ResponseWriter, r *http.Request) {
 func (app *application) UpdateUserDetails(w http.ResponseWriter, r *http.Request) {
 
 	var userDetails struct {
-		Bio       string    `json:"bio"`
-		BirthDate time.Time `json:"birthdate"`
+		Bio       string     `json:"bio"`
+		BirthDate *time.Time `json:"birthdate"`
 	}
 
-	err := app.readJSON(r, w, userDetails)
+	err := app.readJSON(r, w, &userDetails)
 	if err != nil {
-		app.sendErrorResponse(w, http.StatusBadRequest, err)
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	user := app.contextGetUser(r)
 
-	user.Bio = userDetails.Bio
-	user.BirthDate = userDetails.BirthDate
+	updates := map[string]interface{}{
+		"bio":        userDetails.Bio,
+		"birth_date": userDetails.BirthDate,
+	}
 
-	if err := app.models.Users.UpdateUser(user); err != nil {
+	if err := app.modelsFor(r).Users.UpdateUser(user.ID, updates); err != nil {
 		app.internalServerError(w, r)
 		return
 	}
@@ -184,11 +240,11 @@ func (app *application) GetUserProfilePicture(w http.ResponseWriter, r *http.Req
 
 	userid, err := app.readParamID(r)
 	if err != nil {
-		app.sendErrorResponse(w, http.StatusBadRequest, err)
+		app.sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	image, err := app.models.Images.GetProfilePicture(userid)
+	image, err := app.modelsFor(r).Images.GetProfilePicture(userid)
 	if err != nil {
 		app.internalServerError(w, r)
 		return
@@ -212,18 +268,20 @@ func (app *application) UpdateProfilePicture(w http.ResponseWriter, r *http.Requ
 
 	user := app.contextGetUser(r)
 
+	uploadSize.Observe(float64(h.Size))
+
 	image := &data.Image{
 		UserID:   user.ID,
 		Size:     h.Size,
-		Location: imagesDir + h.Filename,
+		Location: Config.ImagesDir + h.Filename,
 	}
 
-	if err := app.models.Images.UpdateProfilePicture(image); err != nil {
+	if err := app.modelsFor(r).Images.UpdateProfilePicture(image); err != nil {
 		app.internalServerError(w, r)
 		return
 	}
 
-	destfile, err := os.Create(imagesDir + h.Filename)
+	destfile, err := os.Create(Config.ImagesDir + h.Filename)
 	if err != nil {
 		app.internalServerError(w, r)
 		return
@@ -240,7 +298,7 @@ func (app *application) UpdateProfilePicture(w http.ResponseWriter, r *http.Requ
 }
 
 func (app *application) GetDeletedUsers(w http.ResponseWriter, r *http.Request) {
-	allDeletedUsers, err := app.models.Users.FindSoftDeletedRecords()
+	allDeletedUsers, err := app.modelsFor(r).Users.FindSoftDeletedRecords()
 	if err != nil {
 		app.internalServerError(w, r)
 		return
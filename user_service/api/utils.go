@@ -1,54 +1,66 @@
 package api
 
 import (
-	"errors"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"time"
 
-	"github.com/golang-jwt/jwt"
 	"golang.org/x/crypto/bcrypt"
+
+	"common/auth"
+	"user_service/internal/data"
 )
 
-// var JWTKEY = common.Config.JWTkey
-var jwtKey = "JWTKEY"
-var tokenExpiryTime = time.Now().Add(60 * time.Minute)
-var ErrTokenInvalid = errors.New("token invalid")
+var tokenLifetime = 60 * time.Minute
+var ErrTokenInvalid = auth.ErrTokenInvalid
+
+// scopes are also refreshed from the database on every request, the token
+// only has to be right until it expires
+func userScopes(user *data.User) []string {
+	if user.IsAdmin {
+		return []string{auth.ScopeAdmin}
+	}
+	return nil
+}
 
-type CustomPayload struct {
-	ID uint64 `json:"id"`
-	jwt.StandardClaims
+func (app *application) generateToken(user *data.User) (string, error) {
+	return app.tokens.Issue(user.ID, userScopes(user))
 }
 
-func (app *application) generateToken(userid uint64) (string, error) {
-	payload := CustomPayload{
-		ID: userid,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: tokenExpiryTime.Unix(),
-		},
+// user the token was issued to, fails with ErrTokenInvalid when the token is
+// malformed, expired or was issued before the user's sessions were revoked
+func (app *application) userForToken(models data.Models, token string) (*data.User, *auth.Claims, error) {
+	claims, err := app.tokens.Verify(token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := app.userForClaims(models, claims)
+	if err != nil {
+		return nil, nil, err
 	}
-	rawToken := jwt.NewWithClaims(jwt.SigningMethodHS256, payload)
-	token, err := rawToken.SignedString(jwtKey)
-	return token, err
+	return user, claims, nil
 }
 
-func (app *application) verifyToken(token string) (uint64, error) {
-	var userid uint64
-	t, err := jwt.ParseWithClaims(token, &CustomPayload{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(jwtKey), nil
-	})
+func (app *application) userForClaims(models data.Models, claims *auth.Claims) (*data.User, error) {
+	user, err := models.Users.GetUser(claims.UserID)
 	if err != nil {
-		//this probably means token is expired
-		return userid, err
+		return nil, err
 	}
-	if p, ok := t.Claims.(*CustomPayload); ok && t.Valid {
-		//this probably means token is invalid
-		userid = p.ID
-		return userid, nil
+
+	//sessions revoked after this token was issued
+	if user.TokensValidAfter != nil && claims.IssuedAt < user.TokensValidAfter.Unix() {
+		return nil, ErrTokenInvalid
 	}
-	return userid, ErrTokenInvalid
+
+	return &user, nil
 }
+
 func (app *application) generateHashedPassword(password []byte) (string, error) {
 	var hashedpass string
-	hashedbytes, err := bcrypt.GenerateFromPassword(password, 3)
+	hashedbytes, err := bcrypt.GenerateFromPassword(password, Config.BCryptCost)
 	if err != nil {
 		return hashedpass, err
 	}
@@ -59,3 +71,17 @@ func (app *application) generateHashedPassword(password []byte) (string, error)
 func (app *application) comparePassword(password []byte, dbPassword []byte) error {
 	return bcrypt.CompareHashAndPassword(dbPassword, password)
 }
+
+// random url safe token, only its hash is stored
+func (app *application) generateRandomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func (app *application) hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
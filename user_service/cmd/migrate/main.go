@@ -0,0 +1,105 @@
+// migrate applies or reverts the user_service schema migrations.
+//
+//	migrate [-json] up
+//	migrate [-json] down [-steps N]
+//	migrate [-json] status
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"user_service/api"
+	"user_service/internal/migrate"
+)
+
+func main() {
+	jsonOutput := flag.Bool("json", false, "print results as json")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: migrate [-json] up | down [-steps N] | status")
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	api.UseStderrLogger()
+	if err := api.LoadEnvVars(); err != nil {
+		fail(err)
+	}
+
+	db, err := api.OpenMigrationDB()
+	if err != nil {
+		fail(err)
+	}
+
+	migrator, err := migrate.New(db)
+	if err != nil {
+		fail(err)
+	}
+
+	var out interface{}
+
+	switch flag.Arg(0) {
+	case "up":
+		ran, err := migrator.Up()
+		if err != nil {
+			fail(err)
+		}
+		out = ran
+		if !*jsonOutput {
+			for _, m := range ran {
+				fmt.Printf("applied %d_%s\n", m.Version, m.Name)
+			}
+		}
+
+	case "down":
+		fs := flag.NewFlagSet("down", flag.ExitOnError)
+		steps := fs.Int("steps", 1, "number of migrations to revert")
+		fs.Parse(flag.Args()[1:])
+
+		reverted, err := migrator.Down(*steps)
+		if err != nil {
+			fail(err)
+		}
+		out = reverted
+		if !*jsonOutput {
+			for _, m := range reverted {
+				fmt.Printf("reverted %d_%s\n", m.Version, m.Name)
+			}
+		}
+
+	case "status":
+		status, err := migrator.Status()
+		if err != nil {
+			fail(err)
+		}
+		out = status
+		if !*jsonOutput {
+			for _, s := range status {
+				state := "pending"
+				if s.Applied {
+					state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+				}
+				fmt.Printf("%04d_%s\t%s\n", s.Version, s.Name, state)
+			}
+		}
+
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *jsonOutput {
+		json.NewEncoder(os.Stdout).Encode(out)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "error:", err)
+	os.Exit(1)
+}
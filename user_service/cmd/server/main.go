@@ -0,0 +1,18 @@
+// server runs the user_service http api.
+//
+//	server [-config file] [-http-addr :8080] [-<option> value ...]
+//
+// every configuration option can also be set in the environment, see api/config.go
+package main
+
+import (
+	"os"
+
+	"user_service/api"
+)
+
+func main() {
+	if err := api.Run(os.Args[1:]); err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,311 @@
+// usersctl runs administrative operations against the user_service database.
+//
+//	usersctl [-json] [-dry-run] <command> [flags]
+//
+// commands:
+//
+//	create-admin   -username -email [-password-file]
+//	unlock         -username
+//	restore        -id
+//	reset-password -username [-password-file]
+//	purge          -older-than
+//
+// passwords are read from -password-file, or from the first line of stdin
+// without it, so they dont show up in the process list or shell history.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"user_service/api"
+	"user_service/internal/data"
+)
+
+// returned from the transaction in dry-run mode so everything is rolled back
+var errDryRun = errors.New("dry run")
+
+type result map[string]interface{}
+
+type command struct {
+	usage string
+	run   func(models data.Models, args []string) (result, error)
+}
+
+var commands = map[string]command{
+	"create-admin":   {"-username -email [-password-file]", createAdmin},
+	"unlock":         {"-username", unlockUser},
+	"restore":        {"-id", restoreUser},
+	"reset-password": {"-username [-password-file]", resetPassword},
+	"purge":          {"-older-than (default 720h)", purge},
+}
+
+func main() {
+	jsonOutput := flag.Bool("json", false, "print results as json")
+	dryRun := flag.Bool("dry-run", false, "run the command and roll back every change")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	api.UseStderrLogger()
+	if err := api.LoadEnvVars(); err != nil {
+		fail(*jsonOutput, err)
+	}
+
+	db, err := api.OpenDB()
+	if err != nil {
+		fail(*jsonOutput, err)
+	}
+
+	var res result
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		res, err = cmd.run(data.GetModels(tx), flag.Args()[1:])
+		if err != nil {
+			return err
+		}
+		if *dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		fail(*jsonOutput, err)
+	}
+
+	res["command"] = flag.Arg(0)
+	res["dry_run"] = *dryRun
+	printResult(*jsonOutput, res)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: usersctl [-json] [-dry-run] <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-15s %s\n", name, commands[name].usage)
+	}
+}
+
+func printResult(jsonOutput bool, res result) {
+	if jsonOutput {
+		json.NewEncoder(os.Stdout).Encode(res)
+		return
+	}
+
+	keys := make([]string, 0, len(res))
+	for k := range res {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Printf("%s: %v\n", k, res[k])
+	}
+}
+
+func fail(jsonOutput bool, err error) {
+	if jsonOutput {
+		json.NewEncoder(os.Stdout).Encode(result{"error": err.Error()})
+	} else {
+		fmt.Fprintln(os.Stderr, "error:", err)
+	}
+	os.Exit(1)
+}
+
+// the first line of the file, or of stdin when there is none
+func readPassword(file string) (string, error) {
+	var r io.Reader = os.Stdin
+	if file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		r = f
+	} else if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		fmt.Fprint(os.Stderr, "password: ")
+	}
+
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	password := strings.TrimRight(line, "\r\n")
+	return password, data.ValidatePassword(password)
+}
+
+func hashPassword(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), api.Config.BCryptCost)
+	return string(hashed), err
+}
+
+func createAdmin(models data.Models, args []string) (result, error) {
+	fs := flag.NewFlagSet("create-admin", flag.ExitOnError)
+	username := fs.String("username", "", "admin username")
+	email := fs.String("email", "", "admin email")
+	passwordFile := fs.String("password-file", "", "file holding the admin password, stdin by default")
+	fs.Parse(args)
+
+	if *username == "" || *email == "" {
+		return nil, errors.New("-username and -email are required")
+	}
+	user := &data.User{
+		Username: *username,
+		Email:    *email,
+		IsAdmin:  true,
+	}
+	if err := user.Validate(); err != nil {
+		return nil, err
+	}
+
+	password, err := readPassword(*passwordFile)
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := models.Users.CheckUserExists(*username)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, data.ErrConflict
+	}
+
+	user.Password, err = hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := models.Users.AddUser(user); err != nil {
+		return nil, err
+	}
+
+	return result{"user_id": user.ID, "username": user.Username}, nil
+}
+
+func unlockUser(models data.Models, args []string) (result, error) {
+	fs := flag.NewFlagSet("unlock", flag.ExitOnError)
+	username := fs.String("username", "", "locked username")
+	fs.Parse(args)
+
+	if *username == "" {
+		return nil, errors.New("-username is required")
+	}
+
+	if err := models.Users.ResetLoginAttempts(*username); err != nil {
+		return nil, err
+	}
+
+	return result{"username": *username}, nil
+}
+
+func restoreUser(models data.Models, args []string) (result, error) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	userid := fs.Uint64("id", 0, "id of the soft deleted user")
+	fs.Parse(args)
+
+	if *userid == 0 {
+		return nil, errors.New("-id is required")
+	}
+
+	if err := models.Users.RestoreUser(*userid); err != nil {
+		return nil, err
+	}
+
+	return result{"user_id": *userid}, nil
+}
+
+func resetPassword(models data.Models, args []string) (result, error) {
+	fs := flag.NewFlagSet("reset-password", flag.ExitOnError)
+	username := fs.String("username", "", "username")
+	passwordFile := fs.String("password-file", "", "file holding the new password, stdin by default")
+	fs.Parse(args)
+
+	if *username == "" {
+		return nil, errors.New("-username is required")
+	}
+
+	password, err := readPassword(*passwordFile)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := models.Users.GetUserByUsername(*username)
+	if err != nil {
+		return nil, err
+	}
+
+	hashed, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	//like a reset by mail, unlocks an account locked through a revoke and
+	//signs out every session
+	if err := models.Users.CompletePasswordReset(user.ID, hashed); err != nil {
+		return nil, err
+	}
+
+	return result{"user_id": user.ID, "username": user.Username}, nil
+}
+
+func purge(models data.Models, args []string) (result, error) {
+	fs := flag.NewFlagSet("purge", flag.ExitOnError)
+	olderThan := fs.Duration("older-than", 30*24*time.Hour, "remove data older than this")
+	fs.Parse(args)
+
+	before := time.Now().Add(-*olderThan)
+
+	users, err := models.Users.PurgeDeletedUsers(before)
+	if err != nil {
+		return nil, err
+	}
+	devices, err := models.Devices.PurgeDevices(before)
+	if err != nil {
+		return nil, err
+	}
+	notifications, err := models.Notifications.PurgeNotifications(before)
+	if err != nil {
+		return nil, err
+	}
+	//expired ones, no matter how old
+	idempotencyKeys, err := models.IdempotencyKeys.PurgeIdempotencyKeys(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return result{
+		"before":           before.Format(time.RFC3339),
+		"deleted_users":    users,
+		"devices":          devices,
+		"notifications":    notifications,
+		"idempotency_keys": idempotencyKeys,
+	}, nil
+}
@@ -0,0 +1,117 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// device/ip combination a user has logged in from
+type Device struct {
+	ID        uint64 `gorm:"primarykey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	IPAddress string
+	UserAgent string
+	LastSeen  time.Time
+
+	// sha256 of the "this wasn't me" token sent with the notification
+	RevokeTokenHash   string `gorm:"index"`
+	RevokeTokenExpiry *time.Time
+	Revoked           bool
+
+	UserID uint64
+	User   User `gorm:"constraint:OnDelete:CASCADE;"`
+}
+
+type DeviceModel struct {
+	DB *gorm.DB
+}
+
+func (d DeviceModel) FindDevice(userid uint64, ip string, userAgent string) (Device, error) {
+	var device Device
+
+	ctx, cancel := context.WithTimeout(contextOf(d.DB), Context_timeout)
+	defer cancel()
+
+	err := d.DB.WithContext(ctx).
+		Where("user_id = ? AND ip_address = ? AND user_agent = ? AND revoked = ?", userid, ip, userAgent, false).
+		First(&device).Error
+
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return device, ErrRecordNotFound
+		default:
+			return device, err
+		}
+	}
+
+	return device, nil
+}
+
+func (d DeviceModel) AddDevice(device *Device) error {
+	ctx, cancel := context.WithTimeout(contextOf(d.DB), Context_timeout)
+	defer cancel()
+
+	return d.DB.WithContext(ctx).Create(device).Error
+}
+
+func (d DeviceModel) UpdateLastSeen(deviceid uint64) error {
+	ctx, cancel := context.WithTimeout(contextOf(d.DB), Context_timeout)
+	defer cancel()
+
+	return d.DB.WithContext(ctx).Model(&Device{ID: deviceid}).Update("last_seen", time.Now()).Error
+}
+
+// devices from before revoke links expired have no expiry and are not found
+func (d DeviceModel) GetDeviceByRevokeToken(tokenHash string) (Device, error) {
+	var device Device
+
+	ctx, cancel := context.WithTimeout(contextOf(d.DB), Context_timeout)
+	defer cancel()
+
+	err := d.DB.WithContext(ctx).
+		Where("revoke_token_hash = ? AND revoke_token_expiry > ?", tokenHash, time.Now()).
+		First(&device).Error
+
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return device, ErrRecordNotFound
+		default:
+			return device, err
+		}
+	}
+
+	return device, nil
+}
+
+// marks the device as revoked and burns the revoke token so the link works once
+func (d DeviceModel) RevokeDevice(deviceid uint64) error {
+	ctx, cancel := context.WithTimeout(contextOf(d.DB), Context_timeout)
+	defer cancel()
+
+	t := d.DB.WithContext(ctx).Model(&Device{ID: deviceid}).Updates(map[string]interface{}{
+		"revoked":           true,
+		"revoke_token_hash": "",
+	})
+	if t.Error != nil {
+		return t.Error
+	}
+	if t.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (d DeviceModel) PurgeDevices(lastSeenBefore time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(contextOf(d.DB), Context_timeout)
+	defer cancel()
+
+	t := d.DB.WithContext(ctx).Where("last_seen < ?", lastSeenBefore).Delete(&Device{})
+	return t.RowsAffected, t.Error
+}
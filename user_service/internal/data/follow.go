@@ -0,0 +1,51 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FollowerID follows FolloweeID
+type Follow struct {
+	FollowerID uint64 `gorm:"primaryKey;autoIncrement:false"`
+	FolloweeID uint64 `gorm:"primaryKey;autoIncrement:false;index"`
+	CreatedAt  time.Time
+}
+
+type FollowModel struct {
+	DB *gorm.DB
+}
+
+// following someone twice is not an error
+func (f FollowModel) Follow(followerid uint64, followeeid uint64) error {
+	ctx, cancel := context.WithTimeout(contextOf(f.DB), Context_timeout)
+	defer cancel()
+
+	follow := &Follow{FollowerID: followerid, FolloweeID: followeeid}
+	return f.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(follow).Error
+}
+
+func (f FollowModel) Unfollow(followerid uint64, followeeid uint64) error {
+	ctx, cancel := context.WithTimeout(contextOf(f.DB), Context_timeout)
+	defer cancel()
+
+	return f.DB.WithContext(ctx).
+		Where("follower_id = ? AND followee_id = ?", followerid, followeeid).
+		Delete(&Follow{}).Error
+}
+
+// ids of the users followerid follows
+func (f FollowModel) GetFollowing(followerid uint64) ([]uint64, error) {
+	ctx, cancel := context.WithTimeout(contextOf(f.DB), Context_timeout)
+	defer cancel()
+
+	ids := []uint64{}
+	t := f.DB.WithContext(ctx).Model(&Follow{}).
+		Where("follower_id = ?", followerid).
+		Order("created_at DESC").
+		Pluck("followee_id", &ids)
+	return ids, t.Error
+}
@@ -0,0 +1,126 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"common/idempotency"
+)
+
+// response stored for an Idempotency-Key, Status is 0 while the request runs
+type IdempotencyRecord struct {
+	UserID      uint64 `gorm:"primaryKey;autoIncrement:false"`
+	Key         string `gorm:"column:idempotency_key;primaryKey"`
+	Fingerprint string
+	Status      int
+	// json encoded http.Header
+	Header    string
+	Body      []byte
+	CreatedAt time.Time
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+func (IdempotencyRecord) TableName() string {
+	return "idempotency_keys"
+}
+
+type IdempotencyModel struct {
+	DB *gorm.DB
+}
+
+func (m IdempotencyModel) Begin(key idempotency.Key, fingerprint string, lockedUntil time.Time) (*idempotency.Response, error) {
+	ctx, cancel := context.WithTimeout(contextOf(m.DB), Context_timeout)
+	defer cancel()
+
+	db := m.DB.WithContext(ctx)
+
+	record := IdempotencyRecord{UserID: key.UserID, Key: key.Value, Fingerprint: fingerprint, ExpiresAt: lockedUntil}
+	t := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+	if t.Error != nil {
+		return nil, t.Error
+	}
+	if t.RowsAffected == 1 {
+		return nil, nil
+	}
+
+	//expired records are free, the condition makes sure only one request wins
+	t = db.Model(&IdempotencyRecord{}).
+		Where("user_id = ? AND idempotency_key = ? AND expires_at < ?", key.UserID, key.Value, time.Now()).
+		Updates(map[string]interface{}{
+			"fingerprint": fingerprint,
+			"status":      0,
+			"header":      "",
+			"body":        nil,
+			"created_at":  time.Now(),
+			"expires_at":  lockedUntil,
+		})
+	if t.Error != nil {
+		return nil, t.Error
+	}
+	if t.RowsAffected == 1 {
+		return nil, nil
+	}
+
+	err := db.Where("user_id = ? AND idempotency_key = ?", key.UserID, key.Value).First(&record).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			//purged in between, the retry gets it
+			return nil, idempotency.ErrInFlight
+		}
+		return nil, err
+	}
+
+	switch {
+	case record.Fingerprint != fingerprint:
+		return nil, idempotency.ErrMismatch
+	case record.Status == 0:
+		return nil, idempotency.ErrInFlight
+	}
+
+	response := &idempotency.Response{Status: record.Status, Body: record.Body}
+	if err := json.Unmarshal([]byte(record.Header), &response.Header); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (m IdempotencyModel) Complete(key idempotency.Key, response *idempotency.Response, expiresAt time.Time) error {
+	header, err := json.Marshal(response.Header)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(contextOf(m.DB), Context_timeout)
+	defer cancel()
+
+	return m.DB.WithContext(ctx).Model(&IdempotencyRecord{}).
+		Where("user_id = ? AND idempotency_key = ? AND status = 0", key.UserID, key.Value).
+		Updates(map[string]interface{}{
+			"status":     response.Status,
+			"header":     string(header),
+			"body":       response.Body,
+			"expires_at": expiresAt,
+		}).Error
+}
+
+func (m IdempotencyModel) Release(key idempotency.Key) error {
+	ctx, cancel := context.WithTimeout(contextOf(m.DB), Context_timeout)
+	defer cancel()
+
+	return m.DB.WithContext(ctx).
+		Where("user_id = ? AND idempotency_key = ? AND status = 0", key.UserID, key.Value).
+		Delete(&IdempotencyRecord{}).Error
+}
+
+func (m IdempotencyModel) PurgeIdempotencyKeys(expiredBefore time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(contextOf(m.DB), Context_timeout)
+	defer cancel()
+
+	t := m.DB.WithContext(ctx).Where("expires_at < ?", expiredBefore).Delete(&IdempotencyRecord{})
+	return t.RowsAffected, t.Error
+}
@@ -24,7 +24,7 @@ type ImageModel struct {
 func (i ImageModel) GetProfilePicture(userid uint64) (Image, error) {
 	var image Image
 
-	ctx, cancel := context.WithTimeout(context.Background(), Context_timeout)
+	ctx, cancel := context.WithTimeout(contextOf(i.DB), Context_timeout)
 	defer cancel()
 
 	t := i.DB.WithContext(ctx).Where("user_id = ?", userid).Find(&image)
@@ -32,14 +32,14 @@ func (i ImageModel) GetProfilePicture(userid uint64) (Image, error) {
 }
 
 func (i ImageModel) UpdateProfilePicture(image *Image) error {
-	ctx, cancel := context.WithTimeout(context.Background(), Context_timeout)
+	ctx, cancel := context.WithTimeout(contextOf(i.DB), Context_timeout)
 	defer cancel()
 
 	t := i.DB.WithContext(ctx).Save(image)
 	return t.Error
 }
 func (i ImageModel) RemoveProfilePicture(image *Image) error {
-	ctx, cancel := context.WithTimeout(context.Background(), Context_timeout)
+	ctx, cancel := context.WithTimeout(contextOf(i.DB), Context_timeout)
 	defer cancel()
 
 	t := i.DB.WithContext(ctx).Delete(image)
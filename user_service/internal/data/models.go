@@ -1,11 +1,22 @@
 package data
 
-import "gorm.io/gorm"
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"common/idempotency"
+)
 
 type Models struct {
+	db *gorm.DB
+
 	Users interface {
 		AddUser(user *User) error
 		GetUser(userid uint64) (User, error)
+		GetUsers(userids []uint64) ([]User, error)
+		GetUserByUsername(username string) (User, error)
 		UpdateUser(userid uint64, updates map[string]interface{}) error
 		DeleteUser(user *User) error
 
@@ -18,6 +29,13 @@ type Models struct {
 		UpdateLoginAttempts(username string) error
 
 		FindSoftDeletedRecords() ([]User, error)
+
+		RevokeSessions(userid uint64, resetHash string, resetExpiry time.Time) error
+		GetUserByResetHash(resetHash string) (User, error)
+		CompletePasswordReset(userid uint64, password string) error
+
+		RestoreUser(userid uint64) error
+		PurgeDeletedUsers(before time.Time) (int64, error)
 	}
 
 	Images interface {
@@ -25,11 +43,57 @@ type Models struct {
 		RemoveProfilePicture(image *Image) error
 		GetProfilePicture(userid uint64) (Image, error)
 	}
+
+	Devices interface {
+		FindDevice(userid uint64, ip string, userAgent string) (Device, error)
+		AddDevice(device *Device) error
+		UpdateLastSeen(deviceid uint64) error
+		GetDeviceByRevokeToken(tokenHash string) (Device, error)
+		RevokeDevice(deviceid uint64) error
+		PurgeDevices(lastSeenBefore time.Time) (int64, error)
+	}
+
+	Notifications interface {
+		AddNotification(notification *Notification) error
+		GetNotifications(userid uint64) ([]Notification, error)
+		MarkNotificationsRead(userid uint64) error
+		PurgeNotifications(before time.Time) (int64, error)
+	}
+
+	Follows interface {
+		Follow(followerid uint64, followeeid uint64) error
+		Unfollow(followerid uint64, followeeid uint64) error
+		GetFollowing(followerid uint64) ([]uint64, error)
+	}
+
+	IdempotencyKeys interface {
+		idempotency.Store
+		PurgeIdempotencyKeys(expiredBefore time.Time) (int64, error)
+	}
 }
 
 func GetModels(db *gorm.DB) Models {
 	return Models{
-		Users:  UserModel{DB: db},
-		Images: ImageModel{DB: db},
+		db:              db,
+		Users:           UserModel{DB: db},
+		Images:          ImageModel{DB: db},
+		Devices:         DeviceModel{DB: db},
+		Notifications:   NotificationModel{DB: db},
+		Follows:         FollowModel{DB: db},
+		IdempotencyKeys: IdempotencyModel{DB: db},
+	}
+}
+
+// models whose queries run under ctx, so request cancellation and trace spans
+// reach the database calls
+func (m Models) WithContext(ctx context.Context) Models {
+	return GetModels(m.db.WithContext(ctx))
+}
+
+// parent for the per query timeout, set through Models.WithContext
+func contextOf(db *gorm.DB) context.Context {
+	if db.Statement != nil && db.Statement.Context != nil {
+		return db.Statement.Context
 	}
+	return context.Background()
 }
@@ -0,0 +1,60 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// in-app notification shown to the user
+type Notification struct {
+	ID        uint64 `gorm:"primarykey"`
+	CreatedAt time.Time
+
+	Kind    string
+	Message string
+	Read    bool
+
+	UserID uint64
+	User   User `gorm:"constraint:OnDelete:CASCADE;"`
+}
+
+const NotificationNewDevice = "new_device_login"
+
+type NotificationModel struct {
+	DB *gorm.DB
+}
+
+func (n NotificationModel) AddNotification(notification *Notification) error {
+	ctx, cancel := context.WithTimeout(contextOf(n.DB), Context_timeout)
+	defer cancel()
+
+	return n.DB.WithContext(ctx).Create(notification).Error
+}
+
+func (n NotificationModel) GetNotifications(userid uint64) ([]Notification, error) {
+	var notifications []Notification
+
+	ctx, cancel := context.WithTimeout(contextOf(n.DB), Context_timeout)
+	defer cancel()
+
+	t := n.DB.WithContext(ctx).Where("user_id = ?", userid).Order("created_at DESC").Find(&notifications)
+	return notifications, t.Error
+}
+
+func (n NotificationModel) MarkNotificationsRead(userid uint64) error {
+	ctx, cancel := context.WithTimeout(contextOf(n.DB), Context_timeout)
+	defer cancel()
+
+	return n.DB.WithContext(ctx).Model(&Notification{}).Where(map[string]interface{}{"user_id": userid, "read": false}).
+		Update("read", true).Error
+}
+
+func (n NotificationModel) PurgeNotifications(before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(contextOf(n.DB), Context_timeout)
+	defer cancel()
+
+	t := n.DB.WithContext(ctx).Where("created_at < ?", before).Delete(&Notification{})
+	return t.RowsAffected, t.Error
+}
@@ -3,6 +3,8 @@ package data
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/mail"
 	"time"
 
 	"gorm.io/gorm"
@@ -18,14 +20,66 @@ type User struct {
 	CreatedAt time.Time
 	UpdatedAt time.Time
 
-	Username string `gorm:"<-:false"`
+	Username string `gorm:"<-:create"`
 	Email    string
 	Password string
 
-	Bio       string
-	BirthDate time.Time
+	Bio string
+	// nil columns are NULL, mysql in strict mode rejects the zero time
+	BirthDate *time.Time
+
+	IsAdmin bool
+
+	// tokens issued before this are rejected
+	TokensValidAfter      *time.Time
+	PasswordResetRequired bool
+	PasswordResetHash     string `gorm:"index"`
+	PasswordResetExpiry   *time.Time
 
 	IsDel soft_delete.DeletedAt `gorm:"softDelete:flag,DeletedAtField:DeletedAt"`
+	// set together with IsDel, purging goes by it
+	DeletedAt *time.Time
+}
+
+const (
+	minPasswordLength = 8
+	// bcrypt ignores anything after 72 bytes
+	maxPasswordLength = 72
+
+	maxUsernameLength = 64
+	maxEmailLength    = 254
+	maxBioLength      = 1000
+)
+
+// checks the fields a user can choose, the password is checked before hashing
+// with ValidatePassword
+func (u *User) Validate() error {
+	if len(u.Username) == 0 {
+		return errors.New("username cannot be empty")
+	}
+	if len(u.Username) > maxUsernameLength {
+		return fmt.Errorf("username cannot be longer than %d bytes", maxUsernameLength)
+	}
+	if len(u.Email) > maxEmailLength {
+		return fmt.Errorf("email cannot be longer than %d bytes", maxEmailLength)
+	}
+	if addr, err := mail.ParseAddress(u.Email); err != nil || addr.Address != u.Email {
+		return errors.New("invalid email address")
+	}
+	if len(u.Bio) > maxBioLength {
+		return fmt.Errorf("bio cannot be longer than %d bytes", maxBioLength)
+	}
+	return nil
+}
+
+func ValidatePassword(password string) error {
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("password must be at least %d characters long", minPasswordLength)
+	}
+	if len(password) > maxPasswordLength {
+		return fmt.Errorf("password cannot be longer than %d bytes", maxPasswordLength)
+	}
+	return nil
 }
 
 type Login struct {
@@ -34,10 +88,10 @@ type Login struct {
 	UpdatedAt time.Time
 
 	FailedLoginAttempts uint
-	FailedLoginTime     time.Time
+	FailedLoginTime     *time.Time
 
-	UserID uint64
-	User   User `gorm:"constraint:OnDelete:CASCADE;"`
+	UserID uint64 `gorm:"uniqueIndex"`
+	User   User   `gorm:"constraint:OnDelete:CASCADE;"`
 }
 
 type UserModel struct {
@@ -59,13 +113,15 @@ func (u *User) IsAnonymousUser() bool {
 }
 
 func (u UserModel) AddUser(user *User) error {
-	ctx, cancel := context.WithTimeout(context.Background(), Context_timeout)
+	ctx, cancel := context.WithTimeout(contextOf(u.DB), Context_timeout)
 	defer cancel()
 
 	err := u.DB.WithContext(ctx).Create(user).Error
 
 	if err != nil {
 		switch {
+		case errors.Is(err, gorm.ErrDuplicatedKey):
+			return ErrConflict
 		case errors.Is(err, gorm.ErrRecordNotFound):
 			return ErrRecordNotFound
 		default:
@@ -76,7 +132,7 @@ func (u UserModel) AddUser(user *User) error {
 }
 
 func (u UserModel) GetUser(userid uint64) (User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), Context_timeout)
+	ctx, cancel := context.WithTimeout(contextOf(u.DB), Context_timeout)
 	defer cancel()
 
 	var user User
@@ -95,45 +151,76 @@ func (u UserModel) GetUser(userid uint64) (User, error) {
 	return user, nil
 }
 
-func (u UserModel) UpdatePassword(userid uint64, password string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), Context_timeout)
+// users that dont exist or are deleted are left out
+func (u UserModel) GetUsers(userids []uint64) ([]User, error) {
+	ctx, cancel := context.WithTimeout(contextOf(u.DB), Context_timeout)
 	defer cancel()
 
-	err := u.DB.WithContext(ctx).Raw("UPDATE users SET password = ? WHERE user_id = ?", password, userid).Error
+	users := []User{}
+	if len(userids) == 0 {
+		return users, nil
+	}
+
+	err := u.DB.WithContext(ctx).Where("id IN ?", userids).Find(&users).Error
+	return users, err
+}
+
+func (u UserModel) GetUserByUsername(username string) (User, error) {
+	ctx, cancel := context.WithTimeout(contextOf(u.DB), Context_timeout)
+	defer cancel()
+
+	var user User
+
+	err := u.DB.WithContext(ctx).Where("username = ?", username).First(&user).Error
 
 	if err != nil {
 		switch {
 		case errors.Is(err, gorm.ErrRecordNotFound):
-			return ErrRecordNotFound
+			return user, ErrRecordNotFound
 		default:
-			return err
+			return user, err
 		}
 	}
+
+	return user, nil
+}
+
+func (u UserModel) UpdatePassword(userid uint64, password string) error {
+	ctx, cancel := context.WithTimeout(contextOf(u.DB), Context_timeout)
+	defer cancel()
+
+	t := u.DB.WithContext(ctx).Model(&User{ID: userid}).Update("password", password)
+	if t.Error != nil {
+		return t.Error
+	}
+	if t.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
 	return nil
 }
 
 func (u UserModel) GetUserPassword(username string) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), Context_timeout)
+	ctx, cancel := context.WithTimeout(contextOf(u.DB), Context_timeout)
 	defer cancel()
 
-	var db_password string
+	var user User
 
-	err := u.DB.WithContext(ctx).Raw("SELECT password FROM users WHERE username = ?", username).Scan(&db_password).Error
+	err := u.DB.WithContext(ctx).Select("password").Where("username = ?", username).First(&user).Error
 
 	if err != nil {
 		switch {
 		case errors.Is(err, gorm.ErrRecordNotFound):
-			return db_password, ErrRecordNotFound
+			return user.Password, ErrRecordNotFound
 		default:
-			return db_password, err
+			return user.Password, err
 		}
 	}
 
-	return db_password, nil
+	return user.Password, nil
 }
 
 func (u UserModel) UpdateUser(userid uint64, updates map[string]interface{}) error {
-	ctx, cancel := context.WithTimeout(context.Background(), Context_timeout)
+	ctx, cancel := context.WithTimeout(contextOf(u.DB), Context_timeout)
 	defer cancel()
 
 	err := u.DB.WithContext(ctx).Model(&User{ID: userid}).Omit("password").Updates(updates).Error
@@ -152,16 +239,19 @@ func (u UserModel) UpdateUser(userid uint64, updates map[string]interface{}) err
 
 func (u UserModel) CheckUserExists(username string) (bool, error) {
 
-	ctx, cancel := context.WithTimeout(context.Background(), Context_timeout)
+	ctx, cancel := context.WithTimeout(contextOf(u.DB), Context_timeout)
 	defer cancel()
 
-	r := u.DB.WithContext(ctx).Where(&User{Username: username})
+	var count int64
+
+	//deleted users keep their username until they are purged
+	err := u.DB.WithContext(ctx).Unscoped().Model(&User{}).Where("username = ?", username).Count(&count).Error
 
-	return r.RowsAffected != 0, r.Error
+	return count != 0, err
 }
 
 func (u UserModel) DeleteUser(user *User) error {
-	ctx, cancel := context.WithTimeout(context.Background(), Context_timeout)
+	ctx, cancel := context.WithTimeout(contextOf(u.DB), Context_timeout)
 	defer cancel()
 
 	err := u.DB.WithContext(ctx).Delete(user).Error
@@ -179,7 +269,7 @@ func (u UserModel) DeleteUser(user *User) error {
 func (u UserModel) FindSoftDeletedRecords() ([]User, error) {
 	var users []User
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(contextOf(u.DB), 5*time.Second)
 	defer cancel()
 
 	t := u.DB.WithContext(ctx).Where("is_del = 1").Find(&users)
@@ -189,12 +279,13 @@ func (u UserModel) FindSoftDeletedRecords() ([]User, error) {
 func (u UserModel) GetLoginAttempts(username string) (Login, error) {
 	var result Login
 
-	ctx, cancel := context.WithTimeout(context.Background(), Context_timeout)
+	ctx, cancel := context.WithTimeout(contextOf(u.DB), Context_timeout)
 	defer cancel()
 
-	err := u.DB.WithContext(ctx).Raw(`SELECT 
-	failed_login_attempts,failed_login_time 
-	FROM users WHERE username = ?`, username).Scan(&result).Error
+	//no logins row means no failed attempts yet, Find leaves result zeroed
+	err := u.DB.WithContext(ctx).
+		Where("user_id = (?)", u.DB.Model(&User{}).Select("id").Where("username = ?", username)).
+		Limit(1).Find(&result).Error
 
 	if err != nil {
 		switch {
@@ -210,12 +301,26 @@ func (u UserModel) GetLoginAttempts(username string) (Login, error) {
 
 func (u UserModel) UpdateLoginAttempts(username string) error {
 
-	ctx, cancel := context.WithTimeout(context.Background(), Context_timeout)
+	ctx, cancel := context.WithTimeout(contextOf(u.DB), Context_timeout)
 	defer cancel()
 
-	err := u.DB.WithContext(ctx).Raw(`UPDATE users SET
-	failed_login_attempts = failed_login_attempts + 1,
-	failed_login_time = ? WHERE username = ?`, time.Now(), username).Error
+	err := u.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user User
+		if err := tx.Select("id").Where("username = ?", username).First(&user).Error; err != nil {
+			return err
+		}
+
+		//logins row is created on the first failed attempt
+		var login Login
+		if err := tx.Where(Login{UserID: user.ID}).FirstOrCreate(&login).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&login).Updates(map[string]interface{}{
+			"failed_login_attempts": gorm.Expr("failed_login_attempts + ?", 1),
+			"failed_login_time":     time.Now(),
+		}).Error
+	})
 
 	if err != nil {
 		switch {
@@ -231,11 +336,19 @@ func (u UserModel) UpdateLoginAttempts(username string) error {
 }
 func (u UserModel) ResetLoginAttempts(username string) error {
 
-	ctx, cancel := context.WithTimeout(context.Background(), Context_timeout)
+	ctx, cancel := context.WithTimeout(contextOf(u.DB), Context_timeout)
 	defer cancel()
 
-	err := u.DB.WithContext(ctx).Raw(`UPDATE users SET
-	failed_login_attempts = 0 WHERE username = ?`, username).Error
+	//the user is looked up first, a user without failed attempts has no
+	//logins row to update and would look the same as an unknown username
+	err := u.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user User
+		if err := tx.Select("id").Where("username = ?", username).First(&user).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&Login{}).Where("user_id = ?", user.ID).Update("failed_login_attempts", 0).Error
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, gorm.ErrRecordNotFound):
@@ -248,3 +361,89 @@ func (u UserModel) ResetLoginAttempts(username string) error {
 	return nil
 
 }
+
+// invalidates every token issued so far and stores a one time password reset token
+func (u UserModel) RevokeSessions(userid uint64, resetHash string, resetExpiry time.Time) error {
+	ctx, cancel := context.WithTimeout(contextOf(u.DB), Context_timeout)
+	defer cancel()
+
+	t := u.DB.WithContext(ctx).Model(&User{ID: userid}).Updates(map[string]interface{}{
+		"tokens_valid_after":      time.Now(),
+		"password_reset_required": true,
+		"password_reset_hash":     resetHash,
+		"password_reset_expiry":   resetExpiry,
+	})
+	if t.Error != nil {
+		return t.Error
+	}
+	if t.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (u UserModel) GetUserByResetHash(resetHash string) (User, error) {
+	ctx, cancel := context.WithTimeout(contextOf(u.DB), Context_timeout)
+	defer cancel()
+
+	var user User
+
+	err := u.DB.WithContext(ctx).Where("password_reset_hash = ?", resetHash).First(&user).Error
+
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return user, ErrRecordNotFound
+		default:
+			return user, err
+		}
+	}
+
+	return user, nil
+}
+
+// sets the new password, lifts the lock of RevokeSessions and invalidates
+// every token issued with the old password
+func (u UserModel) CompletePasswordReset(userid uint64, password string) error {
+	ctx, cancel := context.WithTimeout(contextOf(u.DB), Context_timeout)
+	defer cancel()
+
+	t := u.DB.WithContext(ctx).Model(&User{ID: userid}).Updates(map[string]interface{}{
+		"password":                password,
+		"tokens_valid_after":      time.Now(),
+		"password_reset_required": false,
+		"password_reset_hash":     "",
+		"password_reset_expiry":   nil,
+	})
+	if t.Error != nil {
+		return t.Error
+	}
+	if t.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (u UserModel) RestoreUser(userid uint64) error {
+	ctx, cancel := context.WithTimeout(contextOf(u.DB), Context_timeout)
+	defer cancel()
+
+	t := u.DB.WithContext(ctx).Unscoped().Model(&User{}).Where("id = ? AND is_del = 1", userid).
+		Updates(map[string]interface{}{"is_del": 0, "deleted_at": nil})
+	if t.Error != nil {
+		return t.Error
+	}
+	if t.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// permanently removes users soft deleted before the given time
+func (u UserModel) PurgeDeletedUsers(before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(contextOf(u.DB), Context_timeout)
+	defer cancel()
+
+	t := u.DB.WithContext(ctx).Unscoped().Where("is_del = 1 AND deleted_at < ?", before).Delete(&User{})
+	return t.RowsAffected, t.Error
+}
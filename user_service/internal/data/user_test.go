@@ -0,0 +1,306 @@
+package data
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+
+	"user_service/internal/migrate"
+)
+
+// a fresh sqlite database with every migration applied
+func newTestModels(t *testing.T) Models {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), "users.db") + "?_pragma=foreign_keys(1)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrator, err := migrate.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+	return GetModels(db)
+}
+
+func addTestUser(t *testing.T, models Models, username string) User {
+	t.Helper()
+
+	user := User{Username: username, Email: username + "@example.com", Password: "hash"}
+	if err := models.Users.AddUser(&user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestAddUserLeavesTimesNull(t *testing.T) {
+	models := newTestModels(t)
+	added := addTestUser(t, models, "alice")
+
+	user, err := models.Users.GetUser(added.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.BirthDate != nil || user.TokensValidAfter != nil || user.PasswordResetExpiry != nil || user.DeletedAt != nil {
+		t.Errorf("unset times should read back as nil, got %+v", user)
+	}
+}
+
+func TestAddUserConflict(t *testing.T) {
+	models := newTestModels(t)
+	addTestUser(t, models, "alice")
+	bob := addTestUser(t, models, "bob")
+	if err := models.Users.DeleteUser(&bob); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, username := range []string{"alice", "bob"} {
+		user := User{Username: username, Email: "other@example.com"}
+		if err := models.Users.AddUser(&user); !errors.Is(err, ErrConflict) {
+			t.Errorf("AddUser(%q): got %v, want ErrConflict", username, err)
+		}
+	}
+}
+
+func TestValidateUser(t *testing.T) {
+	tests := []struct {
+		name  string
+		user  User
+		valid bool
+	}{
+		{"valid", User{Username: "alice", Email: "alice@example.com"}, true},
+		{"no username", User{Email: "alice@example.com"}, false},
+		{"long username", User{Username: strings.Repeat("a", 65), Email: "alice@example.com"}, false},
+		{"no email", User{Username: "alice"}, false},
+		{"not an email", User{Username: "alice", Email: "alice"}, false},
+		{"email with a name", User{Username: "alice", Email: "Alice <alice@example.com>"}, false},
+		{"long bio", User{Username: "alice", Email: "alice@example.com", Bio: strings.Repeat("a", 1001)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.user.Validate()
+			if (err == nil) != tt.valid {
+				t.Errorf("Validate() = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestLoginAttempts(t *testing.T) {
+	models := newTestModels(t)
+	addTestUser(t, models, "alice")
+
+	login, err := models.Users.GetLoginAttempts("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if login.FailedLoginAttempts != 0 || login.FailedLoginTime != nil {
+		t.Fatalf("no failed attempts yet, got %d at %v", login.FailedLoginAttempts, login.FailedLoginTime)
+	}
+
+	//resetting before the first failure has nothing to reset
+	if err := models.Users.ResetLoginAttempts("alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	before := time.Now().Add(-time.Second)
+	for i := 0; i < 3; i++ {
+		if err := models.Users.UpdateLoginAttempts("alice"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	login, err = models.Users.GetLoginAttempts("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if login.FailedLoginAttempts != 3 {
+		t.Errorf("got %d failed attempts, want 3", login.FailedLoginAttempts)
+	}
+	if login.FailedLoginTime == nil || login.FailedLoginTime.Before(before) {
+		t.Errorf("failed login time %v should be after %v", login.FailedLoginTime, before)
+	}
+
+	if err := models.Users.ResetLoginAttempts("alice"); err != nil {
+		t.Fatal(err)
+	}
+	login, err = models.Users.GetLoginAttempts("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if login.FailedLoginAttempts != 0 {
+		t.Errorf("got %d failed attempts after the reset, want 0", login.FailedLoginAttempts)
+	}
+
+	err = models.Users.UpdateLoginAttempts("nobody")
+	if !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("unknown user: got %v, want ErrRecordNotFound", err)
+	}
+	err = models.Users.ResetLoginAttempts("nobody")
+	if !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("resetting an unknown user: got %v, want ErrRecordNotFound", err)
+	}
+}
+
+func TestCompletePasswordReset(t *testing.T) {
+	models := newTestModels(t)
+	alice := addTestUser(t, models, "alice")
+
+	if err := models.Users.RevokeSessions(alice.ID, "reset hash", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	revoked, err := models.Users.GetUser(alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !revoked.PasswordResetRequired || revoked.TokensValidAfter == nil {
+		t.Fatalf("revoke should lock the account and invalidate tokens, got %+v", revoked)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if err := models.Users.CompletePasswordReset(alice.ID, "new hash"); err != nil {
+		t.Fatal(err)
+	}
+	user, err := models.Users.GetUser(alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Password != "new hash" || user.PasswordResetRequired {
+		t.Errorf("reset should set the password and unlock the account, got %+v", user)
+	}
+	if user.PasswordResetHash != "" || user.PasswordResetExpiry != nil {
+		t.Errorf("reset token is still usable, got %q until %v", user.PasswordResetHash, user.PasswordResetExpiry)
+	}
+	if user.TokensValidAfter == nil || !user.TokensValidAfter.After(*revoked.TokensValidAfter) {
+		t.Errorf("tokens valid after %v should move past %v", user.TokensValidAfter, revoked.TokensValidAfter)
+	}
+
+	if err := models.Users.CompletePasswordReset(alice.ID+100, "new hash"); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("unknown user: got %v, want ErrRecordNotFound", err)
+	}
+}
+
+func TestRestoreAndPurge(t *testing.T) {
+	models := newTestModels(t)
+	alice := addTestUser(t, models, "alice")
+	bob := addTestUser(t, models, "bob")
+
+	if err := models.Users.UpdateLoginAttempts("alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted := time.Now()
+	if err := models.Users.DeleteUser(&alice); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := models.Users.GetUser(alice.ID); !errors.Is(err, ErrRecordNotFound) {
+		t.Fatalf("deleted user: got %v, want ErrRecordNotFound", err)
+	}
+
+	//only restorable while soft deleted
+	if err := models.Users.RestoreUser(bob.ID); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("restoring a user that isnt deleted: got %v, want ErrRecordNotFound", err)
+	}
+
+	if err := models.Users.RestoreUser(alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := models.Users.GetUser(alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.DeletedAt != nil {
+		t.Errorf("restored user still has deleted_at %v", restored.DeletedAt)
+	}
+
+	if err := models.Users.DeleteUser(&restored); err != nil {
+		t.Fatal(err)
+	}
+
+	//updated_at moving on doesnt matter, only when the user was deleted
+	var row User
+	if err := models.db.Unscoped().First(&row, alice.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if row.DeletedAt == nil || row.DeletedAt.Before(deleted.Add(-time.Second)) {
+		t.Fatalf("deleted_at %v should be set on delete", row.DeletedAt)
+	}
+
+	tests := []struct {
+		name   string
+		before time.Time
+		want   int64
+	}{
+		{"deleted after the cutoff", deleted.Add(-time.Hour), 0},
+		{"deleted before the cutoff", time.Now().Add(time.Second), 1},
+		{"already purged", time.Now().Add(time.Second), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := models.Users.PurgeDeletedUsers(tt.before)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != tt.want {
+				t.Errorf("purged %d users, want %d", n, tt.want)
+			}
+		})
+	}
+
+	var count int64
+	models.db.Unscoped().Model(&User{}).Where("id = ?", alice.ID).Count(&count)
+	if count != 0 {
+		t.Error("purged user is still in the table")
+	}
+	models.db.Model(&Login{}).Where("user_id = ?", alice.ID).Count(&count)
+	if count != 0 {
+		t.Error("logins of the purged user are still in the table")
+	}
+	if _, err := models.Users.GetUser(bob.ID); err != nil {
+		t.Errorf("user that wasnt deleted: %v", err)
+	}
+	if err := models.Users.RestoreUser(alice.ID); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("restoring a purged user: got %v, want ErrRecordNotFound", err)
+	}
+}
+
+func TestCheckUserExists(t *testing.T) {
+	models := newTestModels(t)
+	addTestUser(t, models, "alice")
+	bob := addTestUser(t, models, "bob")
+	if err := models.Users.DeleteUser(&bob); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		username string
+		want     bool
+	}{
+		{"alice", true},
+		{"carol", false},
+		//the name stays taken until the user is purged
+		{"bob", true},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.username, func(t *testing.T) {
+			exists, err := models.Users.CheckUserExists(tt.username)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if exists != tt.want {
+				t.Errorf("CheckUserExists(%q) = %v, want %v", tt.username, exists, tt.want)
+			}
+		})
+	}
+}
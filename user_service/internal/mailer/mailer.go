@@ -0,0 +1,52 @@
+package mailer
+
+import (
+	"fmt"
+	"log/slog"
+	"net/smtp"
+	"strings"
+)
+
+// anything that can deliver an email, swap implementations in application
+type Sender interface {
+	Send(to string, subject string, body string) error
+}
+
+type SMTPSender struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+func (s SMTPSender) Send(to string, subject string, body string) error {
+	addr := fmt.Sprintf("%s:%d", s.Host, s.Port)
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	msg := strings.Join([]string{
+		"From: " + s.From,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=\"utf-8\"",
+		"",
+		body,
+	}, "\r\n")
+
+	return smtp.SendMail(addr, auth, s.From, []string{to}, []byte(msg))
+}
+
+// used when no smtp server is configured, writes the mail to the log instead
+type LogSender struct {
+	Logger *slog.Logger
+}
+
+func (l LogSender) Send(to string, subject string, body string) error {
+	l.Logger.Info("mail not sent, smtp_host is not configured", "to", to, "subject", subject, "body", body)
+	return nil
+}
@@ -0,0 +1,308 @@
+package migrate
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed migrations/*/*.sql
+var migrationFiles embed.FS
+
+// arbitrary key for pg_advisory_lock, every instance has to use the same one
+const lockKey = 7_310_228
+
+// name for mysql GET_LOCK and how long to wait for it in seconds
+const lockName = "user_service_migrations"
+const lockTimeout = 60
+
+var (
+	ErrChecksumMismatch = errors.New("applied migration was modified")
+	ErrUnknownVersion   = errors.New("database has a migration unknown to this binary")
+	ErrLockTimeout      = errors.New("timed out waiting for the migration lock")
+)
+
+type Migration struct {
+	Version  uint64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// row in schema_migrations
+type AppliedMigration struct {
+	Version   uint64 `gorm:"primarykey;autoIncrement:false"`
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+func (AppliedMigration) TableName() string {
+	return "schema_migrations"
+}
+
+type Status struct {
+	Version   uint64     `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+type Migrator struct {
+	DB         *gorm.DB
+	migrations []Migration
+}
+
+// picks the migrations written for the dialect of db (postgres, mysql or sqlite)
+func New(db *gorm.DB) (*Migrator, error) {
+	migrations, err := load(migrationFiles, db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{DB: db, migrations: migrations}, nil
+}
+
+// reads NNNN_name.up.sql / NNNN_name.down.sql pairs sorted by version
+func load(fsys fs.FS, dialect string) ([]Migration, error) {
+	dir := path.Join("migrations", dialect)
+
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for dialect %q: %w", dialect, err)
+	}
+
+	byVersion := map[uint64]*Migration{}
+	for _, entry := range entries {
+		filename := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(filename, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(filename, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(filename, "."+direction+".sql")
+		versionStr, name, found := strings.Cut(base, "_")
+		if !found {
+			return nil, fmt.Errorf("migration %s: expected NNNN_name.%s.sql", filename, direction)
+		}
+		version, err := strconv.ParseUint(versionStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version: %w", filename, err)
+		}
+
+		contents, err := fs.ReadFile(fsys, path.Join(dir, filename))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, name)
+		}
+
+		if direction == "up" {
+			m.Up = string(contents)
+			sum := sha256.Sum256(contents)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(contents)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// runs fn on a single connection while holding the migration lock so that
+// instances starting at the same time dont apply the same migration twice
+func (m *Migrator) withLock(fn func(conn *gorm.DB) error) error {
+	return m.DB.Connection(func(conn *gorm.DB) error {
+		unlock, err := lock(conn)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		if err := conn.AutoMigrate(&AppliedMigration{}); err != nil {
+			return err
+		}
+		return fn(conn)
+	})
+}
+
+func lock(conn *gorm.DB) (func(), error) {
+	switch conn.Dialector.Name() {
+	case "postgres":
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", lockKey).Error; err != nil {
+			return nil, err
+		}
+		return func() { conn.Exec("SELECT pg_advisory_unlock(?)", lockKey) }, nil
+
+	case "mysql":
+		var acquired int
+		if err := conn.Raw("SELECT GET_LOCK(?, ?)", lockName, lockTimeout).Scan(&acquired).Error; err != nil {
+			return nil, err
+		}
+		if acquired != 1 {
+			return nil, ErrLockTimeout
+		}
+		return func() { conn.Exec("SELECT RELEASE_LOCK(?)", lockName) }, nil
+
+	default:
+		// sqlite serializes writers on the database file, a concurrent
+		// duplicate fails on the schema_migrations primary key and rolls back
+		return func() {}, nil
+	}
+}
+
+// applied migrations keyed by version, fails if any of them was changed after being applied
+func (m *Migrator) applied(conn *gorm.DB) (map[uint64]AppliedMigration, error) {
+	var rows []AppliedMigration
+	if err := conn.Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	known := map[uint64]Migration{}
+	for _, migration := range m.migrations {
+		known[migration.Version] = migration
+	}
+
+	applied := map[uint64]AppliedMigration{}
+	for _, row := range rows {
+		migration, ok := known[row.Version]
+		if !ok {
+			return nil, fmt.Errorf("%w: %d_%s", ErrUnknownVersion, row.Version, row.Name)
+		}
+		if migration.Checksum != row.Checksum {
+			return nil, fmt.Errorf("%w: %d_%s", ErrChecksumMismatch, row.Version, row.Name)
+		}
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// applies every pending migration, returns the ones that ran
+func (m *Migrator) Up() ([]Migration, error) {
+	var ran []Migration
+
+	err := m.withLock(func(conn *gorm.DB) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(migration.Up).Error; err != nil {
+					return err
+				}
+				return tx.Create(&AppliedMigration{
+					Version:   migration.Version,
+					Name:      migration.Name,
+					Checksum:  migration.Checksum,
+					AppliedAt: time.Now(),
+				}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			ran = append(ran, migration)
+		}
+		return nil
+	})
+
+	return ran, err
+}
+
+// rolls back the last steps applied migrations, returns the ones that were reverted
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	var reverted []Migration
+
+	err := m.withLock(func(conn *gorm.DB) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+			}
+
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(migration.Down).Error; err != nil {
+					return err
+				}
+				return tx.Delete(&AppliedMigration{Version: migration.Version}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+
+	return reverted, err
+}
+
+func (m *Migrator) Status() ([]Status, error) {
+	var status []Status
+
+	err := m.withLock(func(conn *gorm.DB) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			s := Status{Version: migration.Version, Name: migration.Name}
+			if row, ok := applied[migration.Version]; ok {
+				s.Applied = true
+				s.AppliedAt = &row.AppliedAt
+			}
+			status = append(status, s)
+		}
+		return nil
+	})
+
+	return status, err
+}
@@ -0,0 +1,38 @@
+CREATE TABLE users (
+    id                      BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    created_at              DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    updated_at              DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    username                VARCHAR(191) NOT NULL UNIQUE,
+    email                   VARCHAR(255) NOT NULL DEFAULT '',
+    password                VARCHAR(255) NOT NULL DEFAULT '',
+    bio                     TEXT,
+    birth_date              DATETIME(3) NULL,
+    is_admin                BOOLEAN NOT NULL DEFAULT FALSE,
+    tokens_valid_after      DATETIME(3) NULL,
+    password_reset_required BOOLEAN NOT NULL DEFAULT FALSE,
+    password_reset_hash     VARCHAR(64) NOT NULL DEFAULT '',
+    password_reset_expiry   DATETIME(3) NULL,
+    is_del                  TINYINT UNSIGNED NOT NULL DEFAULT 0,
+    INDEX idx_users_password_reset_hash (password_reset_hash)
+);
+
+CREATE TABLE logins (
+    id                    BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    created_at            DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    updated_at            DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    failed_login_attempts INT UNSIGNED NOT NULL DEFAULT 0,
+    failed_login_time     DATETIME(3) NULL,
+    user_id               BIGINT UNSIGNED NOT NULL UNIQUE,
+    CONSTRAINT fk_logins_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE images (
+    id         BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    created_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    updated_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    size       BIGINT NOT NULL DEFAULT 0,
+    location   VARCHAR(1024) NOT NULL DEFAULT '',
+    user_id    BIGINT UNSIGNED NOT NULL,
+    INDEX idx_images_user_id (user_id),
+    CONSTRAINT fk_images_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
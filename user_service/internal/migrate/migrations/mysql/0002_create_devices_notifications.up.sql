@@ -0,0 +1,25 @@
+CREATE TABLE devices (
+    id                BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    created_at        DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    updated_at        DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    ip_address        VARCHAR(45) NOT NULL DEFAULT '',
+    user_agent        VARCHAR(512) NOT NULL DEFAULT '',
+    last_seen         DATETIME(3) NULL,
+    revoke_token_hash VARCHAR(64) NOT NULL DEFAULT '',
+    revoked           BOOLEAN NOT NULL DEFAULT FALSE,
+    user_id           BIGINT UNSIGNED NOT NULL,
+    INDEX idx_devices_user_id (user_id, ip_address, user_agent(191)),
+    INDEX idx_devices_revoke_token_hash (revoke_token_hash),
+    CONSTRAINT fk_devices_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE notifications (
+    id         BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    created_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    kind       VARCHAR(64) NOT NULL DEFAULT '',
+    message    TEXT,
+    `read`     BOOLEAN NOT NULL DEFAULT FALSE,
+    user_id    BIGINT UNSIGNED NOT NULL,
+    INDEX idx_notifications_user_id (user_id, created_at),
+    CONSTRAINT fk_notifications_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
@@ -0,0 +1,13 @@
+-- user_id 0 holds the keys of anonymous requests, so no foreign key
+CREATE TABLE idempotency_keys (
+    user_id         BIGINT UNSIGNED NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    fingerprint     VARCHAR(64) NOT NULL DEFAULT '',
+    status          INT NOT NULL DEFAULT 0,
+    header          TEXT,
+    body            MEDIUMBLOB,
+    created_at      DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    expires_at      DATETIME(3) NOT NULL,
+    PRIMARY KEY (user_id, idempotency_key),
+    INDEX idx_idempotency_keys_expires_at (expires_at)
+);
@@ -0,0 +1 @@
+ALTER TABLE devices ADD COLUMN revoke_token_expiry DATETIME(3) NULL AFTER revoke_token_hash;
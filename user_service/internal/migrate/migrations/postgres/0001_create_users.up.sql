@@ -0,0 +1,38 @@
+CREATE TABLE users (
+    id                      BIGSERIAL PRIMARY KEY,
+    created_at              TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at              TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    username                TEXT NOT NULL UNIQUE,
+    email                   TEXT NOT NULL DEFAULT '',
+    password                TEXT NOT NULL DEFAULT '',
+    bio                     TEXT NOT NULL DEFAULT '',
+    birth_date              TIMESTAMPTZ,
+    is_admin                BOOLEAN NOT NULL DEFAULT FALSE,
+    tokens_valid_after      TIMESTAMPTZ,
+    password_reset_required BOOLEAN NOT NULL DEFAULT FALSE,
+    password_reset_hash     TEXT NOT NULL DEFAULT '',
+    password_reset_expiry   TIMESTAMPTZ,
+    is_del                  SMALLINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX idx_users_password_reset_hash ON users (password_reset_hash);
+
+CREATE TABLE logins (
+    id                    BIGSERIAL PRIMARY KEY,
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at            TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    failed_login_attempts INTEGER NOT NULL DEFAULT 0,
+    failed_login_time     TIMESTAMPTZ,
+    user_id               BIGINT NOT NULL UNIQUE REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE images (
+    id         BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    size       BIGINT NOT NULL DEFAULT 0,
+    location   TEXT NOT NULL DEFAULT '',
+    user_id    BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_images_user_id ON images (user_id);
@@ -0,0 +1,25 @@
+CREATE TABLE devices (
+    id                BIGSERIAL PRIMARY KEY,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ip_address        TEXT NOT NULL DEFAULT '',
+    user_agent        TEXT NOT NULL DEFAULT '',
+    last_seen         TIMESTAMPTZ,
+    revoke_token_hash TEXT NOT NULL DEFAULT '',
+    revoked           BOOLEAN NOT NULL DEFAULT FALSE,
+    user_id           BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_devices_user_id ON devices (user_id, ip_address, user_agent);
+CREATE INDEX idx_devices_revoke_token_hash ON devices (revoke_token_hash);
+
+CREATE TABLE notifications (
+    id         BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    kind       TEXT NOT NULL DEFAULT '',
+    message    TEXT NOT NULL DEFAULT '',
+    read       BOOLEAN NOT NULL DEFAULT FALSE,
+    user_id    BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_notifications_user_id ON notifications (user_id, created_at);
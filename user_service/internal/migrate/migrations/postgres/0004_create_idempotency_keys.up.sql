@@ -0,0 +1,14 @@
+-- user_id 0 holds the keys of anonymous requests, so no foreign key
+CREATE TABLE idempotency_keys (
+    user_id         BIGINT NOT NULL,
+    idempotency_key TEXT NOT NULL,
+    fingerprint     TEXT NOT NULL DEFAULT '',
+    status          INTEGER NOT NULL DEFAULT 0,
+    header          TEXT NOT NULL DEFAULT '',
+    body            BYTEA,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at      TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_id, idempotency_key)
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
@@ -0,0 +1 @@
+ALTER TABLE devices ADD COLUMN revoke_token_expiry TIMESTAMPTZ;
@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMPTZ;
+UPDATE users SET deleted_at = updated_at WHERE is_del = 1;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS images;
+DROP TABLE IF EXISTS logins;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,38 @@
+CREATE TABLE users (
+    id                      INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at              DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at              DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    username                TEXT NOT NULL UNIQUE,
+    email                   TEXT NOT NULL DEFAULT '',
+    password                TEXT NOT NULL DEFAULT '',
+    bio                     TEXT NOT NULL DEFAULT '',
+    birth_date              DATETIME,
+    is_admin                BOOLEAN NOT NULL DEFAULT 0,
+    tokens_valid_after      DATETIME,
+    password_reset_required BOOLEAN NOT NULL DEFAULT 0,
+    password_reset_hash     TEXT NOT NULL DEFAULT '',
+    password_reset_expiry   DATETIME,
+    is_del                  INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX idx_users_password_reset_hash ON users (password_reset_hash);
+
+CREATE TABLE logins (
+    id                    INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at            DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at            DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    failed_login_attempts INTEGER NOT NULL DEFAULT 0,
+    failed_login_time     DATETIME,
+    user_id               INTEGER NOT NULL UNIQUE REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE images (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    size       INTEGER NOT NULL DEFAULT 0,
+    location   TEXT NOT NULL DEFAULT '',
+    user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_images_user_id ON images (user_id);
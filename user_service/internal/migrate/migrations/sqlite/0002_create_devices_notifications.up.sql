@@ -0,0 +1,25 @@
+CREATE TABLE devices (
+    id                INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at        DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at        DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ip_address        TEXT NOT NULL DEFAULT '',
+    user_agent        TEXT NOT NULL DEFAULT '',
+    last_seen         DATETIME,
+    revoke_token_hash TEXT NOT NULL DEFAULT '',
+    revoked           BOOLEAN NOT NULL DEFAULT 0,
+    user_id           INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_devices_user_id ON devices (user_id, ip_address, user_agent);
+CREATE INDEX idx_devices_revoke_token_hash ON devices (revoke_token_hash);
+
+CREATE TABLE notifications (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    kind       TEXT NOT NULL DEFAULT '',
+    message    TEXT NOT NULL DEFAULT '',
+    read       BOOLEAN NOT NULL DEFAULT 0,
+    user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_notifications_user_id ON notifications (user_id, created_at);